@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"benzinga-webhook/internal/envelope"
+	"benzinga-webhook/internal/sink"
+)
+
+// decryptCommand implements "decrypt -keyring FILE [INPUT]". It reads JSON
+// values (a batch array or NDJSON records) from INPUT or stdin and writes them
+// with every encrypted field replaced by its original value, one per line.
+func decryptCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	keyringPath := fs.String("keyring", os.Getenv("ENCRYPTION_KEYRING"), "keyring `file`")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	keyring, err := envelope.LoadKeyring(*keyringPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "decrypt:", err)
+		return 1
+	}
+
+	in := stdin
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, "decrypt:", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	dec := json.NewDecoder(in)
+	for n := 1; ; n++ {
+		var record json.RawMessage
+		if err := dec.Decode(&record); errors.Is(err, io.EOF) {
+			return 0
+		} else if err != nil {
+			fmt.Fprintf(stderr, "decrypt: value %d: %v\n", n, err)
+			return 1
+		}
+		plain, err := decryptValue(keyring, record)
+		if err != nil {
+			fmt.Fprintf(stderr, "decrypt: value %d: %v\n", n, err)
+			return 1
+		}
+		fmt.Fprintln(stdout, string(plain))
+	}
+}
+
+// decryptValue decrypts a single record or each record of a batch array.
+// Encrypted values are bound to their pointer within the record, so a batch
+// cannot be decrypted as one document.
+func decryptValue(keyring *envelope.Keyring, value json.RawMessage) (json.RawMessage, error) {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(value, &batch); err != nil {
+		return keyring.DecryptRecord(value)
+	}
+	for i, record := range batch {
+		plain, err := keyring.DecryptRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", i, err)
+		}
+		batch[i] = plain
+	}
+	return sink.JSONArray(batch), nil
+}
+
+// rotateKeyCommand implements "rotate-key -keyring FILE -id ID", which adds a
+// new current key to the keyring.
+func rotateKeyCommand(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	keyringPath := fs.String("keyring", os.Getenv("ENCRYPTION_KEYRING"), "keyring `file`, created if missing")
+	id := fs.String("id", "", "`ID` of the new key, e.g. 2024-06")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := envelope.Rotate(*keyringPath, *id); err != nil {
+		fmt.Fprintln(stderr, "rotate-key:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"benzinga-webhook/internal/envelope"
+	"benzinga-webhook/internal/sink"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	var stderr bytes.Buffer
+	require.Equal(t, 0, rotateKeyCommand([]string{"-keyring", path, "-id", "2024-01"}, &stderr), stderr.String())
+
+	keyring, err := envelope.LoadKeyring(path)
+	require.NoError(t, err)
+	e, err := envelope.NewEncryptor(keyring, []string{"/ip"})
+	require.NoError(t, err)
+	first, err := e.Apply(json.RawMessage(`{"ip":"10.0.0.1"}`))
+	require.NoError(t, err)
+	second, err := e.Apply(json.RawMessage(`{"ip":"10.0.0.2"}`))
+	require.NoError(t, err)
+
+	// A batch as posted by the HTTP sink, followed by an NDJSON record.
+	input := string(sink.JSONArray([]json.RawMessage{first, second})) + "\n" + string(first) + "\n"
+	var stdout bytes.Buffer
+	code := decryptCommand([]string{"-keyring", path}, strings.NewReader(input), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "[{\"ip\":\"10.0.0.1\"},{\"ip\":\"10.0.0.2\"}]\n{\"ip\":\"10.0.0.1\"}\n", stdout.String())
+
+	stderr.Reset()
+	code = decryptCommand([]string{"-keyring", filepath.Join(t.TempDir(), "missing.json")}, strings.NewReader(input), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "read keyring")
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"benzinga-webhook/internal/batcher"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/event"
+	"benzinga-webhook/internal/handler"
+	"benzinga-webhook/internal/mocksink"
+	"benzinga-webhook/internal/sink"
+	"benzinga-webhook/internal/tail"
+	"benzinga-webhook/internal/transform"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// loadgenTitle prefixes the title of every generated entry, followed by its
+// sequence number, so that the mock sink can tell when each was delivered.
+const loadgenTitle = "loadgen "
+
+// loadReport is the JSON printed by loadgenCommand. Durations are in
+// milliseconds.
+type loadReport struct {
+	Revision     string  `json:"revision,omitempty"`
+	Target       string  `json:"target"`
+	Rate         float64 `json:"rate"`
+	Duration     string  `json:"duration"`
+	Concurrency  int     `json:"concurrency"`
+	Requests     int     `json:"requests"`
+	AchievedRate float64 `json:"achieved_rate"`
+	Valid        int     `json:"valid"`
+	Invalid      int     `json:"invalid"`
+	Accepted     int     `json:"accepted"`
+	Rejected     int     `json:"rejected"`
+	Failed       int     `json:"failed"`
+	// Unexpected counts valid entries that were not accepted and invalid
+	// entries that were.
+	Unexpected int `json:"unexpected"`
+	// AcceptanceRate is the fraction of valid entries accepted.
+	AcceptanceRate float64     `json:"acceptance_rate"`
+	Latency        percentiles `json:"latency_ms"`
+	// Delivered counts accepted entries that reached the mock sink, and
+	// Dropped those that had not by the end of the drain.
+	Delivered int `json:"delivered"`
+	Dropped   int `json:"dropped"`
+	// QueueDropped is the receiver's batcher_dropped count for log_entry
+	// during the run, when its /admin/debug/vars could be read.
+	QueueDropped  *int64      `json:"queue_dropped,omitempty"`
+	DeliveryDelay percentiles `json:"delivery_delay_ms"`
+	// FailedBatches counts the batches the in-process receiver could not
+	// deliver after its retries.
+	FailedBatches *int64 `json:"failed_batches,omitempty"`
+}
+
+type percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// percentilesOf returns the nearest-rank percentiles of ds in milliseconds.
+func percentilesOf(ds []time.Duration) percentiles {
+	if len(ds) == 0 {
+		return percentiles{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	at := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(ds)))) - 1
+		return math.Round(float64(ds[max(i, 0)])/float64(time.Microsecond)) / 1000
+	}
+	return percentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: at(1)}
+}
+
+// deliveries records when generated entries were sent and reached the mock
+// sink.
+type deliveries struct {
+	mu     sync.Mutex
+	sentAt map[int]time.Time // of entries not yet delivered
+	delays []time.Duration
+}
+
+func (d *deliveries) sent(seq int, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sentAt[seq] = at
+}
+
+func (d *deliveries) delivered() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.delays)
+}
+
+// record notes the delivery of the generated entries in a batch the mock sink
+// accepted.
+func (d *deliveries) record(req mocksink.Request) {
+	if !req.Accepted() {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, record := range req.Records {
+		var entry struct {
+			Title string `json:"title"`
+		}
+		if json.Unmarshal(record, &entry) != nil {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimPrefix(entry.Title, loadgenTitle))
+		if err != nil {
+			continue
+		}
+		if at, ok := d.sentAt[seq]; ok {
+			d.delays = append(d.delays, req.ReceivedAt.Sub(at))
+			delete(d.sentAt, seq)
+		}
+	}
+}
+
+// loadEntry returns the seq-th generated LogEntry payload. Invalid payloads
+// break one validation rule each, in turn.
+func loadEntry(seq int, invalid bool, now time.Time) []byte {
+	entry := map[string]any{
+		"user_id":  seq%1000 + 1,
+		"total":    json.Number(fmt.Sprintf("%d.%02d", 1+seq%500, seq%100)),
+		"currency": "USD",
+		"title":    loadgenTitle + strconv.Itoa(seq),
+		"meta": map[string]any{
+			"logins": []map[string]string{{
+				"time": now.Add(-time.Minute).UTC().Format(time.RFC3339),
+				"ip":   fmt.Sprintf("10.%d.%d.%d", seq>>16&255, seq>>8&255, seq&255),
+			}},
+			"phone_numbers": map[string]string{"home": "123-4567-891", "mobile": "765-4321-912"},
+		},
+		"completed": seq%2 == 0,
+	}
+	if invalid {
+		switch seq % 4 {
+		case 0:
+			entry["user_id"] = 0
+		case 1:
+			entry["total"] = json.Number("-1")
+		case 2:
+			entry["meta"].(map[string]any)["logins"].([]map[string]string)[0]["ip"] = "999.0.0.1"
+		default:
+			entry["currency"] = "XXXX"
+		}
+	}
+	body, _ := json.Marshal(entry)
+	return body
+}
+
+// loadResult is the outcome of one request.
+type loadResult struct {
+	invalid bool
+	status  int // 0 when the request failed
+	latency time.Duration
+}
+
+// loadgenCommand implements "loadgen [-url URL] [-rate R] [-duration D]
+// [-concurrency N] [-invalid F] [-scenario FILE]". It POSTs generated LogEntry payloads, a
+// fraction F of them invalid, at R requests per second for D, then waits up to
+// -drain for the accepted entries to reach a mock sink, and prints a
+// loadReport. Without -url it runs a receiver in-process, configured like the
+// server but delivering to the mock sink; with -url the receiver must post to
+// the mock sink at -sink-addr. The mock sink answers batches as the
+// mocksink.Scenario in -scenario says.
+func loadgenCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := fs.String("url", "", "`URL` of the receiver's /log endpoint; empty runs one in-process")
+	sinkAddr := fs.String("sink-addr", "", "`address` of the mock sink, 127.0.0.1:9000 with -url")
+	rate := fs.Float64("rate", 200, "`requests` per second")
+	duration := fs.Duration("duration", 10*time.Second, "`duration` of the run")
+	concurrency := fs.Int("concurrency", 64, "maximum `number` of requests in flight")
+	invalid := fs.Float64("invalid", 0.1, "`fraction` of invalid payloads")
+	drain := fs.Duration("drain", 15*time.Second, "how `long` to wait for accepted entries to be delivered")
+	scenarioFile := fs.String("scenario", "", "JSON `file` of the mock sink's scenario; empty accepts every batch")
+	adminToken := fs.String("admin-token", os.Getenv("ADMIN_TOKEN"), "`token` to read the receiver's /admin/debug/vars with")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *rate <= 0 || *duration <= 0 || *concurrency < 1 || *invalid < 0 || *invalid > 1 {
+		fmt.Fprintln(stderr, "loadgen: -rate, -duration and -concurrency must be positive and -invalid between 0 and 1")
+		return 2
+	}
+	if *sinkAddr == "" {
+		*sinkAddr = "127.0.0.1:0"
+		if *target != "" {
+			*sinkAddr = "127.0.0.1:9000"
+		}
+	}
+
+	var scenario mocksink.Scenario
+	if *scenarioFile != "" {
+		var err error
+		if scenario, err = mocksink.LoadScenario(*scenarioFile); err != nil {
+			fmt.Fprintln(stderr, "loadgen:", err)
+			return 1
+		}
+	}
+
+	d := &deliveries{sentAt: make(map[int]time.Time)}
+	mock := mocksink.New(scenario)
+	mock.OnRequest = d.record
+	lis, err := net.Listen("tcp", *sinkAddr)
+	if err != nil {
+		fmt.Fprintln(stderr, "loadgen: mock sink:", err)
+		return 1
+	}
+	sinkSrv := &http.Server{Handler: mock, ReadHeaderTimeout: 5 * time.Second}
+	go func() { _ = sinkSrv.Serve(lis) }()
+	defer sinkSrv.Close()
+
+	stop := func() {}
+	// The in-process receiver counts the batches it gives up on instead of
+	// exiting, and the records in them need not be waited for.
+	var failedBatches, failedRecords *atomic.Int64
+	if *target == "" {
+		if *adminToken == "" {
+			*adminToken = sink.NewBatchID()
+		}
+		failedBatches, failedRecords = new(atomic.Int64), new(atomic.Int64)
+		countFailed := batcher.OnFailure(func(_ string, b sink.Batch, _ error) {
+			failedBatches.Add(1)
+			failedRecords.Add(int64(len(b.Records)))
+		})
+		var err error
+		if *target, stop, err = startReceiver("http://"+lis.Addr().String(), *adminToken, countFailed); err != nil {
+			fmt.Fprintln(stderr, "loadgen:", err)
+			return 1
+		}
+	}
+	varsURL := debugVarsURL(*target)
+	droppedBefore, varsOK := queueDropped(varsURL, *adminToken)
+
+	report := generate(*target, *rate, *duration, *concurrency, *invalid, d)
+	if droppedAfter, ok := queueDropped(varsURL, *adminToken); ok && varsOK {
+		n := droppedAfter - droppedBefore
+		report.QueueDropped = &n
+	}
+	// Stopping the in-process receiver flushes its last batch.
+	stop()
+	deadline := time.Now().Add(*drain)
+	failed := func() int {
+		if failedRecords == nil {
+			return 0
+		}
+		return int(failedRecords.Load())
+	}
+	for d.delivered()+failed() < report.Accepted && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	d.mu.Lock()
+	report.Delivered = len(d.delays)
+	report.DeliveryDelay = percentilesOf(d.delays)
+	d.mu.Unlock()
+	report.Dropped = max(report.Accepted-report.Delivered, 0)
+	if failedBatches != nil {
+		n := failedBatches.Load()
+		report.FailedBatches = &n
+	}
+	report.Rate, report.Duration, report.Concurrency = *rate, duration.String(), *concurrency
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				report.Revision = s.Value
+			}
+		}
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(stderr, "loadgen:", err)
+		return 1
+	}
+	return 0
+}
+
+// generate sends rate requests per second to target for duration, each
+// request waiting for one of concurrency slots, and reports on the responses.
+func generate(target string, rate float64, duration time.Duration, concurrency int, invalidFraction float64, d *deliveries) loadReport {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: concurrency},
+	}
+	defer client.CloseIdleConnections()
+	results := make(chan loadResult, concurrency)
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	go func() {
+		start := time.Now()
+		for seq := 0; ; seq++ {
+			due := start.Add(time.Duration(float64(seq) / rate * float64(time.Second)))
+			if due.Sub(start) >= duration {
+				break
+			}
+			time.Sleep(time.Until(due))
+			slots <- struct{}{}
+			// Spread invalid payloads evenly: seq is invalid when it takes
+			// the running count of invalid ones to the next integer.
+			invalid := math.Floor(float64(seq+1)*invalidFraction) > math.Floor(float64(seq)*invalidFraction)
+			wg.Add(1)
+			go func(seq int, due time.Time) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				now := time.Now()
+				body := loadEntry(seq, invalid, now)
+				d.sent(seq, now)
+				res := loadResult{invalid: invalid}
+				resp, err := client.Post(target, "application/json", bytes.NewReader(body))
+				if err == nil {
+					_, _ = io.Copy(io.Discard, resp.Body)
+					_ = resp.Body.Close()
+					res.status = resp.StatusCode
+				}
+				// Latency counts from when the request was due, not sent, so
+				// that waiting for a free slot behind slow responses counts.
+				res.latency = time.Since(due)
+				results <- res
+			}(seq, due)
+		}
+		wg.Wait()
+		close(results)
+	}()
+
+	report := loadReport{Target: target}
+	var latencies []time.Duration
+	var validAccepted int
+	start := time.Now()
+	for res := range results {
+		report.Requests++
+		latencies = append(latencies, res.latency)
+		accepted := res.status >= 200 && res.status < 300
+		switch {
+		case accepted:
+			report.Accepted++
+		case res.status >= 400 && res.status < 500 && res.status != http.StatusTooManyRequests:
+			report.Rejected++
+		default:
+			report.Failed++
+		}
+		switch {
+		case res.invalid:
+			report.Invalid++
+		case accepted:
+			report.Valid++
+			validAccepted++
+		default:
+			report.Valid++
+		}
+		if accepted == res.invalid {
+			report.Unexpected++
+		}
+	}
+	elapsed := time.Since(start)
+	report.AchievedRate = math.Round(float64(report.Requests)/elapsed.Seconds()*10) / 10
+	if report.Valid > 0 {
+		report.AcceptanceRate = math.Round(float64(validAccepted)/float64(report.Valid)*1e4) / 1e4
+	}
+	report.Latency = percentilesOf(latencies)
+	return report
+}
+
+// startReceiver serves POST /log, and GET /admin/debug/vars behind adminToken,
+// on a local port with the event types of the configuration, every batch going
+// to sinkURL through batchers created with opts. It returns
+// the /log URL and a function stopping the receiver, which flushes the
+// batches being collected.
+func startReceiver(sinkURL, adminToken string, opts ...batcher.Option) (string, func(), error) {
+	cfg := config.Load()
+	cfg.PostEndpoint = sinkURL
+	cfg.Sinks = []config.SinkConfig{{Name: config.DefaultSink, Type: "http", URL: sinkURL, Format: config.FormatJSON}}
+	for i := range cfg.Events {
+		cfg.Events[i].Sinks = nil
+	}
+	log := zap.NewNop()
+	transforms, err := transform.New(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	hub, err := tail.New(cfg.Tail, []byte(cfg.RedactHMACKey))
+	if err != nil {
+		return "", nil, err
+	}
+	sinks, err := sink.FromConfig(cfg, transforms, log)
+	if err != nil {
+		return "", nil, err
+	}
+	events, err := buildEvents(cfg, transforms, sinks, hub, log, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	h := handler.New(log, events, cfg)
+	r := chi.NewRouter()
+	r.Post("/log", h.LogPayload)
+	r.With(handler.RequireToken(adminToken)).Get("/admin/debug/vars", expvar.Handler().ServeHTTP)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	srv := &http.Server{Handler: r, ReadHeaderTimeout: 5 * time.Second}
+	events.Start()
+	go func() { _ = srv.Serve(lis) }()
+	return "http://" + lis.Addr().String() + "/log", func() {
+		_ = srv.Close()
+		hub.Close()
+		events.Stop()
+	}, nil
+}
+
+// debugVarsURL returns the /admin/debug/vars URL of the receiver serving
+// target.
+func debugVarsURL(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	u.Path, u.RawQuery = "/admin/debug/vars", ""
+	return u.String()
+}
+
+// queueDropped returns the batcher_dropped count for log_entry published at
+// varsURL, read with the admin token.
+func queueDropped(varsURL, adminToken string) (int64, bool) {
+	req, err := http.NewRequest(http.MethodGet, varsURL, nil)
+	if err != nil {
+		return 0, false
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+	var vars struct {
+		BatcherDropped map[string]int64 `json:"batcher_dropped"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&vars) != nil || vars.BatcherDropped == nil {
+		return 0, false
+	}
+	return vars.BatcherDropped[event.LogEntry], true
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadgenCommand(t *testing.T) {
+	t.Setenv("BATCH_SIZE", "10")
+	t.Setenv("BATCH_INTERVAL", "100ms")
+	var stdout, stderr bytes.Buffer
+	code := loadgenCommand([]string{"-rate", "200", "-duration", "200ms", "-invalid", "0.25", "-drain", "5s"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	var report loadReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, 40, report.Requests)
+	assert.Equal(t, 10, report.Invalid)
+	assert.Equal(t, 30, report.Accepted)
+	assert.Equal(t, 10, report.Rejected)
+	assert.Zero(t, report.Failed)
+	assert.Zero(t, report.Unexpected)
+	assert.Equal(t, 1.0, report.AcceptanceRate)
+	assert.Equal(t, 30, report.Delivered)
+	assert.Zero(t, report.Dropped)
+	require.NotNil(t, report.QueueDropped)
+	assert.Zero(t, *report.QueueDropped)
+	assert.Positive(t, report.Latency.Max)
+	assert.GreaterOrEqual(t, report.DeliveryDelay.Max, report.DeliveryDelay.P50)
+}
+
+func TestLoadgenCommandFailingSink(t *testing.T) {
+	t.Setenv("BATCH_SIZE", "100")
+	t.Setenv("BATCH_INTERVAL", "10s")
+	scenario := filepath.Join(t.TempDir(), "scenario.json")
+	require.NoError(t, os.WriteFile(scenario, []byte(`{"steps": [{"status": 503}]}`), 0o600))
+	var stdout, stderr bytes.Buffer
+	code := loadgenCommand([]string{"-rate", "50", "-duration", "200ms", "-invalid", "0", "-drain", "10s", "-scenario", scenario}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	// The receiver gives up on its only batch and keeps running.
+	var report loadReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, 10, report.Accepted)
+	assert.Zero(t, report.Delivered)
+	assert.Equal(t, 10, report.Dropped)
+	require.NotNil(t, report.FailedBatches)
+	assert.Equal(t, int64(1), *report.FailedBatches)
+}
+
+func TestGenerateCountsQueueingInLatency(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	// Ten requests due 10ms apart, served one at a time in 50ms each: the
+	// last is sent about 400ms after it was due.
+	d := &deliveries{sentAt: make(map[int]time.Time)}
+	report := generate(srv.URL, 100, 100*time.Millisecond, 1, 0, d)
+	assert.Equal(t, 10, report.Accepted)
+	assert.GreaterOrEqual(t, report.Latency.Max, 400.0)
+	assert.GreaterOrEqual(t, report.Latency.P50, 200.0)
+}
+
+func TestPercentilesOf(t *testing.T) {
+	var ds []time.Duration
+	for i := 100; i >= 1; i-- {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, percentiles{P50: 50, P90: 90, P99: 99, Max: 100}, percentilesOf(ds))
+	assert.Equal(t, percentiles{}, percentilesOf(nil))
+}
+
+func TestQueueDroppedNeedsAdminToken(t *testing.T) {
+	target, stop, err := startReceiver("http://127.0.0.1:1", "secret")
+	require.NoError(t, err)
+	defer stop()
+
+	varsURL := debugVarsURL(target)
+	assert.True(t, strings.HasSuffix(varsURL, "/admin/debug/vars"), varsURL)
+	_, ok := queueDropped(varsURL, "")
+	assert.False(t, ok)
+	_, ok = queueDropped(varsURL, "secret")
+	assert.True(t, ok)
+}
@@ -3,7 +3,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,11 +17,28 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
+	"benzinga-webhook/internal/apperror"
 	"benzinga-webhook/internal/batcher"
 	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/detect"
+	"benzinga-webhook/internal/event"
+	"benzinga-webhook/internal/geoip"
 	"benzinga-webhook/internal/handler"
+	"benzinga-webhook/internal/ingest"
 	"benzinga-webhook/internal/logger"
+	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/pb"
+	"benzinga-webhook/internal/phone"
+	"benzinga-webhook/internal/replay"
+	"benzinga-webhook/internal/schema"
+	"benzinga-webhook/internal/sink"
+	"benzinga-webhook/internal/tail"
+	"benzinga-webhook/internal/timestamp"
+	"benzinga-webhook/internal/transform"
+	"benzinga-webhook/internal/versioning"
 )
 
 // Run is the testable entrypoint for the application.
@@ -27,13 +48,52 @@ func Run(ctx context.Context) error {
 	log.Info("Starting Benzinga Webhook Receiver")
 
 	r := chi.NewRouter()
-	batch := batcher.New(cfg, log)
-	validate := validator.New()
-	_ = validate.RegisterValidation("phoneformat", handler.PhoneValidator)
+	transforms, err := transform.New(cfg)
+	if err != nil {
+		log.Error("invalid transform configuration", zap.Error(err))
+		return err
+	}
+	hub, err := tail.New(cfg.Tail, []byte(cfg.RedactHMACKey))
+	if err != nil {
+		log.Error("invalid tail configuration", zap.Error(err))
+		return err
+	}
+	sinks, err := sink.FromConfig(cfg, transforms, log)
+	if err != nil {
+		log.Error("invalid sink configuration", zap.Error(err))
+		return err
+	}
+	events, err := buildEvents(cfg, transforms, sinks, hub, log)
+	if err != nil {
+		log.Error("invalid event configuration", zap.Error(err))
+		return err
+	}
 
-	h := handler.New(log, batch, validate)
+	h := handler.New(log, events, cfg)
 	r.Get("/healthz", h.Healthz)
+	r.Get("/schema", h.Schema)
 	r.Post("/log", h.LogPayload)
+	r.Post("/events/{type}", h.Event)
+	r.Get("/events/{type}/schema", h.EventSchema)
+	var ws *handler.WebSocket
+	if cfg.WSMaxConnections > 0 {
+		ws = handler.NewWebSocket(log, events, cfg)
+		r.Get("/ws/log", ws.Log)
+	}
+	replays := replay.NewManager(cfg, sinks, log)
+	if cfg.AdminToken != "" {
+		admin := handler.NewAdmin(log, transforms)
+		rp := handler.NewReplay(log, replays)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(handler.RequireToken(cfg.AdminToken))
+			r.Get("/debug/vars", expvar.Handler().ServeHTTP)
+			r.Post("/transforms/dry-run", admin.DryRun)
+			r.Post("/replay", rp.Start)
+			r.Get("/replay/{id}", rp.Status)
+			r.Delete("/replay/{id}", rp.Cancel)
+		})
+		r.With(handler.RequireToken(cfg.AdminToken)).Get("/tail", handler.NewTail(log, hub).Stream)
+	}
 
 	srv := &http.Server{
 		Addr:         ":8080",
@@ -43,7 +103,23 @@ func Run(ctx context.Context) error {
 		IdleTimeout:  120 * time.Second,
 	}
 
-	go batch.Start()
+	var gs *grpc.Server
+	if cfg.GRPCPort != 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			log.Error("failed to listen for gRPC", zap.Error(err))
+			return err
+		}
+		gs = grpc.NewServer(ingest.Recovery(log)...)
+		pb.RegisterIngestServer(gs, ingest.New(log, events))
+		go func() {
+			if err := gs.Serve(lis); err != nil {
+				log.Fatal("gRPC server error", zap.Error(err))
+			}
+		}()
+	}
+
+	events.Start()
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal("server error", zap.Error(err))
@@ -55,12 +131,156 @@ func Run(ctx context.Context) error {
 	log.Info("Shutting down server")
 	ctxShutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	if gs != nil {
+		stopGRPC(ctxShutdown, gs)
+	}
+	if ws != nil {
+		ws.Close()
+	}
+	// Tail streams never end on their own, so Shutdown would wait for them.
+	hub.Close()
 	_ = srv.Shutdown(ctxShutdown)
-	batch.Stop()
+	replays.Close()
+	events.Stop()
 	return nil
 }
 
+// stopGRPC lets in-flight calls finish until ctx is done, then closes the
+// streams still open.
+func stopGRPC(ctx context.Context, gs *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		gs.Stop()
+	}
+}
+
+// buildEvents registers the built-in log_entry type and the event types
+// declared in CONFIG_FILE, each with its own batcher routed to its sinks and
+// created with opts. Accepted events are published to hub.
+func buildEvents(cfg *config.Config, transforms *transform.Set, sinks []sink.Sink, hub *tail.Hub, log *zap.Logger, opts ...batcher.Option) (*event.Registry, error) {
+	phones, err := phone.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("phone formats: %w", err)
+	}
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", phones.Validate)
+	if err := decimal.RegisterValidations(validate); err != nil {
+		return nil, err
+	}
+	window := timestamp.New(cfg)
+	if err := window.RegisterValidations(validate); err != nil {
+		return nil, err
+	}
+	geo, err := geoip.New(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaFile := cfg.SchemaFile
+	if ec, ok := cfg.Event(event.LogEntry); ok && ec.Schema != "" {
+		schemaFile = ec.Schema
+	}
+	sv, err := schema.Load(schemaFile, phones, window)
+	if err != nil {
+		return nil, fmt.Errorf("event %q: %w", event.LogEntry, err)
+	}
+
+	logEntries, err := newBatcher[model.LogEntry](event.LogEntry, cfg, transforms, log, sinks, opts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Detection.Enabled() {
+		alerts := batcher.New[model.LoginAlert](detect.Event, cfg, log, sink.Named(sinks, cfg.Detection.AlertSink), opts...)
+		logEntries = detect.Wrap(detect.New(cfg.Detection), logEntries, alerts)
+	}
+	logEntries = tail.Wrap(hub, event.LogEntry, logEntries)
+
+	events, _ := event.NewRegistry(event.New(event.Spec[model.LogEntry]{
+		Name:       event.LogEntry,
+		Versions:   versioning.LogEntry,
+		Schema:     sv,
+		SchemaMode: cfg.ValidationMode == config.ValidationModeSchema,
+		Validate:   validate,
+		Prepare: func(entry *model.LogEntry) {
+			entry.Meta.PhoneNumbers.Home = phones.Normalize(entry.Meta.PhoneNumbers.Home)
+			entry.Meta.PhoneNumbers.Mobile = phones.Normalize(entry.Meta.PhoneNumbers.Mobile)
+			entry.Meta.Logins = geo.Logins(timestamp.Logins(entry.Meta.Logins))
+			entry.ReceivedAt = time.Now().UTC().Format(timestamp.Layout)
+		},
+		Batcher: logEntries,
+		Decoders: map[string]event.Decoder[model.LogEntry]{
+			event.MediaProtobuf: pb.UnmarshalLogEntry,
+			event.MediaMsgpack:  event.Msgpack[model.LogEntry],
+		},
+	}))
+
+	for _, ec := range cfg.Events {
+		if ec.Name == event.LogEntry {
+			continue
+		}
+		if ec.Schema == "" {
+			return nil, fmt.Errorf("event %q: schema is required", ec.Name)
+		}
+		sv, err := schema.Load(ec.Schema, phones, window)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", ec.Name, err)
+		}
+		b, err := newBatcher[json.RawMessage](ec.Name, cfg, transforms, log, sinks, opts)
+		if err != nil {
+			return nil, err
+		}
+		err = events.Register(event.New(event.Spec[json.RawMessage]{
+			Name:    ec.Name,
+			Schema:  sv,
+			Batcher: tail.Wrap(hub, ec.Name, b),
+		}))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// newBatcher returns the batcher for the named event type, which runs the
+// transforms configured for the type before batching.
+func newBatcher[T any](name string, cfg *config.Config, transforms *transform.Set, log *zap.Logger, sinks []sink.Sink, opts []batcher.Option) (batcher.Batcher[T], error) {
+	routed := sink.Route(sinks, cfg, name)
+	ec, _ := cfg.Event(name)
+	if len(ec.Transforms) == 0 {
+		return batcher.New[T](name, cfg, log, routed, opts...), nil
+	}
+	p, err := transforms.Pipeline(ec.Transforms...)
+	if err != nil {
+		return nil, fmt.Errorf("event %q: %w", name, err)
+	}
+	return transform.Wrap[T](name, p, batcher.New[json.RawMessage](name, cfg, log, routed, opts...), log), nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "decrypt":
+			os.Exit(decryptCommand(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "rotate-key":
+			os.Exit(rotateKeyCommand(os.Args[2:], os.Stderr))
+		case "replay":
+			os.Exit(replayCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "send":
+			os.Exit(sendCommand(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "loadgen":
+			os.Exit(loadgenCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "mocksink":
+			os.Exit(mocksinkCommand(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 	if err := Run(ctx); err != nil {
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"benzinga-webhook/internal/mocksink"
+)
+
+// mocksinkCommand implements "mocksink [-addr ADDR] [-scenario FILE]". It
+// serves a mocksink.Server, answering batches as the scenario in FILE says and
+// printing a line per request on stdout, until interrupted.
+func mocksinkCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mocksink", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "127.0.0.1:9000", "`address` to listen on")
+	scenarioFile := fs.String("scenario", "", "JSON `file` of the scenario; empty accepts every batch")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var scenario mocksink.Scenario
+	if *scenarioFile != "" {
+		var err error
+		if scenario, err = mocksink.LoadScenario(*scenarioFile); err != nil {
+			fmt.Fprintln(stderr, "mocksink:", err)
+			return 1
+		}
+	}
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(stderr, "mocksink:", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := serveMockSink(ctx, lis, mocksink.New(scenario), stdout, stderr); err != nil {
+		fmt.Fprintln(stderr, "mocksink:", err)
+		return 1
+	}
+	return 0
+}
+
+// serveMockSink serves mock on lis until ctx is done.
+func serveMockSink(ctx context.Context, lis net.Listener, mock *mocksink.Server, stdout, stderr io.Writer) error {
+	var mu sync.Mutex
+	mock.OnRequest = func(r mocksink.Request) {
+		outcome := fmt.Sprint(r.Status)
+		if r.Status == 0 {
+			outcome = "reset"
+		}
+		size := fmt.Sprintf("%d records", len(r.Records))
+		if r.Records == nil {
+			size = fmt.Sprintf("%d bytes", len(r.Body))
+		}
+		if r.Partial {
+			size += " (partial)"
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(stdout, "%s #%d %s %s %s: %s\n", r.ReceivedAt.Format(time.RFC3339Nano), r.Seq, r.Method, r.Path, size, outcome)
+	}
+
+	srv := &http.Server{Handler: mock, ReadHeaderTimeout: 5 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(lis) }()
+	fmt.Fprintf(stderr, "mocksink: listening on %s, inspect at http://%s%s/requests\n", lis.Addr(), lis.Addr(), mocksink.APIPrefix)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Clients holding connections open, such as a receiver waiting on a
+		// delayed step, are cut off.
+		_ = srv.Close()
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
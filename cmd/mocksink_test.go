@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"benzinga-webhook/internal/mocksink"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedBuffer is a bytes.Buffer safe to write from the server's goroutines.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServeMockSink(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	mock := mocksink.New(mocksink.Scenario{Steps: []mocksink.Step{{Status: http.StatusServiceUnavailable}, {}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr lockedBuffer
+	done := make(chan error, 1)
+	go func() { done <- serveMockSink(ctx, lis, mock, &stdout, &stderr) }()
+
+	url := "http://" + lis.Addr().String() + "/batches"
+	for _, want := range []int{http.StatusServiceUnavailable, http.StatusOK} {
+		resp, err := http.Post(url, "application/json", strings.NewReader(`[{"a":1},{"a":2}]`))
+		require.NoError(t, err)
+		// Read to the end so the connection is reused rather than left open.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, want, resp.StatusCode)
+	}
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Len(t, mock.Batches(), 1)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], " #1 POST /batches 2 records: 503")
+	assert.Contains(t, lines[1], " #2 POST /batches 2 records: 200")
+	assert.Contains(t, stderr.String(), "mocksink: listening on "+lis.Addr().String())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/logger"
+	"benzinga-webhook/internal/replay"
+	"benzinga-webhook/internal/sink"
+	"benzinga-webhook/internal/transform"
+)
+
+// replayCommand implements "replay -sink NAME[,NAME] [-archive NAME | -dir
+// DIR] [filters]". It re-delivers archived batches to the named sinks of the
+// configuration, as replay.Run does, reporting progress on stderr and a
+// summary on stdout. It exits 1 if any batch failed.
+func replayCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	sinkNames := fs.String("sink", "", "comma-separated `names` of the sinks to replay to")
+	archive := fs.String("archive", "", "`name` of the file sink to replay, if more than one is configured")
+	dir := fs.String("dir", "", "archive `directory` to replay instead of a configured one")
+	from := fs.String("from", "", "replay batches created at or after this RFC 3339 `time`")
+	to := fs.String("to", "", "replay batches created before this RFC 3339 `time`")
+	typ := fs.String("type", "", "replay batches of this event `type`")
+	userID := fs.String("user-id", "", "replay records of this `user_id`")
+	batchIDs := fs.String("batch-id", "", "comma-separated `IDs` of the batches to replay")
+	rate := fs.Float64("rate", 0, "maximum `records` sent per second, 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := replay.Options{ID: sink.NewBatchID(), Rate: *rate}
+	opts.Type, opts.UserID = *typ, *userID
+	if *batchIDs != "" {
+		opts.BatchIDs = strings.Split(*batchIDs, ",")
+	}
+	var err error
+	if *from != "" {
+		if opts.From, err = time.Parse(time.RFC3339, *from); err != nil {
+			fmt.Fprintln(stderr, "replay: -from:", err)
+			return 2
+		}
+	}
+	if *to != "" {
+		if opts.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			fmt.Fprintln(stderr, "replay: -to:", err)
+			return 2
+		}
+	}
+	var names []string
+	if *sinkNames != "" {
+		names = strings.Split(*sinkNames, ",")
+	}
+
+	cfg := config.Load()
+	if *dir == "" {
+		if *dir, err = replay.Source(cfg, *archive); err != nil {
+			fmt.Fprintln(stderr, "replay:", err)
+			return 1
+		}
+	}
+	// Build only the target sinks: opening a file sink compresses the segment
+	// a running server may be writing to.
+	targetCfg := *cfg
+	targetCfg.Sinks = nil
+	for _, sc := range cfg.Sinks {
+		for _, name := range names {
+			if sc.Name == name && !(sc.Type == "file" && sc.Archive.Dir == *dir) {
+				targetCfg.Sinks = append(targetCfg.Sinks, sc)
+			}
+		}
+	}
+	log := logger.New(cfg.Env)
+	defer func() { _ = log.Sync() }()
+	transforms, err := transform.New(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, "replay:", err)
+		return 1
+	}
+	sinks, err := sink.FromConfig(&targetCfg, transforms, log)
+	if err != nil {
+		fmt.Fprintln(stderr, "replay:", err)
+		return 1
+	}
+	targets, err := replay.Targets(sinks, cfg, *dir, names)
+	if err != nil {
+		fmt.Fprintln(stderr, "replay:", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var reported time.Time
+	p, err := replay.Run(ctx, *dir, targets, opts, func(p replay.Progress) {
+		if time.Since(reported) >= time.Second {
+			reported = time.Now()
+			fmt.Fprintf(stderr, "replay: %d batches, %d records sent, %d failed\n", p.Batches, p.Records, p.Failed)
+		}
+	})
+	fmt.Fprintf(stdout, "replay %s: %d batches, %d records sent, %d failed, %d lines skipped\n",
+		opts.ID, p.Batches, p.Records, p.Failed, p.Skipped)
+	if p.LastError != "" {
+		fmt.Fprintln(stderr, "replay: last failure:", p.LastError)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "replay:", err)
+		return 1
+	}
+	if p.Failed > 0 {
+		return 1
+	}
+	return 0
+}
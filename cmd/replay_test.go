@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/sink"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReplayCommand(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	a, err := sink.NewArchive("archive", config.ArchiveConfig{Dir: dir, SegmentBytes: 1, SegmentAge: time.Hour}, zap.NewNop())
+	require.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, a.Send(context.Background(), sink.Batch{
+			ID:        fmt.Sprintf("b%d", i),
+			Type:      "log_entry",
+			CreatedAt: time.Date(2024, 6, i, 0, 0, 0, 0, time.UTC),
+			Records:   []json.RawMessage{json.RawMessage(fmt.Sprintf(`{"user_id":%d}`, i))},
+		}))
+	}
+
+	var mu sync.Mutex
+	var bodies []string
+	var replays []string
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+		replays = append(replays, r.Header.Get(sink.ReplayHeader))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`sinks:
+  - name: archive
+    type: file
+    archive:
+      dir: %s
+  - name: warehouse
+    type: http
+    url: %s
+`, dir, srv.URL)), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+
+	var stdout, stderr bytes.Buffer
+	code := replayCommand([]string{"-sink", "warehouse", "-from", "2024-06-02T00:00:00Z"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Regexp(t, `^replay [0-9a-f]{32}: 2 batches, 2 records sent, 0 failed, 0 lines skipped\n$`, stdout.String())
+	mu.Lock()
+	assert.Equal(t, []string{`[{"user_id":2}]`, `[{"user_id":3}]`}, bodies)
+	assert.Equal(t, stdout.String()[7:39], replays[0])
+	mu.Unlock()
+
+	stderr.Reset()
+	assert.Equal(t, 1, replayCommand([]string{"-sink", "archive"}, &stdout, &stderr))
+	assert.Equal(t, "replay: sink \"archive\" is the archive being replayed\n", stderr.String())
+	assert.Equal(t, 2, replayCommand([]string{"-from", "yesterday"}, &stdout, &stderr))
+}
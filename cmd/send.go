@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"benzinga-webhook/internal/apperror"
+)
+
+// maxEntry bounds the JSONL lines sendCommand reads.
+const maxEntry = 4 << 20
+
+// entry is one line to send and where it came from.
+type entry struct {
+	seq  int
+	pos  string // file:line
+	body []byte
+}
+
+// outcome is what became of an entry that was not accepted.
+type outcome struct {
+	seq      int
+	pos      string
+	rejected bool
+	message  string
+}
+
+// sendCommand implements "send [-url URL] [-concurrency N] [-rate R]
+// [PATH...]". It POSTs every line of the JSONL files named, of the .jsonl and
+// .ndjson files in the directories named, or of stdin, to a receiver. It
+// prints why each entry was rejected (4xx) or failed (transport errors, 429 and
+// 5xx), then a summary, and exits 1 unless every entry was accepted.
+func sendCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	url := fs.String("url", "http://localhost:8080/log", "`URL` to POST each entry to")
+	concurrency := fs.Int("concurrency", 4, "`number` of requests in flight")
+	rate := fs.Float64("rate", 0, "maximum `requests` per second, 0 for no limit")
+	timeout := fs.Duration("timeout", 10*time.Second, "`timeout` of each request")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *concurrency < 1 || *rate < 0 {
+		fmt.Fprintln(stderr, "send: -concurrency must be positive and -rate not negative")
+		return 2
+	}
+	files, err := inputFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "send:", err)
+		return 1
+	}
+
+	entries := make(chan entry)
+	results := make(chan *outcome)
+	client := &http.Client{Timeout: *timeout}
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range entries {
+				results <- post(client, *url, e)
+			}
+		}()
+	}
+
+	var readErr error
+	go func() {
+		defer func() {
+			close(entries)
+			wg.Wait()
+			close(results)
+		}()
+		start := time.Now()
+		seq := 0
+		readErr = readEntries(files, stdin, func(pos string, body []byte) {
+			if *rate > 0 {
+				time.Sleep(time.Until(start.Add(time.Duration(float64(seq) / *rate * float64(time.Second)))))
+			}
+			entries <- entry{seq: seq, pos: pos, body: body}
+			seq++
+		})
+	}()
+
+	var sent, rejected, failed int
+	var problems []*outcome
+	for o := range results {
+		sent++
+		switch {
+		case o == nil:
+		case o.rejected:
+			rejected++
+			problems = append(problems, o)
+		default:
+			failed++
+			problems = append(problems, o)
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].seq < problems[j].seq })
+	for _, o := range problems {
+		kind := "failed"
+		if o.rejected {
+			kind = "rejected"
+		}
+		fmt.Fprintf(stdout, "%s: %s: %s\n", o.pos, kind, o.message)
+	}
+	fmt.Fprintf(stdout, "sent %d: %d accepted, %d rejected, %d failed\n", sent, sent-rejected-failed, rejected, failed)
+	if readErr != nil {
+		fmt.Fprintln(stderr, "send:", readErr)
+		return 1
+	}
+	if rejected+failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// inputFiles expands directories among paths to the JSONL files they hold, in
+// name order.
+func inputFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		names, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range names {
+			ext := filepath.Ext(d.Name())
+			if d.Type().IsRegular() && (ext == ".jsonl" || ext == ".ndjson") {
+				files = append(files, filepath.Join(path, d.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// readEntries calls fn with every non-blank line of files, or of stdin when
+// there are none.
+func readEntries(files []string, stdin io.Reader, fn func(pos string, body []byte)) error {
+	read := func(name string, r io.Reader) error {
+		lines := bufio.NewScanner(r)
+		lines.Buffer(nil, maxEntry)
+		for n := 1; lines.Scan(); n++ {
+			if line := bytes.TrimSpace(lines.Bytes()); len(line) > 0 {
+				fn(fmt.Sprintf("%s:%d", name, n), bytes.Clone(line))
+			}
+		}
+		if err := lines.Err(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+	if len(files) == 0 {
+		return read("stdin", stdin)
+	}
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = read(path, f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// post sends e and returns nil if it was accepted.
+func post(client *http.Client, url string, e entry) *outcome {
+	o := &outcome{seq: e.seq, pos: e.pos}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(e.body))
+	if err != nil {
+		o.message = err.Error()
+		return o
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	o.rejected = resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests
+	o.message = fmt.Sprintf("%d %s", resp.StatusCode, describe(body))
+	return o
+}
+
+// describe renders an error response of the receiver: a problem document, or
+// the legacy list of {field: message} maps or {"error": message}.
+func describe(body []byte) string {
+	var p apperror.Problem
+	if err := json.Unmarshal(body, &p); err == nil && p.Title != "" {
+		parts := []string{p.Title}
+		if p.Detail != "" {
+			parts[0] += ": " + p.Detail
+		}
+		for _, v := range p.Errors {
+			parts = append(parts, strings.TrimSpace(v.Pointer+" "+v.Message))
+		}
+		return strings.Join(parts, "; ")
+	}
+	var legacy []map[string]string
+	if err := json.Unmarshal(body, &legacy); err == nil {
+		var parts []string
+		for _, fields := range legacy {
+			for field, msg := range fields {
+				parts = append(parts, field+" "+msg)
+			}
+		}
+		return strings.Join(parts, "; ")
+	}
+	var single struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &single); err == nil && single.Error != "" {
+		return single.Error
+	}
+	if text := strings.TrimSpace(string(body)); text != "" {
+		return text
+	}
+	return "empty response"
+}
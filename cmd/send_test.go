@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"benzinga-webhook/internal/apperror"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendCommand(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var entry struct {
+			UserID int `json:"user_id"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &entry))
+		switch entry.UserID {
+		case 2:
+			w.Header().Set("Content-Type", apperror.ContentTypeProblem)
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(apperror.ViolationsProblem([]apperror.Violation{
+				{Code: apperror.CodeTooShort, Pointer: "/title", Message: "must be at least 3 characters long"},
+			}, "/log", nil))
+		case 3:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`[{"user_id":"must be 1 or greater"}]`))
+		case 4:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.jsonl"), []byte("{\"user_id\":1}\n\n{\"user_id\":2}\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.ndjson"), []byte("{\"user_id\":3}\n{\"user_id\":4}\n{\"user_id\":5}\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an entry\n"), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	code := sendCommand([]string{"-url", srv.URL, "-concurrency", "3", "-rate", "1000", dir}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Equal(t, int32(5), atomic.LoadInt32(&requests))
+	a, b := filepath.Join(dir, "a.jsonl"), filepath.Join(dir, "b.ndjson")
+	assert.Equal(t, strings.Join([]string{
+		a + ":3: rejected: 400 Request validation failed: One or more fields failed validation.; /title must be at least 3 characters long",
+		b + ":1: rejected: 400 user_id must be 1 or greater",
+		b + ":2: failed: 503 empty response",
+		"sent 5: 2 accepted, 2 rejected, 1 failed",
+	}, "\n")+"\n", stdout.String())
+
+	stdout.Reset()
+	code = sendCommand([]string{"-url", srv.URL}, strings.NewReader("{\"user_id\":1}\n"), &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "sent 1: 1 accepted, 0 rejected, 0 failed\n", stdout.String())
+}
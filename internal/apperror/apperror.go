@@ -3,38 +3,49 @@ package apperror
 
 import (
 	"errors"
-	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
 
-var (
-	errRequired            = errors.New("is required")
-	errMustBePositive      = errors.New("must be a positive number")
-	errMustBeAtLeast3Chars = errors.New("must be at least 3 characters long")
-	errInvalidDateTime     = errors.New("must be a valid datetime in RFC3339 format")
-	errInvalidIP           = errors.New("must be a valid IP address")
-	errInvalidPhoneFormat  = errors.New("must match format 555-1212-123")
-)
+// tagMessages is the English message catalog for each validation tag. "{0}" is
+// replaced by the tag parameter.
+var tagMessages = map[string]string{
+	"required":     "is required",
+	"gt":           "must be greater than {0}",
+	"gte":          "must be greater than or equal to {0}",
+	"min":          "must be at least {0} characters long",
+	"datetime":     "must be a valid datetime in RFC3339 format",
+	"ip":           "must be a valid IP address",
+	"phoneformat":  "must be a phone number in an accepted format",
+	"maxscale":     "must have at most {0} decimal places",
+	"maxprecision": "must have at most {0} digits",
+	"iso4217":      "must be an ISO 4217 currency code",
+	"maxfuture":    "is too far in the future",
+	"maxage":       "is too far in the past",
+	"type":         "must be of type {0}",
+	"unknown":      "is not an allowed field",
+}
+
+// fieldMessages overrides tagMessages for a single field. Keys are the struct
+// namespace without slice indices followed by the tag.
+var fieldMessages = map[string]string{
+	"LogEntry.UserID.gte": "must be a positive number",
+	"LogEntry.Total.gt":   "must be a positive number",
+}
+
+// problemMessages is the English catalog for the title and detail of
+// validation problem documents, so that they follow the locale of the
+// violations they hold.
+var problemMessages = map[string]string{
+	"problem.validation.title":  "Request validation failed",
+	"problem.validation.detail": "One or more fields failed validation.",
+}
 
-var customErrors = map[string]error{
-	"LogEntry.UserID.required":                      errRequired,
-	"LogEntry.UserID.gte":                           errMustBePositive,
-	"LogEntry.Total.required":                       errRequired,
-	"LogEntry.Total.gt":                             errMustBePositive,
-	"LogEntry.Title.required":                       errRequired,
-	"LogEntry.Title.min":                            errMustBeAtLeast3Chars,
-	"LogEntry.Meta.required":                        errRequired,
-	"LogEntry.Logins.required":                      errRequired,
-	"LogEntry.Logins.datetime":                      errInvalidDateTime,
-	"LogEntry.Logins.ip":                            errInvalidIP,
-	"LogEntry.Meta.PhoneNumbers.Home.required":      errRequired,
-	"LogEntry.Meta.PhoneNumbers.Home.phoneformat":   errInvalidPhoneFormat,
-	"LogEntry.Meta.PhoneNumbers.Mobile.required":    errRequired,
-	"LogEntry.Meta.PhoneNumbers.Mobile.phoneformat": errInvalidPhoneFormat,
-}
-
-// CustomValidationError converts validator errors and JSON decoding errors into a standardized format.
+// CustomValidationError converts validator errors into the legacy list-of-maps format
+// keyed by Go field name. New clients should use ValidationProblem instead.
 func CustomValidationError(err error) []map[string]string {
 	errList := make([]map[string]string, 0)
 
@@ -45,16 +56,62 @@ func CustomValidationError(err error) []map[string]string {
 	switch {
 	case errors.As(err, &validationErr):
 		for _, e := range validationErr {
-			field := e.StructNamespace()
-			key := field + "." + e.Tag()
+			errList = append(errList, map[string]string{e.StructField(): localized(e, nil)})
+		}
+	}
+	return errList
+}
+
+func lookup(field, tag string) (string, bool) {
+	if msg, ok := fieldMessages[field+"."+tag]; ok {
+		return msg, true
+	}
+	msg, ok := tagMessages[tag]
+	return msg, ok
+}
+
+// FieldTags lists every validation rule declared on t and its nested structs as
+// "<namespace>.<tag>" keys, e.g. "LogEntry.Meta.Logins.IP.ip".
+func FieldTags(t reflect.Type) []string {
+	var keys []string
+	collectTags(t, t.Name(), &keys)
+	sort.Strings(keys)
+	return keys
+}
 
-			errMsg := fmt.Sprintf("%s is invalid", field)
-			if v, ok := customErrors[key]; ok {
-				errMsg = v.Error()
+func collectTags(t reflect.Type, namespace string, keys *[]string) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		ns := namespace + "." + f.Name
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			tag := strings.SplitN(rule, "=", 2)[0]
+			switch tag {
+			case "", "-", "dive", "omitempty":
+				continue
 			}
+			*keys = append(*keys, ns+"."+tag)
+		}
+		collectTags(f.Type, ns, keys)
+	}
+}
 
-			errList = append(errList, map[string]string{e.Field(): errMsg})
+// MissingMessages returns the FieldTags keys of t that have no catalog message.
+func MissingMessages(t reflect.Type) []string {
+	var missing []string
+	for _, key := range FieldTags(t) {
+		i := strings.LastIndex(key, ".")
+		if _, ok := lookup(key[:i], key[i+1:]); !ok {
+			missing = append(missing, key)
 		}
 	}
-	return errList
+	return missing
 }
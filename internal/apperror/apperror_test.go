@@ -0,0 +1,134 @@
+package apperror
+
+import (
+	"reflect"
+	"testing"
+
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/timestamp"
+
+	"github.com/go-playground/locales/it"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogCoversModelTags(t *testing.T) {
+	keys := FieldTags(reflect.TypeOf(model.LogEntry{}))
+	assert.Contains(t, keys, "LogEntry.Meta.Logins.IP.ip")
+	assert.Contains(t, keys, "LogEntry.Meta.Logins.Time.datetime")
+	assert.Contains(t, keys, "LogEntry.Meta.PhoneNumbers.Mobile.phoneformat")
+
+	assert.Empty(t, MissingMessages(reflect.TypeOf(model.LogEntry{})),
+		"every validate tag in model must have a message in the apperror catalog")
+}
+
+func TestMissingMessagesDetectsUnknownTag(t *testing.T) {
+	type Sample struct {
+		Name string `validate:"required,alphanum"`
+	}
+	assert.Equal(t, []string{"Sample.Name.alphanum"}, MissingMessages(reflect.TypeOf(Sample{})))
+}
+
+func TestViolationsPreserveIndices(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", func(validator.FieldLevel) bool { return true })
+	_ = decimal.RegisterValidations(validate)
+	_ = (&timestamp.Policy{}).RegisterValidations(validate)
+
+	entry := model.LogEntry{
+		UserID: 1,
+		Total:  decimal.MustParse("1"),
+		Title:  "indices",
+		Meta: model.Meta{
+			Logins: []model.Login{
+				{Time: "2020-08-08T01:52:50Z", IP: "127.0.0.1"},
+				{Time: "yesterday", IP: "127.0.0.1"},
+			},
+			PhoneNumbers: model.PhoneNumbers{Home: "1", Mobile: "2"},
+		},
+	}
+
+	violations := Violations(validate.Struct(entry), nil)
+	assert.Equal(t, []Violation{{
+		Code:    CodeInvalidDateTime,
+		Pointer: "/meta/logins/1/time",
+		Value:   "yesterday",
+		Message: "must be a valid datetime in RFC3339 format",
+	}}, violations)
+	assert.Equal(t, []map[string]string{{"Time": "must be a valid datetime in RFC3339 format"}},
+		CustomValidationError(validate.Struct(entry)))
+}
+
+func TestBundledCatalogsAreComplete(t *testing.T) {
+	for name, catalog := range map[string]Catalog{"es": spanish, "de": german, "fr": french} {
+		for key := range tagMessages {
+			assert.Contains(t, catalog, key, "%s catalog is missing tag %q", name, key)
+		}
+		for key := range fieldMessages {
+			assert.Contains(t, catalog, key, "%s catalog is missing field message %q", name, key)
+		}
+		for key := range problemMessages {
+			assert.Contains(t, catalog, key, "%s catalog is missing problem message %q", name, key)
+		}
+	}
+}
+
+func TestLocalizedViolations(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", func(validator.FieldLevel) bool { return false })
+	_ = decimal.RegisterValidations(validate)
+	_ = (&timestamp.Policy{}).RegisterValidations(validate)
+
+	entry := model.LogEntry{
+		UserID: 1,
+		Total:  decimal.MustParse("1"),
+		Title:  "ok",
+		Meta: model.Meta{
+			Logins:       []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "127.0.0.1"}},
+			PhoneNumbers: model.PhoneNumbers{Home: "1", Mobile: "2"},
+		},
+	}
+	err := validate.Struct(entry)
+
+	tests := []struct {
+		acceptLanguage string
+		locale         string
+		title          string
+		phone          string
+	}{
+		{"", "en", "must be at least 3 characters long", "must be a phone number in an accepted format"},
+		{"es-MX,es;q=0.9", "es", "debe tener al menos 3 caracteres", "debe ser un número de teléfono en un formato aceptado"},
+		{"ja, de;q=0.5, fr;q=0.7", "fr", "doit contenir au moins 3 caractères", "doit être un numéro de téléphone dans un format accepté"},
+		{"de-CH", "de", "muss mindestens 3 Zeichen lang sein", "muss eine Telefonnummer in einem zulässigen Format sein"},
+		{"ja, fr;q=0", "en", "must be at least 3 characters long", "must be a phone number in an accepted format"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.acceptLanguage, func(t *testing.T) {
+			trans := TranslatorFor(tc.acceptLanguage)
+			assert.Equal(t, tc.locale, trans.Locale())
+
+			violations := Violations(err, trans)
+			assert.Len(t, violations, 3)
+			assert.Equal(t, tc.title, violations[0].Message)
+			assert.Equal(t, tc.phone, violations[1].Message)
+		})
+	}
+}
+
+func TestRegisterCatalogFallsBackToEnglish(t *testing.T) {
+	assert.NoError(t, RegisterCatalog(it.New(), Catalog{"required": "è obbligatorio"}))
+
+	trans := TranslatorFor("it-IT")
+	assert.Equal(t, "it", trans.Locale())
+
+	msg, ok := translate(trans, "LogEntry.Title", "required", "")
+	assert.True(t, ok)
+	assert.Equal(t, "è obbligatorio", msg)
+
+	msg, ok = translate(trans, "LogEntry.Meta.Logins.IP", "ip", "")
+	assert.True(t, ok)
+	assert.Equal(t, "must be a valid IP address", msg)
+}
@@ -0,0 +1,69 @@
+package apperror
+
+// Bundled translations of the English catalog in apperror.go.
+
+var spanish = Catalog{
+	"required":            "es obligatorio",
+	"gt":                  "debe ser mayor que {0}",
+	"gte":                 "debe ser mayor o igual que {0}",
+	"min":                 "debe tener al menos {0} caracteres",
+	"datetime":            "debe ser una fecha y hora válida en formato RFC3339",
+	"ip":                  "debe ser una dirección IP válida",
+	"phoneformat":         "debe ser un número de teléfono en un formato aceptado",
+	"maxscale":            "debe tener como máximo {0} decimales",
+	"maxprecision":        "debe tener como máximo {0} dígitos",
+	"iso4217":             "debe ser un código de moneda ISO 4217",
+	"maxfuture":           "está demasiado lejos en el futuro",
+	"maxage":              "está demasiado lejos en el pasado",
+	"type":                "debe ser de tipo {0}",
+	"unknown":             "no es un campo permitido",
+	"LogEntry.UserID.gte": "debe ser un número positivo",
+	"LogEntry.Total.gt":   "debe ser un número positivo",
+
+	"problem.validation.title":  "La validación de la solicitud falló",
+	"problem.validation.detail": "Uno o más campos no superaron la validación.",
+}
+
+var german = Catalog{
+	"required":            "ist erforderlich",
+	"gt":                  "muss größer als {0} sein",
+	"gte":                 "muss größer oder gleich {0} sein",
+	"min":                 "muss mindestens {0} Zeichen lang sein",
+	"datetime":            "muss ein gültiger Zeitstempel im RFC3339-Format sein",
+	"ip":                  "muss eine gültige IP-Adresse sein",
+	"phoneformat":         "muss eine Telefonnummer in einem zulässigen Format sein",
+	"maxscale":            "darf höchstens {0} Nachkommastellen haben",
+	"maxprecision":        "darf höchstens {0} Ziffern haben",
+	"iso4217":             "muss ein ISO-4217-Währungscode sein",
+	"maxfuture":           "liegt zu weit in der Zukunft",
+	"maxage":              "liegt zu weit in der Vergangenheit",
+	"type":                "muss vom Typ {0} sein",
+	"unknown":             "ist kein zulässiges Feld",
+	"LogEntry.UserID.gte": "muss eine positive Zahl sein",
+	"LogEntry.Total.gt":   "muss eine positive Zahl sein",
+
+	"problem.validation.title":  "Validierung der Anfrage fehlgeschlagen",
+	"problem.validation.detail": "Mindestens ein Feld hat die Validierung nicht bestanden.",
+}
+
+var french = Catalog{
+	"required":            "est obligatoire",
+	"gt":                  "doit être supérieur à {0}",
+	"gte":                 "doit être supérieur ou égal à {0}",
+	"min":                 "doit contenir au moins {0} caractères",
+	"datetime":            "doit être une date et heure valide au format RFC3339",
+	"ip":                  "doit être une adresse IP valide",
+	"phoneformat":         "doit être un numéro de téléphone dans un format accepté",
+	"maxscale":            "doit avoir au plus {0} décimales",
+	"maxprecision":        "doit avoir au plus {0} chiffres",
+	"iso4217":             "doit être un code de devise ISO 4217",
+	"maxfuture":           "est trop loin dans le futur",
+	"maxage":              "est trop loin dans le passé",
+	"type":                "doit être de type {0}",
+	"unknown":             "n'est pas un champ autorisé",
+	"LogEntry.UserID.gte": "doit être un nombre positif",
+	"LogEntry.Total.gt":   "doit être un nombre positif",
+
+	"problem.validation.title":  "La validation de la requête a échoué",
+	"problem.validation.detail": "Un ou plusieurs champs n'ont pas passé la validation.",
+}
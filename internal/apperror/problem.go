@@ -0,0 +1,357 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// ContentTypeProblem is the media type for RFC 7807 problem details.
+const ContentTypeProblem = "application/problem+json"
+
+// Problem types returned by the API.
+const (
+	TypeValidation         = "urn:benzinga-webhook:problem:validation-error"
+	TypeInvalidPayload     = "urn:benzinga-webhook:problem:invalid-payload"
+	TypeUnsupportedVersion = "urn:benzinga-webhook:problem:unsupported-version"
+	TypeUnknownEvent       = "urn:benzinga-webhook:problem:unknown-event-type"
+	TypeUnauthorized       = "urn:benzinga-webhook:problem:unauthorized"
+	TypeTransformFailed    = "urn:benzinga-webhook:problem:transform-failed"
+	TypeUnsupportedMedia   = "urn:benzinga-webhook:problem:unsupported-media-type"
+	TypeTooManyConnections = "urn:benzinga-webhook:problem:too-many-connections"
+	TypeInvalidParameter   = "urn:benzinga-webhook:problem:invalid-parameter"
+	TypeInvalidReplay      = "urn:benzinga-webhook:problem:invalid-replay"
+)
+
+// Stable, machine-readable violation codes. Clients may switch on these values.
+const (
+	CodeRequired        = "required"
+	CodeTooSmall        = "too_small"
+	CodeTooShort        = "too_short"
+	CodeInvalidDateTime = "invalid_datetime"
+	CodeInvalidIP       = "invalid_ip"
+	CodeInvalidPhone    = "invalid_phone"
+	CodeTooPrecise      = "too_precise"
+	CodeInvalidCurrency = "invalid_currency"
+	CodeInFuture        = "in_future"
+	CodeTooOld          = "too_old"
+	CodeInvalid         = "invalid"
+	CodeMalformedJSON   = "malformed_json"
+	CodeMalformedBody   = "malformed_body"
+	CodeInvalidType     = "invalid_type"
+	CodeNotAllowed      = "not_allowed"
+)
+
+var tagCodes = map[string]string{
+	"required":     CodeRequired,
+	"gt":           CodeTooSmall,
+	"gte":          CodeTooSmall,
+	"min":          CodeTooShort,
+	"datetime":     CodeInvalidDateTime,
+	"ip":           CodeInvalidIP,
+	"phoneformat":  CodeInvalidPhone,
+	"maxscale":     CodeTooPrecise,
+	"maxprecision": CodeTooPrecise,
+	"iso4217":      CodeInvalidCurrency,
+	"maxfuture":    CodeInFuture,
+	"maxage":       CodeTooOld,
+}
+
+// sensitiveFields lists struct namespaces (without slice indices) whose rejected
+// values must never be echoed back to the caller.
+var sensitiveFields = map[string]bool{
+	"LogEntry.Meta.Logins.IP":           true,
+	"LogEntry.Meta.PhoneNumbers.Home":   true,
+	"LogEntry.Meta.PhoneNumbers.Mobile": true,
+}
+
+// sensitivePointers lists the same fields as JSON pointers without array indices.
+var sensitivePointers = map[string]bool{
+	"/meta/logins/ip":            true,
+	"/meta/phone_numbers/home":   true,
+	"/meta/phone_numbers/mobile": true,
+}
+
+var (
+	indexPattern        = regexp.MustCompile(`\[(\d+)\]`)
+	pointerIndexPattern = regexp.MustCompile(`/\d+(/|$)`)
+)
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Errors   []Violation `json:"errors,omitempty"`
+	// SupportedVersions lists the accepted schema versions on TypeUnsupportedVersion problems.
+	SupportedVersions []string `json:"supported_versions,omitempty"`
+	// SupportedTypes lists the accepted event types on TypeUnknownEvent problems.
+	SupportedTypes []string `json:"supported_types,omitempty"`
+}
+
+// Violation describes a single rejected field.
+type Violation struct {
+	Code    string `json:"code"`
+	Pointer string `json:"pointer"`
+	Value   any    `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// JSONTagName reports the JSON name of a struct field. Register it with
+// validator.RegisterTagNameFunc so violation pointers use JSON field names.
+func JSONTagName(fld reflect.StructField) string {
+	name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return fld.Name
+	}
+	return name
+}
+
+// ValidationProblem builds a problem document from validator errors with
+// messages rendered by trans. A nil trans renders English messages.
+func ValidationProblem(err error, instance string, trans ut.Translator) *Problem {
+	return ViolationsProblem(Violations(err, trans), instance, trans)
+}
+
+// ViolationsProblem builds a validation problem document from violations
+// produced by any validator, such as a JSON Schema, with its title and detail
+// rendered by trans. A nil trans renders them in English.
+func ViolationsProblem(violations []Violation, instance string, trans ut.Translator) *Problem {
+	return &Problem{
+		Type:     TypeValidation,
+		Title:    text(trans, "problem.validation.title"),
+		Status:   http.StatusBadRequest,
+		Detail:   text(trans, "problem.validation.detail"),
+		Instance: instance,
+		Errors:   violations,
+	}
+}
+
+// LegacyViolations renders violations in the legacy list-of-maps format, keyed
+// by the last segment of each pointer.
+func LegacyViolations(violations []Violation) []map[string]string {
+	errList := make([]map[string]string, 0, len(violations))
+	for _, v := range violations {
+		errList = append(errList, map[string]string{v.Pointer[strings.LastIndex(v.Pointer, "/")+1:]: v.Message})
+	}
+	return errList
+}
+
+// DecodeProblem builds a problem document for a request body that could not be decoded.
+func DecodeProblem(err error, instance string) *Problem {
+	p := &Problem{
+		Type:     TypeInvalidPayload,
+		Title:    "Invalid request payload",
+		Status:   http.StatusBadRequest,
+		Detail:   "The request body is not a valid JSON document.",
+		Instance: instance,
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		p.Detail = "The request body contains a value of the wrong type."
+		p.Errors = []Violation{{
+			Code:    CodeInvalidType,
+			Pointer: "/" + strings.ReplaceAll(typeErr.Field, ".", "/"),
+			Message: "must be of type " + typeErr.Type.String(),
+		}}
+		return p
+	}
+
+	p.Errors = []Violation{{Code: CodeMalformedJSON, Pointer: "", Message: "is not valid JSON"}}
+	return p
+}
+
+// BodyProblem builds a problem document for a request body that could not be
+// decoded as mediaType, a binary format.
+func BodyProblem(mediaType, instance string) *Problem {
+	return &Problem{
+		Type:     TypeInvalidPayload,
+		Title:    "Invalid request payload",
+		Status:   http.StatusBadRequest,
+		Detail:   fmt.Sprintf("The request body is not a valid %s document.", mediaType),
+		Instance: instance,
+		Errors:   []Violation{{Code: CodeMalformedBody, Pointer: "", Message: "is not valid " + mediaType}},
+	}
+}
+
+// UnsupportedMediaProblem builds a problem document for a request body in a
+// media type the event type cannot be decoded from.
+func UnsupportedMediaProblem(mediaType, instance string) *Problem {
+	return &Problem{
+		Type:     TypeUnsupportedMedia,
+		Title:    "Unsupported media type",
+		Status:   http.StatusUnsupportedMediaType,
+		Detail:   fmt.Sprintf("this event type does not accept %s bodies", mediaType),
+		Instance: instance,
+	}
+}
+
+// ConnectionLimitProblem builds a problem document for a streaming connection
+// refused because limit connections are already open.
+func ConnectionLimitProblem(limit int, instance string) *Problem {
+	return &Problem{
+		Type:     TypeTooManyConnections,
+		Title:    "Too many connections",
+		Status:   http.StatusServiceUnavailable,
+		Detail:   fmt.Sprintf("at most %d connections may be open at once", limit),
+		Instance: instance,
+	}
+}
+
+// ParameterProblem builds a problem document for a query parameter that could
+// not be parsed.
+func ParameterProblem(name, detail, instance string) *Problem {
+	return &Problem{
+		Type:     TypeInvalidParameter,
+		Title:    "Invalid query parameter",
+		Status:   http.StatusBadRequest,
+		Detail:   fmt.Sprintf("%s %s", name, detail),
+		Instance: instance,
+	}
+}
+
+// VersionProblem builds a problem document for a payload whose schema version
+// is unknown or could not be upgraded to the current version.
+func VersionProblem(err error, instance string, supported []string) *Problem {
+	return &Problem{
+		Type:              TypeUnsupportedVersion,
+		Title:             "Unsupported schema version",
+		Status:            http.StatusBadRequest,
+		Detail:            err.Error(),
+		Instance:          instance,
+		SupportedVersions: supported,
+	}
+}
+
+// UnknownEventProblem builds a problem document for a request naming an event
+// type that is not registered.
+func UnknownEventProblem(name, instance string, supported []string) *Problem {
+	return &Problem{
+		Type:           TypeUnknownEvent,
+		Title:          "Unknown event type",
+		Status:         http.StatusNotFound,
+		Detail:         fmt.Sprintf("event type %q is not registered", name),
+		Instance:       instance,
+		SupportedTypes: supported,
+	}
+}
+
+// UnauthorizedProblem builds a problem document for a request to an admin
+// endpoint without a valid bearer token.
+func UnauthorizedProblem(instance string) *Problem {
+	return &Problem{
+		Type:     TypeUnauthorized,
+		Title:    "Unauthorized",
+		Status:   http.StatusUnauthorized,
+		Detail:   "A valid bearer token is required.",
+		Instance: instance,
+	}
+}
+
+// TransformProblem builds a problem document for a transform that could not be
+// compiled or failed on the sample payload of a dry run.
+func TransformProblem(err error, instance string) *Problem {
+	return &Problem{
+		Type:     TypeTransformFailed,
+		Title:    "Transform failed",
+		Status:   http.StatusUnprocessableEntity,
+		Detail:   err.Error(),
+		Instance: instance,
+	}
+}
+
+// ReplayProblem builds a problem document for a replay that cannot be
+// started, such as one naming an unknown sink.
+func ReplayProblem(err error, instance string) *Problem {
+	return &Problem{
+		Type:     TypeInvalidReplay,
+		Title:    "Invalid replay",
+		Status:   http.StatusUnprocessableEntity,
+		Detail:   err.Error(),
+		Instance: instance,
+	}
+}
+
+// Summary renders violations as "<pointer>: <code>" strings that are safe to
+// log: rejected values and messages are left out.
+func Summary(violations []Violation) []string {
+	out := make([]string, len(violations))
+	for i, v := range violations {
+		out[i] = v.Pointer + ": " + v.Code
+	}
+	return out
+}
+
+// Violations converts validator errors into violations with messages rendered
+// by trans. Errors of any other type yield an empty list.
+func Violations(err error, trans ut.Translator) []Violation {
+	violations := make([]Violation, 0)
+
+	var validationErr validator.ValidationErrors
+	if !errors.As(err, &validationErr) {
+		return violations
+	}
+
+	for _, e := range validationErr {
+		field := e.StructNamespace()
+		v := Violation{
+			Code:    CodeInvalid,
+			Pointer: pointer(e.Namespace()),
+			Message: localized(e, trans),
+		}
+		if code, ok := tagCodes[e.Tag()]; ok {
+			v.Code = code
+		}
+		if !sensitiveFields[indexPattern.ReplaceAllString(field, "")] {
+			v.Value = safeValue(e.Value())
+		}
+		violations = append(violations, v)
+	}
+	return violations
+}
+
+// pointer converts a validator namespace such as "LogEntry.meta.logins[0].ip"
+// into a JSON pointer such as "/meta/logins/0/ip".
+func pointer(namespace string) string {
+	namespace = indexPattern.ReplaceAllString(namespace, ".$1")
+	parts := strings.Split(namespace, ".")
+	if len(parts) <= 1 {
+		return ""
+	}
+	for i, p := range parts {
+		parts[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(p)
+	}
+	return "/" + strings.Join(parts[1:], "/")
+}
+
+// RejectedValue returns the value to report for the field at pointer: v when it
+// is a scalar that is not personal data, nil otherwise.
+func RejectedValue(pointer string, v any) any {
+	if sensitivePointers[strings.TrimSuffix(pointerIndexPattern.ReplaceAllString(pointer, "/"), "/")] {
+		return nil
+	}
+	return safeValue(v)
+}
+
+// safeValue returns v when it is a scalar that can be echoed back verbatim.
+func safeValue(v any) any {
+	switch v.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return v
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,161 @@
+package apperror
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// Catalog maps message keys to text for one locale. Keys are either a bare
+// validation tag ("required") or a field-qualified tag ("LogEntry.UserID.gte")
+// using the struct namespace without slice indices. "{0}" in the text is
+// replaced by the tag parameter.
+type Catalog map[string]string
+
+var uni = ut.New(en.New())
+
+func init() {
+	english := Catalog{}
+	for k, v := range tagMessages {
+		english[k] = v
+	}
+	for k, v := range fieldMessages {
+		english[k] = v
+	}
+	for k, v := range problemMessages {
+		english[k] = v
+	}
+	mustRegister(en.New(), english)
+	mustRegister(es.New(), spanish)
+	mustRegister(de.New(), german)
+	mustRegister(fr.New(), french)
+}
+
+func mustRegister(l locales.Translator, c Catalog) {
+	if err := RegisterCatalog(l, c); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterCatalog adds or replaces the messages for a locale. Keys missing from
+// the catalog fall back to English. Catalogs must be registered at startup; it
+// is not safe to call RegisterCatalog while requests are being served.
+func RegisterCatalog(l locales.Translator, c Catalog) error {
+	trans, found := uni.GetTranslator(l.Locale())
+	if !found {
+		if err := uni.AddTranslator(l, true); err != nil {
+			return err
+		}
+		trans, _ = uni.GetTranslator(l.Locale())
+	}
+	for key, text := range c {
+		if err := trans.Add(key, text, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TranslatorFor returns the registered translator that best matches an
+// Accept-Language header value, or the English translator.
+func TranslatorFor(acceptLanguage string) ut.Translator {
+	trans, _ := uni.FindTranslator(parseAcceptLanguage(acceptLanguage)...)
+	return trans
+}
+
+// translate renders the message for a field/tag pair, preferring the caller's
+// locale and falling back to English.
+func translate(trans ut.Translator, field, tag, param string) (string, bool) {
+	for _, t := range []ut.Translator{trans, uni.GetFallback()} {
+		if t == nil {
+			continue
+		}
+		for _, key := range []string{field + "." + tag, tag} {
+			msg, err := t.T(key, param)
+			if err == nil {
+				return msg, true
+			}
+			if !errors.Is(err, ut.ErrUnknowTranslation) {
+				return "", false
+			}
+		}
+	}
+	return "", false
+}
+
+// Message renders the catalog message for a validation tag in the locale of
+// trans, falling back to English and then to a generic message.
+func Message(trans ut.Translator, tag, param string) string {
+	if msg, ok := translate(trans, "", tag, param); ok {
+		return msg
+	}
+	return "is invalid"
+}
+
+// text renders the catalog entry key in the locale of trans, falling back to
+// English.
+func text(trans ut.Translator, key string) string {
+	if msg, ok := translate(trans, "", key, ""); ok {
+		return msg
+	}
+	return problemMessages[key]
+}
+
+// localized resolves the message for a validation error in the given locale.
+func localized(e validator.FieldError, trans ut.Translator) string {
+	field := indexPattern.ReplaceAllString(e.StructNamespace(), "")
+	if msg, ok := translate(trans, field, e.Tag(), e.Param()); ok {
+		return msg
+	}
+	return e.StructNamespace() + " is invalid"
+}
+
+// parseAcceptLanguage returns the locales named in an Accept-Language header
+// ordered by preference. Region-qualified tags are followed by their base
+// language, e.g. "de-CH" yields "de_CH" and "de".
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var result []string
+	for _, t := range tags {
+		locale := strings.ReplaceAll(t.tag, "-", "_")
+		result = append(result, locale)
+		if base, _, ok := strings.Cut(locale, "_"); ok {
+			result = append(result, base)
+		}
+	}
+	return result
+}
@@ -3,58 +3,161 @@ package batcher
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
-	"net/http"
+	"expvar"
 	"os"
 	"time"
 
 	"benzinga-webhook/internal/config"
-	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/sink"
 
 	"go.uber.org/zap"
 )
 
 var exitFunc = os.Exit
 
+// Dropped counts, by event type, entries dropped because the queue was full.
+// It is published at /admin/debug/vars as "batcher_dropped".
+var Dropped = expvar.NewMap("batcher_dropped")
+
 // Batcher defines the interface for adding entries and controlling lifecycle.
-type Batcher interface {
-	Add(entry model.LogEntry)
+type Batcher[T any] interface {
+	Add(entry T)
 	Start()
 	Stop()
 }
 
-// batcher holds buffered log entries and manages periodic flushing.
-type batcher struct {
+// Loader is implemented by batchers that can report how full their queue is,
+// so that producers can slow down before entries are dropped.
+type Loader interface {
+	// Load returns the fraction of the queue in use, from 0 to 1.
+	Load() float64
+}
+
+// LoadOf returns the load of b, or 0 when b does not implement Loader.
+func LoadOf(b any) float64 {
+	if l, ok := b.(Loader); ok {
+		return l.Load()
+	}
+	return 0
+}
+
+// Option configures a Batcher created by New.
+type Option func(*options)
+
+type options struct {
+	onFailure func(sinkName string, b sink.Batch, err error)
+}
+
+// OnFailure sets the function called with a batch a sink did not accept after
+// its retries, and the sink's error. The batcher then moves on to the next
+// sink. By default the process exits with status 1.
+func OnFailure(fn func(sinkName string, b sink.Batch, err error)) Option {
+	return func(o *options) { o.onFailure = fn }
+}
+
+// batcher holds buffered entries and manages periodic flushing.
+type batcher[T any] struct {
+	name    string
 	log     *zap.Logger
 	cfg     *config.Config
-	entries chan model.LogEntry
+	sinks   []sink.Sink
+	filters []sink.Filter // by sink index, nil for sinks without one
+	options
+	entries chan queued
 	quit    chan struct{}
 }
 
-// New initializes a new Batcher instance.
-func New(cfg *config.Config, logger *zap.Logger) Batcher {
-	return &batcher{
+// queued is an entry encoded as JSON, waiting to be flushed.
+type queued struct {
+	record json.RawMessage
+	// skip reports, by sink index, whether the sink's filter dropped the
+	// record. It is nil when no sink has a filter.
+	skip []bool
+}
+
+// New initializes a new Batcher instance for events of the named type. Batches
+// are delivered to every sink; when none are given they are posted to
+// cfg.PostEndpoint.
+func New[T any](name string, cfg *config.Config, logger *zap.Logger, sinks []sink.Sink, opts ...Option) Batcher[T] {
+	if len(sinks) == 0 {
+		sinks = []sink.Sink{sink.NewHTTP(config.DefaultSink, cfg.PostEndpoint, sink.JSON{}, logger)}
+	}
+	b := &batcher[T]{
+		name:    name,
 		log:     logger,
 		cfg:     cfg,
-		entries: make(chan model.LogEntry, 1000),
+		sinks:   sinks,
+		options: options{onFailure: func(string, sink.Batch, error) { exitFunc(1) }},
+		entries: make(chan queued, 1000),
 		quit:    make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(&b.options)
+	}
+	for i, s := range sinks {
+		if f, ok := sink.FilterOf(s); ok {
+			if b.filters == nil {
+				b.filters = make([]sink.Filter, len(sinks))
+			}
+			b.filters[i] = f
+		}
+	}
+	return b
 }
 
-// Add queues a log entry into the batch channel.
-func (b *batcher) Add(entry model.LogEntry) {
+// Add queues an entry into the batch channel, unless the filters of every
+// sink drop it.
+func (b *batcher[T]) Add(entry T) {
+	record, err := json.Marshal(entry)
+	if err != nil {
+		b.log.Error("failed to marshal entry", zap.String("type", b.name), zap.Error(err))
+		return
+	}
+	q := queued{record: record}
+	if b.filters != nil {
+		if q.skip = b.filter(record); q.skip == nil {
+			return
+		}
+	}
 	select {
-	case b.entries <- entry:
+	case b.entries <- q:
 		// successfully added
 	default:
-		b.log.Warn("entry channel full, dropping entry")
+		Dropped.Add(b.name, 1)
+		b.log.Warn("entry channel full, dropping entry", zap.String("type", b.name))
 	}
 }
 
+// Load returns the fraction of the entry channel in use.
+func (b *batcher[T]) Load() float64 {
+	return float64(len(b.entries)) / float64(cap(b.entries))
+}
+
+// filter returns which sinks drop record, or nil when all of them do.
+func (b *batcher[T]) filter(record json.RawMessage) []bool {
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return make([]bool, len(b.sinks))
+	}
+	skip := make([]bool, len(b.sinks))
+	kept := false
+	for i, f := range b.filters {
+		skip[i] = f != nil && !f.Keep(doc)
+		kept = kept || !skip[i]
+	}
+	if !kept {
+		return nil
+	}
+	return skip
+}
+
 // Start runs the periodic flush ticker and processes the batch channel.
-func (b *batcher) Start() {
-	buffer := make([]model.LogEntry, 0, b.cfg.BatchSize)
+func (b *batcher[T]) Start() {
+	buffer := make([]queued, 0, b.cfg.BatchSize)
 	ticker := time.NewTicker(b.cfg.BatchInterval)
 	defer ticker.Stop()
 
@@ -72,6 +175,15 @@ func (b *batcher) Start() {
 				buffer = nil
 			}
 		case <-b.quit:
+			// Drain entries queued before Stop so they are not lost.
+			for drained := false; !drained; {
+				select {
+				case entry := <-b.entries:
+					buffer = append(buffer, entry)
+				default:
+					drained = true
+				}
+			}
 			if len(buffer) > 0 {
 				b.flush(buffer)
 			}
@@ -81,40 +193,44 @@ func (b *batcher) Start() {
 }
 
 // Stop signals the batcher to flush and shutdown.
-func (b *batcher) Stop() {
+func (b *batcher[T]) Stop() {
 	close(b.quit)
 }
 
-func (b *batcher) flush(batch []model.LogEntry) {
-	payload, err := json.Marshal(batch)
-	if err != nil {
-		b.log.Error("failed to marshal batch", zap.Error(err))
-		return
-	}
+func (b *batcher[T]) flush(entries []queued) {
+	id, createdAt := sink.NewBatchID(), time.Now().UTC()
+	for i, s := range b.sinks {
+		batch := sink.Batch{
+			ID:        id,
+			Type:      b.name,
+			CreatedAt: createdAt,
+			Records:   make([]json.RawMessage, 0, len(entries)),
+		}
+		for _, q := range entries {
+			if q.skip == nil || !q.skip[i] {
+				batch.Records = append(batch.Records, q.record)
+			}
+		}
+		if len(batch.Records) == 0 {
+			continue
+		}
 
-	start := time.Now()
-	var resp *http.Response
-	for i := 1; i <= 3; i++ {
-		req, _ := http.NewRequest("POST", b.cfg.PostEndpoint, bytes.NewBuffer(payload))
-		req.Header.Set("Content-Type", "application/json")
-		client := &http.Client{Timeout: 5 * time.Second}
-		resp, err = client.Do(req)
-		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			break
+		start := time.Now()
+		if err := s.Send(context.Background(), batch); err != nil {
+			b.log.Error("batch failed",
+				zap.String("type", b.name),
+				zap.String("sink", s.Name()),
+				zap.String("batch_id", batch.ID),
+				zap.Int("size", len(batch.Records)),
+				zap.Error(err))
+			b.onFailure(s.Name(), batch, err)
+			continue
 		}
-		b.log.Warn("POST failed", zap.Int("attempt", i), zap.Any("error", err), zap.Int("statusCode", resp.StatusCode))
-		time.Sleep(2 * time.Second)
+		b.log.Info("batch sent successfully",
+			zap.String("type", b.name),
+			zap.String("sink", s.Name()),
+			zap.String("batch_id", batch.ID),
+			zap.Int("size", len(batch.Records)),
+			zap.Duration("duration", time.Since(start)))
 	}
-	fmt.Println("coming here")
-	duration := time.Since(start)
-	if err != nil || resp.StatusCode >= 300 {
-		b.log.Error("batch failed after 3 attempts", zap.Int("size", len(batch)), zap.Error(err))
-		exitFunc(1)
-		return
-	}
-
-	b.log.Info("batch sent successfully",
-		zap.Int("size", len(batch)),
-		zap.Int("status", resp.StatusCode),
-		zap.Duration("duration", duration))
 }
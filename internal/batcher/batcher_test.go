@@ -1,93 +1,67 @@
 package batcher
 
 import (
+	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/mocksink"
 	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/sink"
 
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 )
 
-type mockServer struct {
-	Requests [][]model.LogEntry
-	Fail     bool
-	FailResp bool
-	Hits     int32
-	Server   *httptest.Server
-}
-
-func newMockServer(fail, failResp bool, passAt int32) *mockServer {
-	s := &mockServer{
-		Fail:     fail,
-		FailResp: failResp,
-	}
-	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		atomic.AddInt32(&s.Hits, 1)
-		if passAt == s.Hits {
-			body, _ := io.ReadAll(r.Body)
-			var logs []model.LogEntry
-			_ = json.Unmarshal(body, &logs)
-			s.Requests = append(s.Requests, logs)
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if s.Fail {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if s.FailResp {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		body, _ := io.ReadAll(r.Body)
-		var logs []model.LogEntry
-		_ = json.Unmarshal(body, &logs)
-		s.Requests = append(s.Requests, logs)
-		w.WriteHeader(http.StatusOK)
-	}))
-	return s
+// newSink starts a mock sink answering as steps say, closed when the test ends.
+func newSink(t *testing.T, steps ...mocksink.Step) (*mocksink.Server, string) {
+	s := mocksink.New(mocksink.Scenario{Steps: steps})
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL
 }
 
 func TestBatcherFlushOnQuit(t *testing.T) {
 	logger := zaptest.NewLogger(t)
-	srv := newMockServer(false, false, 1)
-	defer srv.Server.Close()
+	srv, url := newSink(t)
 
 	cfg := &config.Config{
 		BatchSize:     10,
 		BatchInterval: 5 * time.Second,
-		PostEndpoint:  srv.Server.URL,
+		PostEndpoint:  url,
 	}
 
-	b := New(cfg, logger)
+	b := New[model.LogEntry]("log_entry", cfg, logger, nil)
 	go b.Start()
-	b.Add(model.LogEntry{UserID: 1, Total: 1.23, Title: "flush-on-quit"})
+	b.Add(model.LogEntry{UserID: 1, Total: decimal.MustParse("1.23"), Title: "flush-on-quit"})
 	time.Sleep(500 * time.Millisecond)
 	b.Stop()
 
 	time.Sleep(500 * time.Millisecond)
-	if len(srv.Requests) != 1 {
-		t.Errorf("expected 1 flush, got %d", len(srv.Requests))
+	if len(srv.Batches()) != 1 {
+		t.Errorf("expected 1 flush, got %d", len(srv.Batches()))
 	}
 }
 
 func TestBatcherRetries(t *testing.T) {
 	logger := zaptest.NewLogger(t)
-	srv := newMockServer(true, false, -1)
-	defer srv.Server.Close()
+	srv, url := newSink(t, mocksink.Step{Status: http.StatusInternalServerError})
 
 	cfg := &config.Config{
 		BatchSize:     1,
 		BatchInterval: 1 * time.Second,
-		PostEndpoint:  srv.Server.URL,
+		PostEndpoint:  url,
 	}
 
 	// intercept os.Exit
@@ -98,25 +72,25 @@ func TestBatcherRetries(t *testing.T) {
 	}
 	defer func() { exitFunc = savedExit }()
 
-	b := New(cfg, logger)
+	b := New[model.LogEntry]("log_entry", cfg, logger, nil)
 	go b.Start()
-	b.Add(model.LogEntry{UserID: 2, Total: 2.34, Title: "retry-fail"})
+	b.Add(model.LogEntry{UserID: 2, Total: decimal.MustParse("2.34"), Title: "retry-fail"})
 	time.Sleep(10 * time.Second)
 
 	if atomic.LoadInt32(&exited) != 1 {
 		t.Error("expected exit after retries fail")
 	}
+	assert.Equal(t, 3, srv.Hits())
 }
 
 func TestBatcherFlushUsingTicker(t *testing.T) {
 	logger := zaptest.NewLogger(t)
-	srv := newMockServer(false, false, 1)
-	defer srv.Server.Close()
+	srv, url := newSink(t)
 
 	cfg := &config.Config{
 		BatchSize:     3,
 		BatchInterval: 2 * time.Second,
-		PostEndpoint:  srv.Server.URL,
+		PostEndpoint:  url,
 	}
 
 	exited := int32(0)
@@ -126,25 +100,25 @@ func TestBatcherFlushUsingTicker(t *testing.T) {
 	}
 	defer func() { exitFunc = savedExit }()
 
-	b := New(cfg, logger)
+	b := New[model.LogEntry]("log_entry", cfg, logger, nil)
 	go b.Start()
-	b.Add(model.LogEntry{UserID: 3, Total: 3.45, Title: "bad-resp"})
+	b.Add(model.LogEntry{UserID: 3, Total: decimal.MustParse("3.45"), Title: "bad-resp"})
 	time.Sleep(5 * time.Second)
 
 	if atomic.LoadInt32(&exited) != 0 {
 		t.Error("expected non zero exit code")
 	}
+	assert.Len(t, srv.Batches(), 1)
 }
 
 func TestBatcherSuccessAfterRetry(t *testing.T) {
 	logger := zaptest.NewLogger(t)
-	srv := newMockServer(false, true, 2)
-	defer srv.Server.Close()
+	srv, url := newSink(t, mocksink.Step{Status: http.StatusBadRequest}, mocksink.Step{})
 
 	cfg := &config.Config{
 		BatchSize:     1,
 		BatchInterval: 10 * time.Second,
-		PostEndpoint:  srv.Server.URL,
+		PostEndpoint:  url,
 	}
 
 	exited := int32(0)
@@ -154,12 +128,148 @@ func TestBatcherSuccessAfterRetry(t *testing.T) {
 	}
 	defer func() { exitFunc = savedExit }()
 
-	b := New(cfg, logger)
+	b := New[model.LogEntry]("log_entry", cfg, logger, nil)
 	go b.Start()
-	b.Add(model.LogEntry{UserID: 3, Total: 3.45, Title: "bad-resp"})
+	b.Add(model.LogEntry{UserID: 3, Total: decimal.MustParse("3.45"), Title: "bad-resp"})
 	time.Sleep(8 * time.Second)
 
 	if atomic.LoadInt32(&exited) != 0 {
 		t.Error("expected exit due to bad HTTP status code")
 	}
+	assert.Equal(t, 2, srv.Hits())
+	assert.Len(t, srv.Batches(), 1)
+}
+
+type recordingSink struct {
+	name    string
+	fail    error
+	mu      sync.Mutex
+	batches []sink.Batch
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Send(_ context.Context, b sink.Batch) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, b)
+	return nil
+}
+
+func TestBatcherFansOutToSinks(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{BatchSize: 2, BatchInterval: time.Minute}
+	first, second := &recordingSink{name: "first"}, &recordingSink{name: "second"}
+
+	b := New[json.RawMessage]("audit", cfg, logger, []sink.Sink{first, second})
+	done := make(chan struct{})
+	go func() {
+		b.Start()
+		close(done)
+	}()
+	b.Add(json.RawMessage(`{"a":1}`))
+	b.Add(json.RawMessage(`{"a":2}`))
+	b.Stop()
+	<-done
+
+	for _, s := range []*recordingSink{first, second} {
+		if assert.Len(t, s.batches, 1, s.name) {
+			batch := s.batches[0]
+			assert.Equal(t, "audit", batch.Type)
+			assert.Len(t, batch.ID, 32)
+			assert.Equal(t, `[{"a":1},{"a":2}]`, string(sink.JSONArray(batch.Records)))
+		}
+	}
+	assert.Equal(t, first.batches[0].ID, second.batches[0].ID)
+}
+
+func TestBatcherOnFailure(t *testing.T) {
+	cfg := &config.Config{BatchSize: 10, BatchInterval: time.Minute}
+	failing := &recordingSink{name: "failing", fail: errors.New("unavailable")}
+	ok := &recordingSink{name: "ok"}
+	var failed []string
+	b := New[json.RawMessage]("audit", cfg, zaptest.NewLogger(t), []sink.Sink{failing, ok},
+		OnFailure(func(sinkName string, batch sink.Batch, err error) {
+			failed = append(failed, fmt.Sprintf("%s %d %v", sinkName, len(batch.Records), err))
+		}))
+	done := make(chan struct{})
+	go func() {
+		b.Start()
+		close(done)
+	}()
+	b.Add(json.RawMessage(`{"a":1}`))
+	b.Stop()
+	<-done
+
+	// The process keeps running and the next sink still gets the batch.
+	assert.Equal(t, []string{"failing 1 unavailable"}, failed)
+	assert.Len(t, ok.batches, 1)
+}
+
+type dropUser string
+
+func (d dropUser) Keep(doc any) bool {
+	return doc.(map[string]any)["user"] != string(d)
+}
+
+func TestBatcherFiltersBeforeQueueing(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{BatchSize: 10, BatchInterval: time.Minute}
+	first, second := &recordingSink{name: "first"}, &recordingSink{name: "second"}
+
+	b := New[json.RawMessage]("audit", cfg, logger, []sink.Sink{
+		sink.WithFilter(first, dropUser("a")),
+		sink.WithFilter(second, dropUser("b")),
+	})
+	b.Add(json.RawMessage(`{"user":"a"}`))
+	b.Add(json.RawMessage(`{"user":"b"}`))
+	b.Add(json.RawMessage(`{"user":"c"}`))
+	b.Add(json.RawMessage(`{"user":"a"}`))
+	b.Add(json.RawMessage(`{"user":"b"}`))
+
+	// A record every sink drops is not queued at all.
+	both := New[json.RawMessage]("audit", cfg, logger, []sink.Sink{
+		sink.WithFilter(first, dropUser("a")),
+		sink.WithFilter(second, dropUser("a")),
+	})
+	both.Add(json.RawMessage(`{"user":"a"}`))
+	assert.Zero(t, len(both.(*batcher[json.RawMessage]).entries))
+
+	done := make(chan struct{})
+	go func() {
+		b.Start()
+		close(done)
+	}()
+	b.Stop()
+	<-done
+
+	if assert.Len(t, first.batches, 1) && assert.Len(t, second.batches, 1) {
+		assert.Equal(t, `[{"user":"b"},{"user":"c"},{"user":"b"}]`, string(sink.JSONArray(first.batches[0].Records)))
+		assert.Equal(t, `[{"user":"a"},{"user":"c"},{"user":"a"}]`, string(sink.JSONArray(second.batches[0].Records)))
+		assert.Equal(t, first.batches[0].ID, second.batches[0].ID)
+	}
+}
+
+func TestBatcherLoad(t *testing.T) {
+	cfg := &config.Config{BatchSize: 10, BatchInterval: time.Minute}
+	b := New[json.RawMessage]("audit", cfg, zaptest.NewLogger(t), []sink.Sink{&recordingSink{name: "first"}})
+	assert.Zero(t, LoadOf(b))
+	for i := 0; i < 250; i++ {
+		b.Add(json.RawMessage(`{}`))
+	}
+	assert.InDelta(t, 0.25, LoadOf(b), 1e-9)
+	assert.Zero(t, LoadOf(struct{}{}))
+}
+
+func TestBatcherCountsDrops(t *testing.T) {
+	cfg := &config.Config{BatchSize: 10, BatchInterval: time.Minute}
+	b := New[json.RawMessage]("dropped", cfg, zap.NewNop(), []sink.Sink{&recordingSink{name: "first"}})
+	for i := 0; i < 1002; i++ {
+		b.Add(json.RawMessage(`{}`))
+	}
+	assert.Equal(t, "2", Dropped.Get("dropped").String())
+	assert.Equal(t, 1.0, LoadOf(b))
 }
@@ -2,21 +2,97 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// Supported values for ERROR_FORMAT.
+const (
+	// ErrorFormatProblem renders errors as RFC 7807 application/problem+json documents.
+	ErrorFormatProblem = "problem"
+	// ErrorFormatLegacy renders errors in the original list-of-maps format.
+	ErrorFormatLegacy = "legacy"
+)
+
+// Supported values for VALIDATION_MODE.
+const (
+	// ValidationModeStruct validates payloads with the validate struct tags in internal/model.
+	ValidationModeStruct = "struct"
+	// ValidationModeSchema validates payloads against the JSON Schema in SCHEMA_FILE.
+	ValidationModeSchema = "schema"
+)
+
 // Config holds all configurable values for the app.
 type Config struct {
 	Env           string
 	BatchSize     int
 	BatchInterval time.Duration
 	PostEndpoint  string
+	ErrorFormat   string
+
+	ValidationMode string
+	SchemaFile     string
+
+	PhoneFormats       []string
+	PhoneCustomFormats []PhoneFormat
+	PhoneNormalize     bool
+
+	// LoginMaxFuture and LoginMaxAge bound how far login times may lie ahead of
+	// or behind the server clock. Zero disables the check.
+	LoginMaxFuture time.Duration
+	LoginMaxAge    time.Duration
+
+	// RedactHMACKey keys the "hash" redaction action.
+	RedactHMACKey string
+	// EncryptionKeyring is the path of the keyring file used by sinks that
+	// encrypt fields.
+	EncryptionKeyring string
+
+	// GeoIPDatabases lists the MaxMind DB files login IPs are looked up in.
+	// Lookups are cached for up to GeoIPCacheSize IPs and the files are checked
+	// for changes every GeoIPReloadInterval; zero disables reloading.
+	GeoIPDatabases      []string
+	GeoIPCacheSize      int
+	GeoIPReloadInterval time.Duration
+
+	// GRPCPort is the port the gRPC Ingest service listens on. It is not
+	// served when zero.
+	GRPCPort int
+
+	// WSMaxConnections bounds the WebSocket connections open at
+	// /ws/log; the endpoint is not served when it is zero. The server pings
+	// every connection each WSPingInterval and closes it when no pong
+	// arrives within twice that.
+	WSMaxConnections int
+	WSPingInterval   time.Duration
+
+	// AdminToken is the bearer token required by the /admin endpoints. They
+	// are not served when it is empty.
+	AdminToken string
+
+	// Sinks, Events, Detection, Transforms and Tail are read from the YAML
+	// file named by CONFIG_FILE.
+	Sinks      []SinkConfig
+	Events     []EventConfig
+	Detection  DetectionConfig
+	Transforms []TransformConfig
+	Tail       TailConfig
+}
+
+// PhoneFormat describes a custom phone number format supplied via PHONE_CUSTOM_FORMATS.
+type PhoneFormat struct {
+	Name        string
+	CallingCode string
+	TrunkPrefix string
+	Pattern     string
 }
 
-// Load reads environment variables and populates a Config struct.
+// Load reads environment variables, and the YAML file named by CONFIG_FILE if
+// set, and populates a Config struct.
 func Load() *Config {
 	batchSize, err := strconv.Atoi(getEnv("BATCH_SIZE", "5"))
 	if err != nil {
@@ -28,12 +104,97 @@ func Load() *Config {
 		log.Panicf("Invalid BATCH_INTERVAL: %v", err)
 	}
 
-	return &Config{
+	errorFormat := getEnv("ERROR_FORMAT", ErrorFormatProblem)
+	if errorFormat != ErrorFormatProblem && errorFormat != ErrorFormatLegacy {
+		log.Panicf("Invalid ERROR_FORMAT: %q", errorFormat)
+	}
+
+	validationMode := getEnv("VALIDATION_MODE", ValidationModeStruct)
+	if validationMode != ValidationModeStruct && validationMode != ValidationModeSchema {
+		log.Panicf("Invalid VALIDATION_MODE: %q", validationMode)
+	}
+
+	normalize, err := strconv.ParseBool(getEnv("PHONE_NORMALIZE", "false"))
+	if err != nil {
+		log.Panicf("Invalid PHONE_NORMALIZE: %v", err)
+	}
+
+	customFormats, err := parsePhoneFormats(os.Getenv("PHONE_CUSTOM_FORMATS"))
+	if err != nil {
+		log.Panicf("Invalid PHONE_CUSTOM_FORMATS: %v", err)
+	}
+
+	maxFuture, err := parseWindow("LOGIN_MAX_FUTURE", "0s")
+	if err != nil {
+		log.Panicf("Invalid LOGIN_MAX_FUTURE: %v", err)
+	}
+
+	maxAge, err := parseWindow("LOGIN_MAX_AGE", "0s")
+	if err != nil {
+		log.Panicf("Invalid LOGIN_MAX_AGE: %v", err)
+	}
+
+	geoCacheSize, err := strconv.Atoi(getEnv("GEOIP_CACHE_SIZE", "10000"))
+	if err != nil || geoCacheSize < 0 {
+		log.Panicf("Invalid GEOIP_CACHE_SIZE: %q", os.Getenv("GEOIP_CACHE_SIZE"))
+	}
+
+	geoReload, err := parseWindow("GEOIP_RELOAD_INTERVAL", "1m")
+	if err != nil {
+		log.Panicf("Invalid GEOIP_RELOAD_INTERVAL: %v", err)
+	}
+
+	grpcPort, err := strconv.Atoi(getEnv("GRPC_PORT", "0"))
+	if err != nil || grpcPort < 0 || grpcPort > 65535 {
+		log.Panicf("Invalid GRPC_PORT: %q", os.Getenv("GRPC_PORT"))
+	}
+
+	wsMax, err := strconv.Atoi(getEnv("WS_MAX_CONNECTIONS", "100"))
+	if err != nil || wsMax < 0 {
+		log.Panicf("Invalid WS_MAX_CONNECTIONS: %q", os.Getenv("WS_MAX_CONNECTIONS"))
+	}
+
+	wsPing, err := time.ParseDuration(getEnv("WS_PING_INTERVAL", "30s"))
+	if err != nil || wsPing <= 0 {
+		log.Panicf("Invalid WS_PING_INTERVAL: %q", os.Getenv("WS_PING_INTERVAL"))
+	}
+
+	cfg := &Config{
 		Env:           getEnv("ENV", "development"),
 		BatchSize:     batchSize,
 		BatchInterval: interval,
 		PostEndpoint:  getEnv("POST_ENDPOINT", "http://localhost:9000"),
+		ErrorFormat:   errorFormat,
+
+		ValidationMode: validationMode,
+		SchemaFile:     os.Getenv("SCHEMA_FILE"),
+
+		PhoneFormats:       splitList(getEnv("PHONE_FORMATS", "e164,legacy")),
+		PhoneCustomFormats: customFormats,
+		PhoneNormalize:     normalize,
+
+		LoginMaxFuture: maxFuture,
+		LoginMaxAge:    maxAge,
+
+		RedactHMACKey:     os.Getenv("REDACT_HMAC_KEY"),
+		EncryptionKeyring: os.Getenv("ENCRYPTION_KEYRING"),
+
+		GeoIPDatabases:      splitList(os.Getenv("GEOIP_DATABASES")),
+		GeoIPCacheSize:      geoCacheSize,
+		GeoIPReloadInterval: geoReload,
+
+		GRPCPort: grpcPort,
+
+		WSMaxConnections: wsMax,
+		WSPingInterval:   wsPing,
+
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+	}
+
+	if err := loadFile(os.Getenv("CONFIG_FILE"), cfg); err != nil {
+		log.Panicf("Invalid CONFIG_FILE: %v", err)
 	}
+	return cfg
 }
 
 func getEnv(key, fallback string) string {
@@ -42,3 +203,49 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func splitList(val string) []string {
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseWindow parses a non-negative duration from key, defaulting to fallback.
+func parseWindow(key, fallback string) (time.Duration, error) {
+	d, err := time.ParseDuration(getEnv(key, fallback))
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative, got %s", d)
+	}
+	return d, nil
+}
+
+// parsePhoneFormats parses semicolon-separated "name|calling_code|trunk_prefix|pattern" entries.
+func parsePhoneFormats(val string) ([]PhoneFormat, error) {
+	var formats []PhoneFormat
+	for _, entry := range strings.Split(val, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "|", 4)
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		if len(parts) != 4 || parts[0] == "" || parts[3] == "" {
+			return nil, fmt.Errorf("expected name|calling_code|trunk_prefix|pattern, got %q", entry)
+		}
+		formats = append(formats, PhoneFormat{
+			Name:        parts[0],
+			CallingCode: parts[1],
+			TrunkPrefix: parts[2],
+			Pattern:     parts[3],
+		})
+	}
+	return formats, nil
+}
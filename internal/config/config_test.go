@@ -2,10 +2,12 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoad_Defaults(t *testing.T) {
@@ -17,6 +19,14 @@ func TestLoad_Defaults(t *testing.T) {
 	assert.Equal(t, 5, cfg.BatchSize)
 	assert.Equal(t, 10*time.Second, cfg.BatchInterval)
 	assert.Equal(t, "http://localhost:9000", cfg.PostEndpoint)
+	assert.Equal(t, ErrorFormatProblem, cfg.ErrorFormat)
+	assert.Equal(t, ValidationModeStruct, cfg.ValidationMode)
+	assert.Empty(t, cfg.SchemaFile)
+	assert.Equal(t, []string{"e164", "legacy"}, cfg.PhoneFormats)
+	assert.Empty(t, cfg.PhoneCustomFormats)
+	assert.False(t, cfg.PhoneNormalize)
+	assert.Zero(t, cfg.LoginMaxFuture)
+	assert.Zero(t, cfg.LoginMaxAge)
 }
 
 func TestLoad_CustomEnv(t *testing.T) {
@@ -24,6 +34,7 @@ func TestLoad_CustomEnv(t *testing.T) {
 	_ = os.Setenv("BATCH_SIZE", "15")
 	_ = os.Setenv("BATCH_INTERVAL", "30s")
 	_ = os.Setenv("POST_ENDPOINT", "https://example.com/hook")
+	_ = os.Setenv("ERROR_FORMAT", "legacy")
 
 	cfg := Load()
 
@@ -31,6 +42,7 @@ func TestLoad_CustomEnv(t *testing.T) {
 	assert.Equal(t, 15, cfg.BatchSize)
 	assert.Equal(t, 30*time.Second, cfg.BatchInterval)
 	assert.Equal(t, "https://example.com/hook", cfg.PostEndpoint)
+	assert.Equal(t, ErrorFormatLegacy, cfg.ErrorFormat)
 }
 
 func TestLoad_InvalidBatchSize(t *testing.T) {
@@ -53,3 +65,405 @@ func TestLoad_InvalidInterval(t *testing.T) {
 	}()
 	Load()
 }
+
+func TestLoad_InvalidErrorFormat(t *testing.T) {
+	_ = os.Setenv("BATCH_SIZE", "5")
+	_ = os.Setenv("BATCH_INTERVAL", "10s")
+	_ = os.Setenv("ERROR_FORMAT", "xml")
+	defer func() {
+		_ = os.Unsetenv("ERROR_FORMAT")
+		if r := recover(); r == nil {
+			t.Error("expected panic due to invalid ERROR_FORMAT")
+		}
+	}()
+	Load()
+}
+
+func TestLoad_PhoneFormats(t *testing.T) {
+	t.Setenv("BATCH_SIZE", "5")
+	t.Setenv("BATCH_INTERVAL", "10s")
+	t.Setenv("ERROR_FORMAT", "problem")
+	t.Setenv("PHONE_FORMATS", "e164, us")
+	t.Setenv("PHONE_CUSTOM_FORMATS", `fr|33|0|^0[1-9](\d{2}){4}$;ext||| ^x\d+$`)
+	t.Setenv("PHONE_NORMALIZE", "true")
+
+	cfg := Load()
+
+	assert.Equal(t, []string{"e164", "us"}, cfg.PhoneFormats)
+	assert.Equal(t, []PhoneFormat{
+		{Name: "fr", CallingCode: "33", TrunkPrefix: "0", Pattern: `^0[1-9](\d{2}){4}$`},
+		{Name: "ext", Pattern: `^x\d+$`},
+	}, cfg.PhoneCustomFormats)
+	assert.True(t, cfg.PhoneNormalize)
+}
+
+func TestLoad_InvalidPhoneCustomFormats(t *testing.T) {
+	t.Setenv("BATCH_SIZE", "5")
+	t.Setenv("BATCH_INTERVAL", "10s")
+	t.Setenv("ERROR_FORMAT", "problem")
+	t.Setenv("PHONE_CUSTOM_FORMATS", "fr|33")
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic due to invalid PHONE_CUSTOM_FORMATS")
+		}
+	}()
+	Load()
+}
+
+func TestLoad_SchemaValidation(t *testing.T) {
+	t.Setenv("BATCH_SIZE", "5")
+	t.Setenv("BATCH_INTERVAL", "10s")
+	t.Setenv("ERROR_FORMAT", "problem")
+	t.Setenv("VALIDATION_MODE", "schema")
+	t.Setenv("SCHEMA_FILE", "/etc/webhook/log_entry.schema.json")
+
+	cfg := Load()
+
+	assert.Equal(t, ValidationModeSchema, cfg.ValidationMode)
+	assert.Equal(t, "/etc/webhook/log_entry.schema.json", cfg.SchemaFile)
+}
+
+func TestLoad_InvalidValidationMode(t *testing.T) {
+	t.Setenv("BATCH_SIZE", "5")
+	t.Setenv("BATCH_INTERVAL", "10s")
+	t.Setenv("ERROR_FORMAT", "problem")
+	t.Setenv("VALIDATION_MODE", "xsd")
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic due to invalid VALIDATION_MODE")
+		}
+	}()
+	Load()
+}
+
+func TestLoad_ConfigFile(t *testing.T) {
+	os.Clearenv()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+sinks:
+  - name: warehouse
+    url: https://warehouse.example.com/ingest
+  - name: audit
+    type: http
+    url: https://audit.example.com/ingest
+    format: cloudevents
+    source: /benzinga/audit
+    redact:
+      - field: /meta/logins/*/ip
+        action: truncate_ip
+      - field: /meta/phone_numbers/home
+        action: mask
+        keep: 3
+events:
+  - name: order
+    schema: schemas/order.json
+    sinks: [warehouse]
+`), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg := Load()
+
+	assert.Equal(t, []SinkConfig{
+		{Name: "warehouse", Type: "http", URL: "https://warehouse.example.com/ingest", Format: FormatJSON},
+		{Name: "audit", Type: "http", URL: "https://audit.example.com/ingest", Format: FormatCloudEvents, Source: "/benzinga/audit", Redact: []RedactRule{
+			{Field: "/meta/logins/*/ip", Action: RedactTruncateIP},
+			{Field: "/meta/phone_numbers/home", Action: RedactMask, Keep: 3},
+		}},
+	}, cfg.Sinks)
+	order, ok := cfg.Event("order")
+	assert.True(t, ok)
+	assert.Equal(t, EventConfig{Name: "order", Schema: "schemas/order.json", Sinks: []string{"warehouse"}}, order)
+}
+
+func TestLoad_Detection(t *testing.T) {
+	os.Clearenv()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+sinks:
+  - name: warehouse
+    url: https://warehouse.example.com/ingest
+  - name: security
+    url: https://siem.example.com/ingest
+detection:
+  alert_sink: security
+  max_speed_kmh: 900
+  distinct_ips: 5
+  private_ips: true
+`), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("GEOIP_DATABASES", "GeoLite2-City.mmdb")
+
+	cfg := Load()
+
+	assert.Equal(t, DetectionConfig{
+		AlertSink:        "security",
+		MaxSpeedKMH:      900,
+		DistinctIPs:      5,
+		DistinctIPWindow: time.Hour,
+		PrivateIPs:       true,
+		MaxUsers:         100000,
+		IdleTTL:          24 * time.Hour,
+	}, cfg.Detection)
+}
+
+func TestLoad_Transforms(t *testing.T) {
+	os.Clearenv()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+transforms:
+  - name: camel
+    jq: '{userId: .user_id} + del(.user_id)'
+  - name: no-meta
+    jq: del(.meta)
+sinks:
+  - name: analytics
+    url: https://analytics.example.com/ingest
+    transforms: [camel, no-meta]
+events:
+  - name: log_entry
+    transforms: [camel]
+`), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+
+	cfg := Load()
+
+	assert.Equal(t, []TransformConfig{
+		{Name: "camel", JQ: "{userId: .user_id} + del(.user_id)"},
+		{Name: "no-meta", JQ: "del(.meta)"},
+	}, cfg.Transforms)
+	assert.Equal(t, []string{"camel", "no-meta"}, cfg.Sinks[0].Transforms)
+	ec, _ := cfg.Event("log_entry")
+	assert.Equal(t, []string{"camel"}, ec.Transforms)
+	assert.Equal(t, "s3cret", cfg.AdminToken)
+}
+
+func TestLoad_Filter(t *testing.T) {
+	os.Clearenv()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+sinks:
+  - name: analytics
+    url: https://analytics.example.com/ingest
+    filter:
+      - name: incomplete
+        when: .completed == false
+        action: drop
+      - name: tenth
+        action: sample
+        rate: 0.1
+`), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg := Load()
+
+	assert.Equal(t, []FilterRule{
+		{Name: "incomplete", When: ".completed == false", Action: FilterDrop},
+		{Name: "tenth", Action: FilterSample, Rate: 0.1, Key: "/user_id"},
+	}, cfg.Sinks[0].Filter)
+}
+
+func TestLoad_Archive(t *testing.T) {
+	os.Clearenv()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+sinks:
+  - name: archive
+    type: file
+    archive:
+      dir: /var/lib/benzinga-webhook/archive
+      max_age: 720h
+  - name: small
+    type: file
+    archive: {dir: /tmp/small, segment_bytes: 1024, segment_age: 1m, max_bytes: 4096}
+`), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg := Load()
+
+	assert.Equal(t, SinkConfig{
+		Name:   "archive",
+		Type:   "file",
+		Format: FormatNDJSON,
+		Archive: ArchiveConfig{
+			Dir:          "/var/lib/benzinga-webhook/archive",
+			SegmentBytes: 64 << 20,
+			SegmentAge:   time.Hour,
+			MaxAge:       720 * time.Hour,
+		},
+	}, cfg.Sinks[0])
+	assert.Equal(t, ArchiveConfig{Dir: "/tmp/small", SegmentBytes: 1024, SegmentAge: time.Minute, MaxBytes: 4096}, cfg.Sinks[1].Archive)
+}
+
+func TestLoad_ProtobufRedact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	for doc, want := range map[string]string{
+		"redact: [{field: /meta/logins/*/ip, action: truncate_ip}, {field: /meta/*, action: mask}, {field: /user_id, action: drop}]": "",
+		"redact: [{field: /user_id, action: mask}]":             `sink "a": redact "/user_id": format protobuf cannot mask a field that is not a string`,
+		"redact: [{field: /total, action: mask}]":               `sink "a": redact "/total": format protobuf cannot mask a field that is not a string`,
+		"redact: [{field: /meta/logins/*/geo/*, action: mask}]": `sink "a": redact "/meta/logins/*/geo/*": format protobuf cannot mask a field that is not a string`,
+		"redact: [{field: /*, action: mask}]":                   `sink "a": redact "/*": format protobuf cannot mask a field that is not a string`,
+	} {
+		require.NoError(t, os.WriteFile(path, []byte("sinks: [{name: a, url: http://a, format: protobuf, "+doc+"}]"), 0o600))
+		err := loadFile(path, &Config{})
+		if want == "" {
+			assert.NoError(t, err, doc)
+		} else {
+			assert.EqualError(t, err, want, doc)
+		}
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+transforms: [{name: t, jq: .}]
+sinks: [{name: a, url: http://a}, {name: b, url: http://b, format: protobuf}]
+events: [{name: log_entry, sinks: [a], transforms: [t]}, {name: order, schema: order.json, transforms: [t]}]
+`), 0o600))
+	assert.NoError(t, loadFile(path, &Config{}), "transforms of other events or of events not routed to the sink")
+}
+
+func TestLoad_Tail(t *testing.T) {
+	os.Clearenv()
+	cfg := Load()
+	assert.Equal(t, TailConfig{Buffer: 256, Redact: DefaultTailRedact}, cfg.Tail)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+tail:
+  buffer: 16
+  redact:
+    - field: /title
+      action: drop
+`), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+	cfg = Load()
+	assert.Equal(t, TailConfig{Buffer: 16, Redact: []RedactRule{{Field: "/title", Action: RedactDrop}}}, cfg.Tail)
+
+	assert.NoError(t, os.WriteFile(path, []byte("tail: {redact: []}"), 0o600))
+	cfg = Load()
+	assert.Empty(t, cfg.Tail.Redact)
+	assert.NotNil(t, cfg.Tail.Redact)
+}
+
+func TestLoad_DefaultSink(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("POST_ENDPOINT", "https://example.com/hook")
+
+	cfg := Load()
+
+	assert.Equal(t, []SinkConfig{{Name: DefaultSink, Type: "http", URL: "https://example.com/hook", Format: FormatJSON}}, cfg.Sinks)
+	assert.Empty(t, cfg.Events)
+}
+
+func TestLoad_InvalidConfigFile(t *testing.T) {
+	for name, doc := range map[string]string{
+		"duplicate sink":  "sinks: [{name: a, url: http://a}, {name: a, url: http://b}]",
+		"missing url":     "sinks: [{name: a}]",
+		"unknown type":    "sinks: [{name: a, type: kafka, url: http://a}]",
+		"unknown format":  "sinks: [{name: a, url: http://a, format: xml}]",
+		"protobuf shape":  "transforms: [{name: t, jq: .}]\nsinks: [{name: a, url: http://a, format: protobuf, transforms: [t]}]",
+		"protobuf redact": "sinks: [{name: a, url: http://a, format: protobuf, redact: [{field: /user_id, action: mask}]}]",
+		"protobuf events": "transforms: [{name: t, jq: .}]\nsinks: [{name: a, url: http://a, format: protobuf}]\nevents: [{name: log_entry, transforms: [t]}]",
+		"unknown sink":    "events: [{name: order, schema: order.json, sinks: [missing]}]",
+		"malformed":       "sinks: {",
+		"redact action":   "sinks: [{name: a, url: http://a, redact: [{field: /ip, action: encrypt}]}]",
+		"redact pointer":  "sinks: [{name: a, url: http://a, redact: [{field: ip, action: drop}]}]",
+		"redact hash":     "sinks: [{name: a, url: http://a, redact: [{field: /ip, action: hash}]}]",
+		"encrypt keyring": "sinks: [{name: a, url: http://a, encrypt: [/ip]}]",
+		"encrypt pointer": "sinks: [{name: a, url: http://a, encrypt: [ip]}]",
+		"alert sink":      "detection: {alert_sink: missing}",
+		"travel geoip":    "sinks: [{name: a, url: http://a}]\ndetection: {alert_sink: a, max_speed_kmh: 900}",
+		"negative window": "sinks: [{name: a, url: http://a}]\ndetection: {alert_sink: a, distinct_ip_window: -1h}",
+		"transform jq":    "transforms: [{name: t, jq: '{'}]",
+		"transform empty": "transforms: [{name: t}]",
+		"transform dup":   "transforms: [{name: t, jq: .}, {name: t, jq: .}]",
+		"sink transform":  "sinks: [{name: a, url: http://a, transforms: [missing]}]",
+		"event transform": "events: [{name: order, schema: order.json, transforms: [missing]}]",
+		"filter action":   "sinks: [{name: a, url: http://a, filter: [{name: f, action: route}]}]",
+		"filter dup":      "sinks: [{name: a, url: http://a, filter: [{name: f, action: drop}, {name: f, action: keep}]}]",
+		"filter when":     "sinks: [{name: a, url: http://a, filter: [{name: f, when: '{', action: drop}]}]",
+		"filter rate":     "sinks: [{name: a, url: http://a, filter: [{name: f, action: sample, rate: 2}]}]",
+		"filter key":      "sinks: [{name: a, url: http://a, filter: [{name: f, action: sample, key: user_id}]}]",
+		"tail buffer":     "tail: {buffer: -1}",
+		"archive dir":     "sinks: [{name: a, type: file}]",
+		"archive format":  "sinks: [{name: a, type: file, format: json, archive: {dir: /tmp/a}}]",
+		"archive limits":  "sinks: [{name: a, type: file, archive: {dir: /tmp/a, max_bytes: -1}}]",
+		"tail redact":     "tail: {redact: [{field: /ip, action: hash}]}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			os.Clearenv()
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			assert.NoError(t, os.WriteFile(path, []byte(doc), 0o600))
+			t.Setenv("CONFIG_FILE", path)
+			assert.Panics(t, func() { Load() })
+		})
+	}
+}
+
+func TestLoad_LoginWindow(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("LOGIN_MAX_FUTURE", "5m")
+	t.Setenv("LOGIN_MAX_AGE", "720h")
+
+	cfg := Load()
+
+	assert.Equal(t, 5*time.Minute, cfg.LoginMaxFuture)
+	assert.Equal(t, 720*time.Hour, cfg.LoginMaxAge)
+
+	t.Setenv("LOGIN_MAX_AGE", "-1h")
+	assert.Panics(t, func() { Load() })
+}
+
+func TestLoad_GeoIP(t *testing.T) {
+	os.Clearenv()
+	cfg := Load()
+	assert.Empty(t, cfg.GeoIPDatabases)
+	assert.Equal(t, 10000, cfg.GeoIPCacheSize)
+	assert.Equal(t, time.Minute, cfg.GeoIPReloadInterval)
+
+	t.Setenv("GEOIP_DATABASES", "City.mmdb, ASN.mmdb")
+	t.Setenv("GEOIP_CACHE_SIZE", "0")
+	t.Setenv("GEOIP_RELOAD_INTERVAL", "0")
+	cfg = Load()
+	assert.Equal(t, []string{"City.mmdb", "ASN.mmdb"}, cfg.GeoIPDatabases)
+	assert.Zero(t, cfg.GeoIPCacheSize)
+	assert.Zero(t, cfg.GeoIPReloadInterval)
+
+	t.Setenv("GEOIP_CACHE_SIZE", "-1")
+	assert.Panics(t, func() { Load() })
+}
+
+func TestLoad_GRPCPort(t *testing.T) {
+	os.Clearenv()
+	assert.Zero(t, Load().GRPCPort)
+
+	t.Setenv("GRPC_PORT", "9090")
+	assert.Equal(t, 9090, Load().GRPCPort)
+
+	for _, port := range []string{"grpc", "-1", "65536"} {
+		t.Setenv("GRPC_PORT", port)
+		assert.Panics(t, func() { Load() }, port)
+	}
+}
+
+func TestLoad_WebSocket(t *testing.T) {
+	os.Clearenv()
+	cfg := Load()
+	assert.Equal(t, 100, cfg.WSMaxConnections)
+	assert.Equal(t, 30*time.Second, cfg.WSPingInterval)
+
+	t.Setenv("WS_MAX_CONNECTIONS", "0")
+	t.Setenv("WS_PING_INTERVAL", "5s")
+	cfg = Load()
+	assert.Zero(t, cfg.WSMaxConnections)
+	assert.Equal(t, 5*time.Second, cfg.WSPingInterval)
+
+	for _, n := range []string{"many", "-1"} {
+		t.Setenv("WS_MAX_CONNECTIONS", n)
+		assert.Panics(t, func() { Load() }, n)
+	}
+	t.Setenv("WS_MAX_CONNECTIONS", "1")
+	for _, d := range []string{"soon", "0s", "-1s"} {
+		t.Setenv("WS_PING_INTERVAL", d)
+		assert.Panics(t, func() { Load() }, d)
+	}
+}
@@ -0,0 +1,502 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"benzinga-webhook/internal/jsonptr"
+	"benzinga-webhook/internal/model"
+
+	"github.com/itchyny/gojq"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSink is the name of the sink built from POST_ENDPOINT when CONFIG_FILE
+// declares no sinks.
+const DefaultSink = "default"
+
+// SinkConfig declares a delivery target for batches.
+type SinkConfig struct {
+	Name string `yaml:"name"`
+	// Type selects the sink implementation: "http", the default, posts
+	// batches to URL and "file" appends them to the archive in Archive.Dir.
+	Type string `yaml:"type"`
+	URL  string `yaml:"url"`
+	// Archive configures sinks of type "file".
+	Archive ArchiveConfig `yaml:"archive"`
+	// Format selects the payload encoding, one of the Format constants.
+	// It defaults to FormatJSON.
+	Format string `yaml:"format"`
+	// Source identifies this service in FormatCloudEvents and FormatEnvelope
+	// payloads, "benzinga-webhook" by default.
+	Source string `yaml:"source"`
+	// Redact lists the rules applied to every record before it is delivered.
+	Redact []RedactRule `yaml:"redact"`
+	// Encrypt lists JSON pointers, as in RedactRule.Field, of values encrypted
+	// with the keyring in ENCRYPTION_KEYRING after redaction.
+	Encrypt []string `yaml:"encrypt"`
+	// Transforms names the transforms applied, in order, to every record
+	// before redaction.
+	Transforms []string `yaml:"transforms"`
+	// Filter lists the rules deciding which records the sink receives.
+	Filter []FilterRule `yaml:"filter"`
+}
+
+// ArchiveConfig configures a sink of type "file", which appends records to
+// NDJSON segment files in Dir and lists every batch in an index file.
+type ArchiveConfig struct {
+	Dir string `yaml:"dir"`
+	// SegmentBytes and SegmentAge bound the segment being written; the next
+	// batch starts a new one once either is reached, and the previous one is
+	// compressed. They default to 64 MiB and 1h.
+	SegmentBytes int64         `yaml:"segment_bytes"`
+	SegmentAge   time.Duration `yaml:"segment_age"`
+	// MaxAge and MaxBytes bound the compressed segments kept; the oldest are
+	// deleted first. Zero keeps segments regardless of age or total size.
+	MaxAge   time.Duration `yaml:"max_age"`
+	MaxBytes int64         `yaml:"max_bytes"`
+}
+
+// Payload formats supported in SinkConfig.Format.
+const (
+	// FormatJSON posts a JSON array of records.
+	FormatJSON = "json"
+	// FormatNDJSON posts one record per line.
+	FormatNDJSON = "ndjson"
+	// FormatCloudEvents posts a CloudEvents 1.0 JSON batch.
+	FormatCloudEvents = "cloudevents"
+	// FormatEnvelope posts an object with the batch ID, record count, send
+	// time, source and checksum alongside the records.
+	FormatEnvelope = "envelope"
+	// FormatProtobuf posts a Batch message of internal/pb/webhook.proto.
+	FormatProtobuf = "protobuf"
+)
+
+// Filter actions supported in FilterRule.Action.
+const (
+	// FilterKeep delivers the record.
+	FilterKeep = "keep"
+	// FilterDrop does not deliver the record.
+	FilterDrop = "drop"
+	// FilterSample delivers the records of a Rate fraction of users, chosen by
+	// a hash of the value at Key so that the same users are always kept.
+	FilterSample = "sample"
+)
+
+// FilterRule decides whether a sink receives a record. Rules are evaluated in
+// order and the first one whose When matches decides; records no rule matches
+// are delivered.
+type FilterRule struct {
+	Name string `yaml:"name"`
+	// When is a jq expression matching the records it is true for. An empty
+	// When matches every record.
+	When   string  `yaml:"when"`
+	Action string  `yaml:"action"`
+	Rate   float64 `yaml:"rate"`
+	// Key is the JSON pointer of the value FilterSample hashes, "/user_id" by
+	// default.
+	Key string `yaml:"key"`
+}
+
+// Redaction actions supported in RedactRule.Action.
+const (
+	// RedactMask replaces all but the last Keep characters with '*'.
+	RedactMask = "mask"
+	// RedactHash replaces the value with its hex HMAC-SHA256 keyed with REDACT_HMAC_KEY.
+	RedactHash = "hash"
+	// RedactTruncateIP zeroes an IPv4 address to its /24 or an IPv6 address to its /48.
+	RedactTruncateIP = "truncate_ip"
+	// RedactDrop removes the field.
+	RedactDrop = "drop"
+)
+
+// RedactRule rewrites the fields matching a JSON pointer such as
+// "/meta/logins/*/ip", where "*" matches any array index or object key.
+type RedactRule struct {
+	Field  string `yaml:"field"`
+	Action string `yaml:"action"`
+	// Keep is the number of trailing characters left readable by RedactMask.
+	Keep int `yaml:"keep"`
+}
+
+// EventConfig declares an event type accepted at POST /events/{type}, or
+// overrides the routing of a built-in type such as log_entry.
+type EventConfig struct {
+	Name string `yaml:"name"`
+	// Schema is the path of the JSON Schema describing the payload. It is
+	// required for types that are not built in.
+	Schema string `yaml:"schema"`
+	// Sinks lists the sinks batches of this type are delivered to. When empty,
+	// batches are delivered to every sink.
+	Sinks []string `yaml:"sinks"`
+	// Transforms names the transforms applied, in order, to every event before
+	// it is batched.
+	Transforms []string `yaml:"transforms"`
+}
+
+// TransformConfig names a jq program that rewrites records. The program's
+// first output replaces the record; a program with no output, or whose output
+// is null, drops it.
+type TransformConfig struct {
+	Name string `yaml:"name"`
+	JQ   string `yaml:"jq"`
+}
+
+// TailConfig configures GET /tail, which streams accepted events to admins.
+type TailConfig struct {
+	// Buffer is the number of events buffered per subscriber, 256 by default.
+	// Subscribers that fall further behind are disconnected.
+	Buffer int `yaml:"buffer"`
+	// Redact lists the rules applied to every event streamed. When omitted,
+	// DefaultTailRedact applies; an empty list streams events unredacted.
+	Redact []RedactRule `yaml:"redact"`
+}
+
+// DefaultTailRedact truncates login IPs and masks phone numbers of log_entry
+// events streamed by GET /tail.
+var DefaultTailRedact = []RedactRule{
+	{Field: "/meta/logins/*/ip", Action: RedactTruncateIP},
+	{Field: "/meta/phone_numbers/home", Action: RedactMask, Keep: 3},
+	{Field: "/meta/phone_numbers/mobile", Action: RedactMask, Keep: 3},
+}
+
+// DetectionConfig enables suspicious-login detection on log_entry events.
+// Each rule is disabled while its threshold is zero.
+type DetectionConfig struct {
+	// AlertSink names the sink alerts are delivered to. Detection is disabled
+	// when it is empty. Event types that do not list their sinks are not
+	// delivered to it.
+	AlertSink string `yaml:"alert_sink"`
+	// MaxSpeedKMH flags two logins whose locations are further apart than
+	// could be travelled at this speed. It requires GEOIP_DATABASES.
+	MaxSpeedKMH float64 `yaml:"max_speed_kmh"`
+	// DistinctIPs flags more than this many IPs within DistinctIPWindow.
+	DistinctIPs      int           `yaml:"distinct_ips"`
+	DistinctIPWindow time.Duration `yaml:"distinct_ip_window"`
+	// PrivateIPs flags logins from private, loopback or otherwise reserved IPs.
+	PrivateIPs bool `yaml:"private_ips"`
+	// MaxUsers bounds the number of users whose recent logins are kept; the
+	// least recently seen are evicted first. Users not seen for IdleTTL are
+	// evicted as well.
+	MaxUsers int           `yaml:"max_users"`
+	IdleTTL  time.Duration `yaml:"idle_ttl"`
+}
+
+// Enabled reports whether an alert sink is configured.
+func (d DetectionConfig) Enabled() bool {
+	return d.AlertSink != ""
+}
+
+// fileConfig is the layout of the YAML document named by CONFIG_FILE.
+type fileConfig struct {
+	Sinks      []SinkConfig      `yaml:"sinks"`
+	Events     []EventConfig     `yaml:"events"`
+	Detection  DetectionConfig   `yaml:"detection"`
+	Transforms []TransformConfig `yaml:"transforms"`
+	Tail       TailConfig        `yaml:"tail"`
+}
+
+// loadFile reads the YAML document at path into cfg. An empty path leaves cfg
+// with a single default sink posting to cfg.PostEndpoint.
+func loadFile(path string, cfg *Config) error {
+	var fc fileConfig
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(raw, &fc); err != nil {
+			return err
+		}
+	}
+	if len(fc.Sinks) == 0 {
+		fc.Sinks = []SinkConfig{{Name: DefaultSink, Type: "http", URL: cfg.PostEndpoint}}
+	}
+
+	transforms := make(map[string]bool, len(fc.Transforms))
+	for i, t := range fc.Transforms {
+		if t.Name == "" || transforms[t.Name] {
+			return fmt.Errorf("transform %d: missing or duplicate name %q", i, t.Name)
+		}
+		transforms[t.Name] = true
+		if err := compileJQ(t.JQ); err != nil {
+			return fmt.Errorf("transform %q: %w", t.Name, err)
+		}
+	}
+
+	sinks := make(map[string]bool, len(fc.Sinks))
+	var protobufSinks []string
+	for i, s := range fc.Sinks {
+		if s.Name == "" || sinks[s.Name] {
+			return fmt.Errorf("sink %d: missing or duplicate name %q", i, s.Name)
+		}
+		sinks[s.Name] = true
+		switch s.Type {
+		case "", "http":
+			if s.URL == "" {
+				return fmt.Errorf("sink %q: url is required", s.Name)
+			}
+			fc.Sinks[i].Type = "http"
+		case "file":
+			if err := defaultArchive(&fc.Sinks[i]); err != nil {
+				return fmt.Errorf("sink %q: %w", s.Name, err)
+			}
+			s = fc.Sinks[i]
+		default:
+			return fmt.Errorf("sink %q: unknown type %q", s.Name, s.Type)
+		}
+		switch s.Format {
+		case "":
+			fc.Sinks[i].Format = FormatJSON
+		case FormatJSON, FormatNDJSON, FormatCloudEvents, FormatEnvelope:
+		case FormatProtobuf:
+			// Records must keep the shape of the message they are encoded as.
+			if len(s.Transforms) > 0 || len(s.Encrypt) > 0 {
+				return fmt.Errorf("sink %q: format protobuf cannot be combined with transforms or encrypt", s.Name)
+			}
+			for _, rule := range s.Redact {
+				if rule.Action != RedactDrop && !stringFields(logEntryType, jsonptr.Split(rule.Field)) {
+					return fmt.Errorf("sink %q: redact %q: format protobuf cannot %s a field that is not a string", s.Name, rule.Field, rule.Action)
+				}
+			}
+			protobufSinks = append(protobufSinks, s.Name)
+		default:
+			return fmt.Errorf("sink %q: unknown format %q", s.Name, s.Format)
+		}
+		for _, rule := range s.Redact {
+			if err := validateRedactRule(rule, cfg.RedactHMACKey); err != nil {
+				return fmt.Errorf("sink %q: redact %q: %w", s.Name, rule.Field, err)
+			}
+		}
+		for _, field := range s.Encrypt {
+			if !strings.HasPrefix(field, "/") {
+				return fmt.Errorf("sink %q: encrypt %q: field must be a JSON pointer", s.Name, field)
+			}
+		}
+		if len(s.Encrypt) > 0 && cfg.EncryptionKeyring == "" {
+			return fmt.Errorf("sink %q: encrypt requires ENCRYPTION_KEYRING", s.Name)
+		}
+		for _, name := range s.Transforms {
+			if !transforms[name] {
+				return fmt.Errorf("sink %q: unknown transform %q", s.Name, name)
+			}
+		}
+		rules := make(map[string]bool, len(s.Filter))
+		for j, rule := range s.Filter {
+			if rule.Name == "" || rules[rule.Name] {
+				return fmt.Errorf("sink %q: filter %d: missing or duplicate name %q", s.Name, j, rule.Name)
+			}
+			rules[rule.Name] = true
+			if err := validateFilterRule(&fc.Sinks[i].Filter[j]); err != nil {
+				return fmt.Errorf("sink %q: filter %q: %w", s.Name, rule.Name, err)
+			}
+		}
+	}
+
+	events := make(map[string]bool, len(fc.Events))
+	for i, e := range fc.Events {
+		if e.Name == "" || events[e.Name] {
+			return fmt.Errorf("event %d: missing or duplicate name %q", i, e.Name)
+		}
+		events[e.Name] = true
+		for _, name := range e.Sinks {
+			if !sinks[name] {
+				return fmt.Errorf("event %q: unknown sink %q", e.Name, name)
+			}
+		}
+		for _, name := range e.Transforms {
+			if !transforms[name] {
+				return fmt.Errorf("event %q: unknown transform %q", e.Name, name)
+			}
+		}
+		if e.Name == logEntryEvent && len(e.Transforms) > 0 {
+			for _, name := range protobufSinks {
+				if len(e.Sinks) == 0 || slices.Contains(e.Sinks, name) {
+					return fmt.Errorf("event %q: transforms cannot be used with protobuf sink %q", e.Name, name)
+				}
+			}
+		}
+	}
+
+	if fc.Detection.Enabled() {
+		if !sinks[fc.Detection.AlertSink] {
+			return fmt.Errorf("detection: unknown alert sink %q", fc.Detection.AlertSink)
+		}
+		if err := defaultDetection(&fc.Detection, cfg); err != nil {
+			return fmt.Errorf("detection: %w", err)
+		}
+	}
+
+	if fc.Tail.Buffer < 0 {
+		return fmt.Errorf("tail: buffer must not be negative, got %d", fc.Tail.Buffer)
+	}
+	if fc.Tail.Buffer == 0 {
+		fc.Tail.Buffer = 256
+	}
+	if fc.Tail.Redact == nil {
+		fc.Tail.Redact = DefaultTailRedact
+	}
+	for _, rule := range fc.Tail.Redact {
+		if err := validateRedactRule(rule, cfg.RedactHMACKey); err != nil {
+			return fmt.Errorf("tail: redact %q: %w", rule.Field, err)
+		}
+	}
+
+	cfg.Sinks = fc.Sinks
+	cfg.Events = fc.Events
+	cfg.Detection = fc.Detection
+	cfg.Transforms = fc.Transforms
+	cfg.Tail = fc.Tail
+	return nil
+}
+
+// defaultArchive checks the archive settings of a file sink and fills in their
+// defaults. Archives are always written as NDJSON.
+func defaultArchive(s *SinkConfig) error {
+	a := &s.Archive
+	if a.Dir == "" {
+		return fmt.Errorf("archive.dir is required")
+	}
+	if s.Format != "" && s.Format != FormatNDJSON {
+		return fmt.Errorf("file sinks are always written as %s", FormatNDJSON)
+	}
+	s.Format = FormatNDJSON
+	if a.SegmentBytes < 0 || a.SegmentAge < 0 || a.MaxAge < 0 || a.MaxBytes < 0 {
+		return fmt.Errorf("archive limits must not be negative")
+	}
+	if a.SegmentBytes == 0 {
+		a.SegmentBytes = 64 << 20
+	}
+	if a.SegmentAge == 0 {
+		a.SegmentAge = time.Hour
+	}
+	return nil
+}
+
+// logEntryEvent is event.LogEntry, which this package cannot import.
+const logEntryEvent = "log_entry"
+
+var (
+	logEntryType    = reflect.TypeOf(model.LogEntry{})
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// stringFields reports whether every scalar field of t matched by tokens is a
+// string, so that redacting it keeps t decodable. Objects and arrays are left
+// alone by redaction, and tokens matching no field match nothing.
+func stringFields(t reflect.Type, tokens []string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if len(tokens) == 0 {
+		switch t.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			return true
+		case reflect.Struct:
+			return !reflect.PointerTo(t).Implements(unmarshalerType)
+		}
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return stringFields(t.Elem(), tokens[1:])
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if (tokens[0] == jsonptr.Wildcard || tokens[0] == name) && !stringFields(f.Type, tokens[1:]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validateFilterRule checks rule and fills in its default Key.
+func validateFilterRule(rule *FilterRule) error {
+	switch rule.Action {
+	case FilterKeep, FilterDrop:
+	case FilterSample:
+		if rule.Rate < 0 || rule.Rate > 1 {
+			return fmt.Errorf("rate must be between 0 and 1, got %g", rule.Rate)
+		}
+		if rule.Key == "" {
+			rule.Key = "/user_id"
+		}
+		if !strings.HasPrefix(rule.Key, "/") {
+			return fmt.Errorf("key must be a JSON pointer")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if rule.When != "" {
+		return compileJQ(rule.When)
+	}
+	return nil
+}
+
+// compileJQ reports whether src is a valid jq program.
+func compileJQ(src string) error {
+	if strings.TrimSpace(src) == "" {
+		return fmt.Errorf("jq is required")
+	}
+	q, err := gojq.Parse(src)
+	if err != nil {
+		return err
+	}
+	_, err = gojq.Compile(q)
+	return err
+}
+
+func defaultDetection(d *DetectionConfig, cfg *Config) error {
+	if d.MaxSpeedKMH < 0 || d.DistinctIPs < 0 || d.DistinctIPWindow < 0 || d.MaxUsers < 0 || d.IdleTTL < 0 {
+		return fmt.Errorf("thresholds must not be negative")
+	}
+	if d.MaxSpeedKMH > 0 && len(cfg.GeoIPDatabases) == 0 {
+		return fmt.Errorf("max_speed_kmh requires GEOIP_DATABASES")
+	}
+	if d.DistinctIPs > 0 && d.DistinctIPWindow == 0 {
+		d.DistinctIPWindow = time.Hour
+	}
+	if d.MaxUsers == 0 {
+		d.MaxUsers = 100000
+	}
+	if d.IdleTTL == 0 {
+		d.IdleTTL = 24 * time.Hour
+	}
+	return nil
+}
+
+func validateRedactRule(rule RedactRule, hmacKey string) error {
+	if !strings.HasPrefix(rule.Field, "/") {
+		return fmt.Errorf("field must be a JSON pointer")
+	}
+	switch rule.Action {
+	case RedactMask, RedactTruncateIP, RedactDrop:
+	case RedactHash:
+		if hmacKey == "" {
+			return fmt.Errorf("hash requires REDACT_HMAC_KEY")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if rule.Keep < 0 {
+		return fmt.Errorf("keep must not be negative")
+	}
+	return nil
+}
+
+// Event returns the configuration for the named event type.
+func (c *Config) Event(name string) (EventConfig, bool) {
+	for _, e := range c.Events {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return EventConfig{}, false
+}
@@ -0,0 +1,217 @@
+// Package decimal carries exact decimal amounts through decoding, validation
+// and re-encoding without the rounding artifacts of float64.
+package decimal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// number matches a JSON number literal.
+var number = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?([eE][+-]?\d+)?$`)
+
+// MaxExponent bounds the exponent of a literal such as 1e308, so that every
+// Decimal has an exact value that can be computed cheaply.
+const MaxExponent = 1000
+
+// Decimal is an exact decimal number that remembers the text it was parsed
+// from. It is encoded back to JSON exactly as it was received.
+type Decimal struct {
+	text string
+}
+
+// Parse parses a JSON number literal such as "99.990" or "1e3".
+func Parse(s string) (Decimal, error) {
+	if !number.MatchString(s) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if !exponentInRange(s) {
+		return Decimal{}, fmt.Errorf("decimal %q out of range", s)
+	}
+	return Decimal{text: s}, nil
+}
+
+// exponentInRange reports whether the exponent of the number literal s, if
+// any, is within MaxExponent.
+func exponentInRange(s string) bool {
+	i := strings.IndexAny(s, "eE")
+	if i < 0 {
+		return true
+	}
+	exp, err := strconv.Atoi(s[i+1:])
+	return err == nil && exp >= -MaxExponent && exp <= MaxExponent
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// String returns the original text, or "0" for the zero value.
+func (d Decimal) String() string {
+	if d.text == "" {
+		return "0"
+	}
+	return d.text
+}
+
+// IsZero reports whether d is numerically zero.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d, and 0 if d has no
+// exact value.
+func (d Decimal) Sign() int {
+	r, ok := d.Rat()
+	if !ok {
+		return 0
+	}
+	return r.Sign()
+}
+
+// Rat returns the exact value of d. It reports false if d has none, which
+// only happens to a Decimal not built by Parse or decoding.
+func (d Decimal) Rat() (*big.Rat, bool) {
+	return new(big.Rat).SetString(d.String())
+}
+
+// Float64 returns the nearest float64 to d, or NaN if d has no exact value.
+func (d Decimal) Float64() float64 {
+	r, ok := d.Rat()
+	if !ok {
+		return math.NaN()
+	}
+	f, _ := r.Float64()
+	return f
+}
+
+// Scale returns the number of significant digits after the decimal point,
+// ignoring trailing zeros: 99.990 and 9.999e1 both have scale 2.
+func (d Decimal) Scale() int {
+	digits, exp := d.digits()
+	if scale := len(digits) - exp; scale > 0 {
+		return scale
+	}
+	return 0
+}
+
+// Precision returns the number of digits needed to write d without an
+// exponent, excluding leading zeros and trailing fractional zeros: 123.450 has
+// precision 5 and 0.05 has precision 2.
+func (d Decimal) Precision() int {
+	digits, exp := d.digits()
+	if digits == "" {
+		return 0
+	}
+	intDigits := exp
+	if intDigits < 0 {
+		intDigits = 0
+	}
+	return intDigits + d.Scale()
+}
+
+// digits returns the significant digits of d without leading or trailing
+// zeros, and the position of the decimal point relative to the first of them:
+// 123.45 yields ("12345", 3) and 0.05 yields ("5", -1).
+func (d Decimal) digits() (string, int) {
+	mantissa, exp := strings.TrimPrefix(d.String(), "-"), 0
+	if i := strings.IndexAny(mantissa, "eE"); i >= 0 {
+		exp, _ = strconv.Atoi(mantissa[i+1:])
+		mantissa = mantissa[:i]
+	}
+	intPart, frac, _ := strings.Cut(mantissa, ".")
+	all := intPart + frac
+	exp += len(intPart)
+
+	trimmed := strings.TrimLeft(all, "0")
+	exp -= len(all) - len(trimmed)
+	return strings.TrimRight(trimmed, "0"), exp
+}
+
+// MarshalJSON encodes d as a JSON number using its original text.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number and keeps its text verbatim.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if !number.Match(data) {
+		// Reported like the float64 this type replaces so clients see the same error.
+		return &json.UnmarshalTypeError{Value: jsonKind(data), Type: reflect.TypeOf(float64(0))}
+	}
+	if !exponentInRange(string(data)) {
+		// As encoding/json reports a float64 overflow.
+		return &json.UnmarshalTypeError{Value: "number " + string(data), Type: reflect.TypeOf(float64(0))}
+	}
+	d.text = string(data)
+	return nil
+}
+
+func jsonKind(data []byte) string {
+	switch {
+	case len(data) == 0:
+		return "value"
+	case data[0] == '"':
+		return "string"
+	case data[0] == '{':
+		return "object"
+	case data[0] == '[':
+		return "array"
+	case data[0] == 't' || data[0] == 'f':
+		return "bool"
+	default:
+		return "value"
+	}
+}
+
+// RegisterValidations teaches v to validate Decimal fields. Numeric tags such
+// as required and gt compare the value as a float64, and the maxscale and
+// maxprecision tags bound Scale and Precision.
+func RegisterValidations(v *validator.Validate) error {
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(Decimal).Float64()
+	}, Decimal{})
+	if err := v.RegisterValidation("maxscale", limit(Decimal.Scale)); err != nil {
+		return err
+	}
+	return v.RegisterValidation("maxprecision", limit(Decimal.Precision))
+}
+
+// limit builds a validation that passes when measure of the field is at most
+// the tag parameter. The custom type func hands validations a float64, so the
+// original Decimal is read from the parent struct.
+func limit(measure func(Decimal) int) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		max, err := strconv.Atoi(fl.Param())
+		if err != nil {
+			panic(fmt.Sprintf("invalid %s parameter %q", fl.GetTag(), fl.Param()))
+		}
+		parent := fl.Parent()
+		for parent.Kind() == reflect.Ptr {
+			parent = parent.Elem()
+		}
+		if parent.Kind() != reflect.Struct {
+			return true
+		}
+		d, ok := parent.FieldByName(fl.StructFieldName()).Interface().(Decimal)
+		return !ok || measure(d) <= max
+	}
+}
@@ -0,0 +1,149 @@
+package decimal
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestScaleAndPrecision(t *testing.T) {
+	tests := []struct {
+		text      string
+		scale     int
+		precision int
+	}{
+		{"0", 0, 0},
+		{"99.99", 2, 4},
+		{"99.990", 2, 4},
+		{"0.05", 2, 2},
+		{"-123.450", 2, 5},
+		{"100", 0, 3},
+		{"1e3", 0, 4},
+		{"9.999e1", 2, 4},
+		{"1.5E-3", 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			d := MustParse(tt.text)
+			assert.Equal(t, tt.scale, d.Scale())
+			assert.Equal(t, tt.precision, d.Precision())
+		})
+	}
+}
+
+func TestJSONRoundTripKeepsText(t *testing.T) {
+	var v struct {
+		Total Decimal `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"total": 99.990}`), &v))
+	assert.Equal(t, "99.990", v.Total.String())
+	assert.Equal(t, 1, v.Total.Sign())
+
+	out, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"total":99.990}`, string(out))
+
+	err = json.Unmarshal([]byte(`{"total":"99.99"}`), &v)
+	var typeErr *json.UnmarshalTypeError
+	if assert.ErrorAs(t, err, &typeErr) {
+		assert.Equal(t, "string", typeErr.Value)
+	}
+
+	_, err = Parse("0x10")
+	assert.Error(t, err)
+}
+
+func TestMsgpack(t *testing.T) {
+	type payment struct {
+		Total Decimal `msgpack:"total"`
+	}
+	for in, want := range map[any]string{
+		"99.90":       "99.90",
+		int64(100):    "100",
+		uint8(7):      "7",
+		float32(99.9): "99.9",
+		float64(0.05): "0.05",
+		int8(-3):      "-3",
+		"1e3":         "1e3",
+	} {
+		raw, err := msgpack.Marshal(map[string]any{"total": in})
+		assert.NoError(t, err)
+		var p payment
+		assert.NoError(t, msgpack.Unmarshal(raw, &p), in)
+		assert.Equal(t, want, p.Total.String(), in)
+	}
+
+	for _, in := range []any{"ten", "1e5000000", math.NaN(), math.Inf(1), float32(math.Inf(-1))} {
+		raw, err := msgpack.Marshal(map[string]any{"total": in})
+		assert.NoError(t, err)
+		assert.Error(t, msgpack.Unmarshal(raw, &payment{}), in)
+	}
+
+	raw, err := msgpack.Marshal(payment{Total: MustParse("99.90")})
+	assert.NoError(t, err)
+	var back payment
+	assert.NoError(t, msgpack.Unmarshal(raw, &back))
+	assert.Equal(t, "99.90", back.Total.String())
+}
+
+func TestRegisterValidations(t *testing.T) {
+	type payment struct {
+		Amount Decimal `validate:"required,gt=0,maxscale=2,maxprecision=6"`
+	}
+	v := validator.New()
+	assert.NoError(t, RegisterValidations(v))
+
+	for text, tag := range map[string]string{
+		"0":        "required",
+		"-1":       "gt",
+		"0.001":    "maxscale",
+		"1234.567": "maxscale",
+		"12345.67": "maxprecision",
+		"9999.99":  "",
+		"0.10":     "",
+	} {
+		t.Run(text, func(t *testing.T) {
+			err := v.Struct(payment{Amount: MustParse(text)})
+			if tag == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var errs validator.ValidationErrors
+			if assert.ErrorAs(t, err, &errs) {
+				assert.Equal(t, tag, errs[0].Tag())
+			}
+		})
+	}
+}
+
+func TestExponentOutOfRange(t *testing.T) {
+	for _, text := range []string{"1e5000000", "1e-99999999999", "-2.5E1001", "1e99999999999999999999"} {
+		t.Run(text, func(t *testing.T) {
+			_, err := Parse(text)
+			assert.ErrorContains(t, err, "out of range")
+
+			var v struct {
+				Total Decimal `json:"total"`
+			}
+			err = json.Unmarshal([]byte(`{"total":`+text+`}`), &v)
+			var typeErr *json.UnmarshalTypeError
+			if assert.ErrorAs(t, err, &typeErr) {
+				assert.Equal(t, "number "+text, typeErr.Value)
+			}
+		})
+	}
+
+	for _, text := range []string{"1e1000", "1e-1000", "1E+0001"} {
+		d := MustParse(text)
+		_, ok := d.Rat()
+		assert.True(t, ok, text)
+	}
+	_, ok := Decimal{text: "1e5000000"}.Rat()
+	assert.False(t, ok)
+	assert.True(t, math.IsNaN(Decimal{text: "1e5000000"}.Float64()))
+	assert.Zero(t, Decimal{text: "1e5000000"}.Sign())
+}
@@ -0,0 +1,68 @@
+package decimal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// EncodeMsgpack encodes d as a string holding its original text, since
+// MessagePack floats cannot carry trailing zeros.
+func (d Decimal) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.EncodeString(d.String())
+}
+
+// DecodeMsgpack accepts an integer, a float, or a string holding a JSON number
+// literal, which is how amounts such as 99.90 keep their scale.
+func (d *Decimal) DecodeMsgpack(dec *msgpack.Decoder) error {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+	switch {
+	case code == msgpcode.Nil:
+		return dec.DecodeNil()
+	case msgpcode.IsString(code):
+		s, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		*d, err = Parse(s)
+		return err
+	case code == msgpcode.Float:
+		f, err := dec.DecodeFloat32()
+		if err != nil {
+			return err
+		}
+		*d, err = fromFloat(float64(f), 32)
+		return err
+	case code == msgpcode.Double:
+		f, err := dec.DecodeFloat64()
+		if err != nil {
+			return err
+		}
+		*d, err = fromFloat(f, 64)
+		return err
+	case msgpcode.IsFixedNum(code) || code >= msgpcode.Uint8 && code <= msgpcode.Int64:
+		i, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		d.text = strconv.FormatInt(i, 10)
+		return nil
+	default:
+		return fmt.Errorf("msgpack: cannot decode code %x into decimal", code)
+	}
+}
+
+// fromFloat returns the shortest Decimal that reads back as f in bitSize
+// bits. NaN and infinities have no decimal value.
+func fromFloat(f float64, bitSize int) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("msgpack: cannot decode %v into decimal", f)
+	}
+	return Parse(strconv.FormatFloat(f, 'f', -1, bitSize))
+}
@@ -0,0 +1,40 @@
+package detect
+
+import (
+	"benzinga-webhook/internal/batcher"
+	"benzinga-webhook/internal/model"
+)
+
+// stage runs a Detector on entries on their way to a batcher.
+type stage struct {
+	detector *Detector
+	next     batcher.Batcher[model.LogEntry]
+	alerts   batcher.Batcher[model.LoginAlert]
+}
+
+// Wrap returns a batcher that passes every entry to d before adding it to
+// next, and adds the alerts raised to alerts. Starting and stopping it starts
+// and stops both batchers.
+func Wrap(d *Detector, next batcher.Batcher[model.LogEntry], alerts batcher.Batcher[model.LoginAlert]) batcher.Batcher[model.LogEntry] {
+	return &stage{detector: d, next: next, alerts: alerts}
+}
+
+func (s *stage) Add(entry model.LogEntry) {
+	for _, a := range s.detector.Observe(entry) {
+		s.alerts.Add(a)
+	}
+	s.next.Add(entry)
+}
+
+// Load returns the load of next, which entries are added to.
+func (s *stage) Load() float64 { return batcher.LoadOf(s.next) }
+
+func (s *stage) Start() {
+	go s.alerts.Start()
+	s.next.Start()
+}
+
+func (s *stage) Stop() {
+	s.next.Stop()
+	s.alerts.Stop()
+}
@@ -0,0 +1,277 @@
+// Package detect flags suspicious logins by tracking the recent logins of each
+// user across log entries.
+package detect
+
+import (
+	"container/list"
+	"math"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/timestamp"
+)
+
+// Event is the name alert batches are delivered under.
+const Event = "login_alert"
+
+// Alert kinds, as found in model.LoginAlert.Kind.
+const (
+	// KindImpossibleTravel flags two logins too far apart to be travelled
+	// between in the time separating them.
+	KindImpossibleTravel = "impossible_travel"
+	// KindDistinctIPs flags more distinct IPs within the window than allowed.
+	KindDistinctIPs = "distinct_ips"
+	// KindPrivateIP flags a login from a private or reserved IP.
+	KindPrivateIP = "private_ip"
+)
+
+const (
+	// maxLogins bounds the logins kept per user; the oldest are dropped first.
+	maxLogins = 32
+	// minTravelKM ignores distances within the accuracy of IP geolocation.
+	minTravelKM   = 100
+	earthRadiusKM = 6371
+)
+
+// reserved lists special-purpose ranges not covered by the netip.Addr
+// predicates, see RFC 6890.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// login is a login as tracked for a user.
+type login struct {
+	model.Login
+	at time.Time
+}
+
+// user is the detection state kept for one user.
+type user struct {
+	id     int
+	logins []login // oldest first
+	seen   time.Time
+	// distinctAlert is the time of the login that last raised a
+	// KindDistinctIPs alert, so that one is raised per window.
+	distinctAlert time.Time
+}
+
+// Detector evaluates the logins of every entry against the rules in a
+// config.DetectionConfig. It is safe for concurrent use.
+type Detector struct {
+	cfg config.DetectionConfig
+	// Now returns the server time used for idle eviction. It defaults to time.Now.
+	Now func() time.Time
+
+	mu    sync.Mutex
+	users map[int]*list.Element // of *user
+	order *list.List            // most recently seen first
+}
+
+// New returns a Detector for cfg.
+func New(cfg config.DetectionConfig) *Detector {
+	return &Detector{cfg: cfg, Now: time.Now, users: make(map[int]*list.Element), order: list.New()}
+}
+
+func (d *Detector) now() time.Time {
+	if d.Now == nil {
+		return time.Now()
+	}
+	return d.Now()
+}
+
+// Users returns the number of users whose state is kept.
+func (d *Detector) Users() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.order.Len()
+}
+
+// Observe records the logins of entry and returns the alerts raised by those
+// not seen before. Logins are expected in the form timestamp.Logins leaves
+// them in; ones whose time cannot be parsed are ignored.
+func (d *Detector) Observe(entry model.LogEntry) []model.LoginAlert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.evict(now)
+	u := d.user(entry.UserID, now)
+
+	var alerts []model.LoginAlert
+	detectedAt := now.UTC().Format(timestamp.Layout)
+	for _, l := range entry.Meta.Logins {
+		at, err := time.Parse(time.RFC3339, l.Time)
+		if err != nil || !u.add(login{Login: l, at: at}) {
+			continue
+		}
+		if d.cfg.PrivateIPs && private(l.IP) {
+			alerts = append(alerts, model.LoginAlert{Kind: KindPrivateIP, Logins: []model.Login{l}})
+		}
+		if d.cfg.MaxSpeedKMH > 0 {
+			if a, ok := d.travel(u, login{Login: l, at: at}); ok {
+				alerts = append(alerts, a)
+			}
+		}
+		if d.cfg.DistinctIPs > 0 {
+			if a, ok := d.distinct(u, at); ok {
+				alerts = append(alerts, a)
+			}
+		}
+	}
+	for i := range alerts {
+		alerts[i].UserID = entry.UserID
+		alerts[i].DetectedAt = detectedAt
+	}
+	return alerts
+}
+
+// user returns the state of id, creating it and evicting the least recently
+// seen user when MaxUsers is reached.
+func (d *Detector) user(id int, now time.Time) *user {
+	if el, ok := d.users[id]; ok {
+		d.order.MoveToFront(el)
+		u := el.Value.(*user)
+		u.seen = now
+		return u
+	}
+	if d.cfg.MaxUsers > 0 && d.order.Len() >= d.cfg.MaxUsers {
+		d.remove(d.order.Back())
+	}
+	u := &user{id: id, seen: now}
+	d.users[id] = d.order.PushFront(u)
+	return u
+}
+
+// evict drops users not seen for IdleTTL.
+func (d *Detector) evict(now time.Time) {
+	if d.cfg.IdleTTL <= 0 {
+		return
+	}
+	for el := d.order.Back(); el != nil && now.Sub(el.Value.(*user).seen) > d.cfg.IdleTTL; el = d.order.Back() {
+		d.remove(el)
+	}
+}
+
+func (d *Detector) remove(el *list.Element) {
+	d.order.Remove(el)
+	delete(d.users, el.Value.(*user).id)
+}
+
+// add inserts l in time order. It reports false when l is already known, or
+// is older than every kept login while the user is at maxLogins.
+func (u *user) add(l login) bool {
+	for _, known := range u.logins {
+		if known.at.Equal(l.at) && known.IP == l.IP {
+			return false
+		}
+	}
+	if len(u.logins) >= maxLogins && !l.at.After(u.logins[0].at) {
+		return false
+	}
+	i := sort.Search(len(u.logins), func(i int) bool { return u.logins[i].at.After(l.at) })
+	u.logins = append(u.logins, login{})
+	copy(u.logins[i+1:], u.logins[i:])
+	u.logins[i] = l
+	if len(u.logins) > maxLogins {
+		u.logins = u.logins[1:]
+	}
+	return true
+}
+
+// travel compares l with every other located login of u and returns an alert
+// for the pair requiring the highest speed, if it exceeds MaxSpeedKMH.
+func (d *Detector) travel(u *user, l login) (model.LoginAlert, bool) {
+	if l.Geo == nil || l.Geo.Location == nil {
+		return model.LoginAlert{}, false
+	}
+	var worst model.LoginAlert
+	for _, other := range u.logins {
+		if other.Geo == nil || other.Geo.Location == nil || (other.at.Equal(l.at) && other.IP == l.IP) {
+			continue
+		}
+		km := distance(*l.Geo.Location, *other.Geo.Location)
+		if km < minTravelKM {
+			continue
+		}
+		// Logins at the same instant count as one second apart so that
+		// the speed stays finite.
+		elapsed := math.Max(math.Abs(l.at.Sub(other.at).Hours()), time.Second.Hours())
+		speed := km / elapsed
+		if speed <= d.cfg.MaxSpeedKMH || speed <= worst.SpeedKMH {
+			continue
+		}
+		pair := []model.Login{other.Login, l.Login}
+		if l.at.Before(other.at) {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		worst = model.LoginAlert{Kind: KindImpossibleTravel, Logins: pair, DistanceKM: round(km), SpeedKMH: round(speed)}
+	}
+	return worst, worst.Kind != ""
+}
+
+// distinct returns an alert when the logins of u within DistinctIPWindow
+// before at come from more than DistinctIPs addresses, unless one was already
+// raised within that window.
+func (d *Detector) distinct(u *user, at time.Time) (model.LoginAlert, bool) {
+	if !u.distinctAlert.IsZero() && at.Sub(u.distinctAlert) < d.cfg.DistinctIPWindow {
+		return model.LoginAlert{}, false
+	}
+	ips := make(map[string]bool)
+	var logins []model.Login
+	for _, l := range u.logins {
+		if l.at.After(at) || at.Sub(l.at) > d.cfg.DistinctIPWindow {
+			continue
+		}
+		ips[l.IP] = true
+		logins = append(logins, l.Login)
+	}
+	if len(ips) <= d.cfg.DistinctIPs {
+		return model.LoginAlert{}, false
+	}
+	u.distinctAlert = at
+	return model.LoginAlert{Kind: KindDistinctIPs, Logins: logins, DistinctIPs: len(ips)}, true
+}
+
+// private reports whether ip is not a routable public unicast address.
+func private(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsMulticast() ||
+		addr.IsUnspecified() || addr.IsInterfaceLocalMulticast() || addr.IsLinkLocalMulticast() {
+		return true
+	}
+	for _, p := range reserved {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// distance returns the great-circle distance between a and b in kilometres.
+func distance(a, b model.Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func round(f float64) float64 {
+	return math.Round(f*10) / 10
+}
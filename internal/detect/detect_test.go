@@ -0,0 +1,146 @@
+package detect
+
+import (
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	london = &model.Geo{Country: "GB", City: "London", Location: &model.Location{Latitude: 51.5142, Longitude: -0.0931}}
+	tokyo  = &model.Geo{Country: "JP", City: "Tokyo", Location: &model.Location{Latitude: 35.685, Longitude: 139.7514}}
+	milton = &model.Geo{Country: "US", City: "Milton", Location: &model.Location{Latitude: 47.2513, Longitude: -122.3149}}
+)
+
+func entry(userID int, logins ...model.Login) model.LogEntry {
+	return model.LogEntry{UserID: userID, Meta: model.Meta{Logins: logins}}
+}
+
+func newDetector(cfg config.DetectionConfig) *Detector {
+	d := New(cfg)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	d.Now = func() time.Time { return now }
+	return d
+}
+
+func TestPrivateIP(t *testing.T) {
+	d := newDetector(config.DetectionConfig{PrivateIPs: true})
+	e := entry(1,
+		model.Login{Time: "2024-06-01T10:00:00Z", IP: "10.1.2.3"},
+		model.Login{Time: "2024-06-01T10:05:00Z", IP: "81.2.69.142"},
+		model.Login{Time: "2024-06-01T10:10:00Z", IP: "203.0.113.9"},
+		model.Login{Time: "2024-06-01T10:15:00Z", IP: "::ffff:127.0.0.1"},
+	)
+
+	alerts := d.Observe(e)
+	require.Len(t, alerts, 3)
+	for i, ip := range []string{"10.1.2.3", "203.0.113.9", "::ffff:127.0.0.1"} {
+		assert.Equal(t, KindPrivateIP, alerts[i].Kind)
+		assert.Equal(t, 1, alerts[i].UserID)
+		assert.Equal(t, "2024-06-01T12:00:00Z", alerts[i].DetectedAt)
+		assert.Equal(t, ip, alerts[i].Logins[0].IP)
+	}
+
+	assert.Empty(t, d.Observe(e), "logins already seen raise no alerts")
+}
+
+func TestImpossibleTravel(t *testing.T) {
+	d := newDetector(config.DetectionConfig{MaxSpeedKMH: 900})
+	first := model.Login{Time: "2024-06-01T08:00:00Z", IP: "81.2.69.142", Geo: london}
+
+	assert.Empty(t, d.Observe(entry(1, first)))
+	assert.Empty(t, d.Observe(entry(1, first, model.Login{Time: "2024-06-02T08:00:00Z", IP: "216.160.83.56", Geo: milton})),
+		"London to Milton in a day is possible")
+	nearby := model.Login{Time: "2024-06-01T08:30:00Z", IP: "81.2.69.1", Geo: london}
+	assert.Empty(t, d.Observe(entry(1, nearby)), "nearby logins are ignored")
+
+	tokyoLogin := model.Login{Time: "2024-06-01T09:00:00Z", IP: "2001:218::7", Geo: tokyo}
+	alerts := d.Observe(entry(1, first, tokyoLogin))
+	require.Len(t, alerts, 1)
+	a := alerts[0]
+	assert.Equal(t, KindImpossibleTravel, a.Kind)
+	assert.Equal(t, []model.Login{nearby, tokyoLogin}, a.Logins, "the fastest pair is reported")
+	assert.InDelta(t, 9560, a.DistanceKM, 20)
+	assert.InDelta(t, 19120, a.SpeedKMH, 40)
+
+	assert.Empty(t, d.Observe(entry(2, model.Login{Time: "2024-06-01T09:00:00Z", IP: "2001:218::7", Geo: tokyo})),
+		"state is kept per user")
+	assert.Empty(t, d.Observe(entry(3, model.Login{Time: "2024-06-01T09:00:00Z", IP: "2001:218::7"})))
+}
+
+func TestDistinctIPs(t *testing.T) {
+	d := newDetector(config.DetectionConfig{DistinctIPs: 2, DistinctIPWindow: time.Hour})
+	login := func(ts, ip string) model.Login { return model.Login{Time: ts, IP: ip} }
+
+	assert.Empty(t, d.Observe(entry(1,
+		login("2024-06-01T08:00:00Z", "81.2.69.1"),
+		login("2024-06-01T08:10:00Z", "81.2.69.2"),
+		login("2024-06-01T08:20:00Z", "81.2.69.1"),
+	)))
+	alerts := d.Observe(entry(1, login("2024-06-01T08:30:00Z", "81.2.69.3")))
+	require.Len(t, alerts, 1)
+	assert.Equal(t, KindDistinctIPs, alerts[0].Kind)
+	assert.Equal(t, 3, alerts[0].DistinctIPs)
+	assert.Len(t, alerts[0].Logins, 4)
+
+	assert.Empty(t, d.Observe(entry(1, login("2024-06-01T08:40:00Z", "81.2.69.4"))), "one alert per window")
+	assert.Empty(t, d.Observe(entry(1, login("2024-06-01T10:00:00Z", "81.2.69.5"))), "older logins leave the window")
+}
+
+func TestStateIsBounded(t *testing.T) {
+	d := newDetector(config.DetectionConfig{PrivateIPs: true, MaxUsers: 2, IdleTTL: time.Hour})
+	now := d.Now()
+	d.Now = func() time.Time { return now }
+	private := model.Login{Time: "2024-06-01T08:00:00Z", IP: "192.168.1.1"}
+
+	for id := 1; id <= 3; id++ {
+		assert.Len(t, d.Observe(entry(id, private)), 1)
+	}
+	assert.Equal(t, 2, d.Users())
+	assert.Len(t, d.Observe(entry(1, private)), 1, "user 1 was evicted as least recently seen")
+	assert.Empty(t, d.Observe(entry(3, private)))
+
+	now = now.Add(2 * time.Hour)
+	assert.Len(t, d.Observe(entry(3, private)), 1, "idle users are evicted")
+	assert.Equal(t, 1, d.Users())
+
+	u := &user{}
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2*maxLogins; i++ {
+		assert.True(t, u.add(login{Login: model.Login{IP: "81.2.69.1"}, at: start.Add(time.Duration(i) * time.Minute)}))
+	}
+	assert.Len(t, u.logins, maxLogins)
+	assert.Equal(t, start.Add(maxLogins*time.Minute), u.logins[0].at, "the oldest logins are dropped")
+	assert.False(t, u.add(login{Login: model.Login{IP: "81.2.69.2"}, at: start}))
+}
+
+type captureBatcher[T any] struct {
+	entries []T
+	started bool
+	stopped bool
+}
+
+func (c *captureBatcher[T]) Add(entry T) { c.entries = append(c.entries, entry) }
+func (c *captureBatcher[T]) Start()      { c.started = true }
+func (c *captureBatcher[T]) Stop()       { c.stopped = true }
+
+func TestWrap(t *testing.T) {
+	entries := &captureBatcher[model.LogEntry]{}
+	alerts := &captureBatcher[model.LoginAlert]{}
+	b := Wrap(newDetector(config.DetectionConfig{PrivateIPs: true}), entries, alerts)
+
+	e := entry(1, model.Login{Time: "2024-06-01T08:00:00Z", IP: "127.0.0.1"})
+	b.Add(e)
+	assert.Equal(t, []model.LogEntry{e}, entries.entries)
+	require.Len(t, alerts.entries, 1)
+	assert.Equal(t, KindPrivateIP, alerts.entries[0].Kind)
+
+	b.Stop()
+	assert.True(t, entries.stopped)
+	assert.True(t, alerts.stopped)
+}
@@ -0,0 +1,368 @@
+// Package envelope encrypts individual fields of outbound records with
+// envelope encryption: every value is sealed with a fresh AES-256-GCM data key,
+// which is itself sealed with a key-encryption key from a keyring.
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"benzinga-webhook/internal/jsonptr"
+)
+
+// Algorithm identifies the scheme in the "enc" member of encrypted values.
+const Algorithm = "aes-256-gcm"
+
+const keySize = 32
+
+// Field is the JSON object an encrypted value is replaced with.
+type Field struct {
+	Enc string `json:"enc"`
+	// KeyID names the key-encryption key that wrapped DataKey.
+	KeyID string `json:"kid"`
+	// DataKey is the data key sealed with the key-encryption key.
+	DataKey string `json:"dek"`
+	// Ciphertext is the JSON encoding of the original value sealed with the
+	// data key, authenticating the JSON pointer of the value in its record.
+	Ciphertext string `json:"ct"`
+}
+
+// Keyring holds key-encryption keys by ID. New values are always encrypted
+// with the current key; the others are kept so older records can still be
+// decrypted after a rotation.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// keyringFile is the layout of a keyring file: base64-encoded 32-byte keys by
+// ID and the ID of the key used for encryption.
+type keyringFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// LoadKeyring reads a keyring file.
+func LoadKeyring(path string) (*Keyring, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keyring: %w", err)
+	}
+	var kf keyringFile
+	if err := json.Unmarshal(raw, &kf); err != nil {
+		return nil, fmt.Errorf("parse keyring: %w", err)
+	}
+	keys := make(map[string][]byte, len(kf.Keys))
+	for id, encoded := range kf.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return NewKeyring(kf.Current, keys)
+}
+
+// NewKeyring builds a keyring from 32-byte keys. current must be one of them.
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keyring", current)
+	}
+	k := &Keyring{current: current, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" {
+			return nil, errors.New("key IDs must not be empty")
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %q: must be %d bytes, got %d", id, keySize, len(key))
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// Current returns the ID of the key new values are encrypted with.
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce, returning nonce||ciphertext.
+// additional is authenticated but not encrypted.
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+}
+
+// Encrypt seals the JSON encoding of value under a fresh data key wrapped with
+// the current key-encryption key. The Field only decrypts at pointer, the
+// location of value in its record, so it cannot be moved to another field.
+func (k *Keyring) Encrypt(value any, pointer string) (Field, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return Field{}, err
+	}
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Field{}, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return Field{}, err
+	}
+	ct, err := seal(aead, plaintext, []byte(pointer))
+	if err != nil {
+		return Field{}, err
+	}
+	// The key ID is bound to the wrapped key so it cannot be swapped.
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return Field{}, err
+	}
+	return Field{
+		Enc:        Algorithm,
+		KeyID:      k.current,
+		DataKey:    base64.StdEncoding.EncodeToString(wrapped),
+		Ciphertext: base64.StdEncoding.EncodeToString(ct),
+	}, nil
+}
+
+// Decrypt opens f, found at pointer in its record, and decodes the original
+// value with json.Decoder.UseNumber.
+func (k *Keyring) Decrypt(f Field, pointer string) (any, error) {
+	if f.Enc != Algorithm {
+		return nil, fmt.Errorf("unsupported algorithm %q", f.Enc)
+	}
+	kek, ok := k.keys[f.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", f.KeyID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(f.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("data key: %w", err)
+	}
+	ct, err := base64.StdEncoding.DecodeString(f.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("ciphertext: %w", err)
+	}
+	dataKey, err := open(kek, wrapped, []byte(f.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key with %q: %w", f.KeyID, err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, ct, []byte(pointer))
+	if err != nil {
+		return nil, fmt.Errorf("open value: %w", err)
+	}
+	return decode(plaintext)
+}
+
+func decode(raw []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Encryptor encrypts the fields of records matched by a list of JSON pointers.
+// It implements sink.Stage.
+type Encryptor struct {
+	keyring *Keyring
+	fields  [][]string
+}
+
+// NewEncryptor returns an Encryptor for fields, JSON pointers in which "*"
+// matches any array index or object key.
+func NewEncryptor(keyring *Keyring, fields []string) (*Encryptor, error) {
+	if keyring == nil {
+		return nil, errors.New("encryption requires a keyring")
+	}
+	e := &Encryptor{keyring: keyring}
+	for _, f := range fields {
+		if !strings.HasPrefix(f, "/") {
+			return nil, fmt.Errorf("encrypt %q: field must be a JSON pointer", f)
+		}
+		e.fields = append(e.fields, jsonptr.Split(f))
+	}
+	return e, nil
+}
+
+// Apply replaces every matched value in record, except nulls, with its Field.
+func (e *Encryptor) Apply(record json.RawMessage) (json.RawMessage, error) {
+	doc, err := decode(record)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: decode record: %w", err)
+	}
+	var failed error
+	for _, tokens := range e.fields {
+		jsonptr.RewritePath(doc, tokens, func(pointer string, v any) (any, bool) {
+			if v == nil || failed != nil {
+				return v, true
+			}
+			f, err := e.keyring.Encrypt(v, pointer)
+			if err != nil {
+				failed = err
+				return nil, true
+			}
+			return f, true
+		})
+	}
+	if failed != nil {
+		return nil, fmt.Errorf("encrypt: %w", failed)
+	}
+	return json.Marshal(doc)
+}
+
+// DecryptRecord replaces every encrypted value found anywhere in record with
+// the original value.
+func (k *Keyring) DecryptRecord(record json.RawMessage) (json.RawMessage, error) {
+	doc, err := decode(record)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = k.decryptAll(doc, ""); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func (k *Keyring) decryptAll(node any, pointer string) (any, error) {
+	switch n := node.(type) {
+	case map[string]any:
+		if f, ok := asField(n); ok {
+			return k.Decrypt(f, pointer)
+		}
+		for key, child := range n {
+			v, err := k.decryptAll(child, pointer+"/"+jsonptr.Escape(key))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			n[key] = v
+		}
+	case []any:
+		for i, child := range n {
+			v, err := k.decryptAll(child, pointer+"/"+strconv.Itoa(i))
+			if err != nil {
+				return nil, fmt.Errorf("%d: %w", i, err)
+			}
+			n[i] = v
+		}
+	}
+	return node, nil
+}
+
+// asField recognizes an object written by Encrypt.
+func asField(m map[string]any) (Field, bool) {
+	if len(m) != 4 || m["enc"] != Algorithm {
+		return Field{}, false
+	}
+	kid, ok1 := m["kid"].(string)
+	dek, ok2 := m["dek"].(string)
+	ct, ok3 := m["ct"].(string)
+	return Field{Enc: Algorithm, KeyID: kid, DataKey: dek, Ciphertext: ct}, ok1 && ok2 && ok3
+}
+
+// Rotate adds a freshly generated key named id to the keyring file at path,
+// creating the file if it does not exist, and makes it the current key. Older
+// keys are kept so records encrypted with them can still be decrypted.
+func Rotate(path, id string) error {
+	kf := keyringFile{Keys: map[string]string{}}
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(raw, &kf); err != nil {
+			return fmt.Errorf("parse keyring: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("read keyring: %w", err)
+	}
+	if id == "" {
+		return errors.New("key IDs must not be empty")
+	}
+	if _, dup := kf.Keys[id]; dup {
+		return fmt.Errorf("key %q already exists", id)
+	}
+	if kf.Keys == nil {
+		kf.Keys = map[string]string{}
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	kf.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	kf.Current = id
+
+	out, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, append(out, '\n'))
+}
+
+// writeFile replaces the file at path with data atomically, through a
+// temporary file in the same directory, so that a crash or a full disk never
+// leaves the keyring truncated.
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package envelope
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const record = `{"user_id":1,"total":99.990,"meta":{"logins":[{"ip":"203.0.113.7"},{"ip":"198.51.100.1"}],"phone_numbers":{"home":"555-1212-123","mobile":null}}}`
+
+func TestEncryptorRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	require.NoError(t, Rotate(path, "k1"))
+	keyring, err := LoadKeyring(path)
+	require.NoError(t, err)
+
+	e, err := NewEncryptor(keyring, []string{"/meta/logins/*/ip", "/meta/phone_numbers/home", "/meta/phone_numbers/mobile", "/total"})
+	require.NoError(t, err)
+	out, err := e.Apply(json.RawMessage(record))
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(out), "203.0.113.7")
+	assert.NotContains(t, string(out), "555-1212-123")
+	assert.Contains(t, string(out), `"mobile":null`, "nulls are left alone")
+	var doc struct {
+		Meta struct {
+			PhoneNumbers struct {
+				Home Field `json:"home"`
+			} `json:"phone_numbers"`
+		} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(out, &doc))
+	assert.Equal(t, Algorithm, doc.Meta.PhoneNumbers.Home.Enc)
+	assert.Equal(t, "k1", doc.Meta.PhoneNumbers.Home.KeyID)
+
+	plain, err := keyring.DecryptRecord(out)
+	require.NoError(t, err)
+	assert.JSONEq(t, record, string(plain))
+	assert.Contains(t, string(plain), `"total":99.990`)
+}
+
+func TestRotationKeepsOldKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	require.NoError(t, Rotate(path, "k1"))
+	old, err := LoadKeyring(path)
+	require.NoError(t, err)
+	f, err := old.Encrypt("555-1212-123", "/phone")
+	require.NoError(t, err)
+
+	require.NoError(t, Rotate(path, "k2"))
+	assert.EqualError(t, Rotate(path, "k2"), `key "k2" already exists`)
+	rotated, err := LoadKeyring(path)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", rotated.Current())
+
+	v, err := rotated.Decrypt(f, "/phone")
+	require.NoError(t, err)
+	assert.Equal(t, "555-1212-123", v)
+
+	g, err := rotated.Encrypt("x", "/phone")
+	require.NoError(t, err)
+	assert.Equal(t, "k2", g.KeyID)
+	_, err = old.Decrypt(g, "/phone")
+	assert.EqualError(t, err, `unknown key "k2"`)
+
+	// The keyring is replaced through a temporary file that does not linger.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	keyring, err := NewKeyring("a", map[string][]byte{
+		"a": []byte(strings.Repeat("a", 32)),
+		"b": []byte(strings.Repeat("b", 32)),
+	})
+	require.NoError(t, err)
+	f, err := keyring.Encrypt("secret", "/ip")
+	require.NoError(t, err)
+
+	swapped := f
+	swapped.KeyID = "b"
+	_, err = keyring.Decrypt(swapped, "/ip")
+	assert.ErrorContains(t, err, `unwrap data key with "b"`)
+
+	other, err := keyring.Encrypt("other", "/ip")
+	require.NoError(t, err)
+	mixed := f
+	mixed.Ciphertext = other.Ciphertext
+	_, err = keyring.Decrypt(mixed, "/ip")
+	assert.ErrorContains(t, err, "open value")
+
+	_, err = keyring.Decrypt(f, "/name")
+	assert.ErrorContains(t, err, "open value", "a value only decrypts at the pointer it was sealed for")
+}
+
+func TestDecryptRecordRejectsMovedValues(t *testing.T) {
+	keyring, err := NewKeyring("a", map[string][]byte{"a": []byte(strings.Repeat("a", 32))})
+	require.NoError(t, err)
+	e, err := NewEncryptor(keyring, []string{"/meta/logins/*/ip"})
+	require.NoError(t, err)
+	out, err := e.Apply(json.RawMessage(record))
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(out, &doc))
+	logins := doc["meta"].(map[string]any)["logins"].([]any)
+	first, second := logins[0].(map[string]any), logins[1].(map[string]any)
+	first["ip"], second["ip"] = second["ip"], first["ip"]
+	swapped, err := json.Marshal(doc)
+	require.NoError(t, err)
+	_, err = keyring.DecryptRecord(swapped)
+	assert.ErrorContains(t, err, "open value")
+
+	first["ip"], second["ip"] = second["ip"], first["ip"]
+	doc["user_id"] = first["ip"]
+	delete(first, "ip")
+	moved, err := json.Marshal(doc)
+	require.NoError(t, err)
+	_, err = keyring.DecryptRecord(moved)
+	assert.ErrorContains(t, err, "user_id: open value")
+}
+
+func TestNewKeyringValidation(t *testing.T) {
+	_, err := NewKeyring("missing", map[string][]byte{"a": make([]byte, 32)})
+	assert.EqualError(t, err, `current key "missing" is not in the keyring`)
+	_, err = NewKeyring("a", map[string][]byte{"a": make([]byte, 16)})
+	assert.EqualError(t, err, `key "a": must be 32 bytes, got 16`)
+	_, err = NewEncryptor(nil, []string{"/ip"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,315 @@
+// Package event defines the event types accepted by the receiver and the
+// registry used to look them up by name.
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/batcher"
+	"benzinga-webhook/internal/pb"
+	"benzinga-webhook/internal/schema"
+	"benzinga-webhook/internal/versioning"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// LogEntry is the name of the built-in model.LogEntry event type.
+const LogEntry = "log_entry"
+
+// Media types of the binary bodies a Spec can decode, besides JSON.
+const (
+	MediaProtobuf = pb.ContentType
+	MediaMsgpack  = "application/msgpack"
+)
+
+// ErrUnsupportedMediaType is returned by IngestAs for a media type the event
+// type has no decoder for.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// DecodeError reports a request body that could not be decoded.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string { return "decode event: " + e.Err.Error() }
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// ValidationError reports a decoded event that failed validation. Err is set by
+// struct-tag validation and Violations by JSON Schema validation.
+type ValidationError struct {
+	Err        error
+	Violations []apperror.Violation
+}
+
+func (e *ValidationError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%d schema violations", len(e.Violations))
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// Type is an event type accepted at POST /events/{type}.
+type Type interface {
+	Name() string
+	// Versions returns the payload versions of the type, or nil if it is unversioned.
+	Versions() *versioning.Registry
+	// Upgrade converts a JSON body from the version declared, or detected in
+	// the body, to the current version, and returns that version. A body
+	// declared as an older version is first checked against the model of that
+	// version and rejected with a *DecodeError or *ValidationError; any other
+	// error means the version was rejected. Bodies that are not JSON objects,
+	// and the bodies of unversioned types, are returned unchanged.
+	Upgrade(body []byte, declared string) ([]byte, string, error)
+	// Schema returns the JSON Schema describing the payload, or nil.
+	Schema() []byte
+	// Ingest decodes and validates one event and queues it for delivery. It
+	// returns a *DecodeError or *ValidationError when the event is rejected.
+	Ingest(body []byte, trans ut.Translator) error
+	// IngestAs is like Ingest for a body of one of the Spec.Decoders media
+	// types. Such bodies are not versioned.
+	IngestAs(mediaType string, body []byte, trans ut.Translator) error
+	// Load returns the fraction of the batcher queue in use, from 0 to 1, or
+	// 0 when the batcher does not report it.
+	Load() float64
+	Start()
+	Stop()
+}
+
+// Spec describes how events decoded into T are validated and batched.
+type Spec[T any] struct {
+	Name     string
+	Versions *versioning.Registry
+	// Schema validates payloads when Validate is nil or SchemaMode is set.
+	Schema     *schema.Validator
+	SchemaMode bool
+	// Validate checks the validate struct tags of T.
+	Validate *validator.Validate
+	// Prepare runs on every valid event before it is batched.
+	Prepare func(*T)
+	Batcher batcher.Batcher[T]
+	// Decoders decode bodies of other media types than JSON, by media type.
+	Decoders map[string]Decoder[T]
+}
+
+// Decoder decodes a body into entry.
+type Decoder[T any] func(body []byte, entry *T) error
+
+// Msgpack decodes a MessagePack body into entry, matching map keys against
+// the json tags of T.
+func Msgpack[T any](body []byte, entry *T) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(body))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(entry)
+}
+
+type typed[T any] struct {
+	spec Spec[T]
+}
+
+// New returns a Type for events decoded into T.
+func New[T any](spec Spec[T]) Type {
+	return &typed[T]{spec: spec}
+}
+
+func (t *typed[T]) Name() string { return t.spec.Name }
+
+func (t *typed[T]) Versions() *versioning.Registry { return t.spec.Versions }
+
+func (t *typed[T]) Upgrade(body []byte, declared string) ([]byte, string, error) {
+	versions := t.spec.Versions
+	if versions == nil {
+		return body, "", nil
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		// Left for Ingest to report.
+		return body, "", nil
+	}
+
+	version := versions.Detect(doc, declared)
+	var invalid *versioning.InvalidError
+	if err := versions.Check(doc, version, t.spec.Validate); errors.As(err, &invalid) {
+		var verrs validator.ValidationErrors
+		if errors.As(invalid.Err, &verrs) {
+			return nil, version, &ValidationError{Err: verrs}
+		}
+		return nil, version, &DecodeError{Err: invalid.Err}
+	}
+	doc, err := versions.Upgrade(doc, version)
+	if err != nil {
+		return nil, version, err
+	}
+	if body, err = json.Marshal(doc); err != nil {
+		return nil, version, &DecodeError{Err: err}
+	}
+	return body, version, nil
+}
+
+func (t *typed[T]) Schema() []byte {
+	if t.spec.Schema == nil {
+		return nil
+	}
+	return t.spec.Schema.Raw()
+}
+
+func (t *typed[T]) Ingest(body []byte, trans ut.Translator) error {
+	var entry T
+	if t.spec.Validate == nil || t.spec.SchemaMode {
+		if t.spec.Schema != nil {
+			var doc any
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			if err := dec.Decode(&doc); err != nil {
+				return &DecodeError{Err: err}
+			}
+			if violations := t.spec.Schema.Validate(doc, trans); len(violations) > 0 {
+				return &ValidationError{Violations: violations}
+			}
+		}
+		if err := json.Unmarshal(body, &entry); err != nil {
+			return &DecodeError{Err: err}
+		}
+	} else {
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&entry); err != nil {
+			return &DecodeError{Err: err}
+		}
+		if err := t.spec.Validate.Struct(entry); err != nil {
+			return &ValidationError{Err: err}
+		}
+	}
+
+	t.queue(entry)
+	return nil
+}
+
+func (t *typed[T]) IngestAs(mediaType string, body []byte, trans ut.Translator) error {
+	decode, ok := t.spec.Decoders[mediaType]
+	if !ok {
+		return ErrUnsupportedMediaType
+	}
+	var entry T
+	if err := decode(body, &entry); err != nil {
+		return &DecodeError{Err: err}
+	}
+	return t.ingestEntry(entry, trans)
+}
+
+// IngestEntry is like Type.Ingest for an entry already decoded into T, e.g.
+// from a gRPC request. t must have been created by New[T].
+func IngestEntry[T any](t Type, entry T, trans ut.Translator) error {
+	typed, ok := t.(*typed[T])
+	if !ok {
+		return fmt.Errorf("event type %q does not hold %T entries", t.Name(), entry)
+	}
+	return typed.ingestEntry(entry, trans)
+}
+
+// ingestEntry validates entry and queues it.
+func (t *typed[T]) ingestEntry(entry T, trans ut.Translator) error {
+	if t.spec.Validate == nil || t.spec.SchemaMode {
+		if t.spec.Schema != nil {
+			// The schema describes the JSON encoding, so that is what it checks.
+			raw, err := json.Marshal(entry)
+			if err != nil {
+				return &DecodeError{Err: err}
+			}
+			var doc any
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			if err := dec.Decode(&doc); err != nil {
+				return &DecodeError{Err: err}
+			}
+			if violations := t.spec.Schema.Validate(doc, trans); len(violations) > 0 {
+				return &ValidationError{Violations: violations}
+			}
+		}
+	} else if err := t.spec.Validate.Struct(entry); err != nil {
+		return &ValidationError{Err: err}
+	}
+	t.queue(entry)
+	return nil
+}
+
+// queue prepares a valid entry and adds it to the batcher.
+func (t *typed[T]) queue(entry T) {
+	if t.spec.Prepare != nil {
+		t.spec.Prepare(&entry)
+	}
+	t.spec.Batcher.Add(entry)
+}
+
+func (t *typed[T]) Load() float64 { return batcher.LoadOf(t.spec.Batcher) }
+
+func (t *typed[T]) Start() { t.spec.Batcher.Start() }
+
+func (t *typed[T]) Stop() { t.spec.Batcher.Stop() }
+
+// Registry holds the accepted event types by name.
+type Registry struct {
+	types map[string]Type
+}
+
+// NewRegistry creates a registry holding types.
+func NewRegistry(types ...Type) (*Registry, error) {
+	r := &Registry{types: make(map[string]Type, len(types))}
+	for _, t := range types {
+		if err := r.Register(t); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds an event type. Names must be unique.
+func (r *Registry) Register(t Type) error {
+	if t.Name() == "" {
+		return fmt.Errorf("event type name is required")
+	}
+	if _, dup := r.types[t.Name()]; dup {
+		return fmt.Errorf("event type %q is already registered", t.Name())
+	}
+	r.types[t.Name()] = t
+	return nil
+}
+
+// Lookup returns the event type with the given name.
+func (r *Registry) Lookup(name string) (Type, bool) {
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Names returns the registered type names in sorted order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.types))
+	for name := range r.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start starts the batcher of every registered type in its own goroutine.
+func (r *Registry) Start() {
+	for _, t := range r.types {
+		go t.Start()
+	}
+}
+
+// Stop signals the batcher of every registered type to flush and shut down.
+func (r *Registry) Stop() {
+	for _, t := range r.types {
+		t.Stop()
+	}
+}
@@ -0,0 +1,123 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"benzinga-webhook/internal/schema"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type order struct {
+	ID string `json:"id" validate:"required"`
+}
+
+type recorder[T any] struct {
+	entries []T
+}
+
+func (r *recorder[T]) Add(entry T) { r.entries = append(r.entries, entry) }
+func (r *recorder[T]) Start()      {}
+func (r *recorder[T]) Stop()       {}
+
+func TestIngestStruct(t *testing.T) {
+	b := &recorder[order]{}
+	typ := New(Spec[order]{
+		Name:     "order",
+		Validate: validator.New(),
+		Prepare:  func(o *order) { o.ID = "order-" + o.ID },
+		Batcher:  b,
+	})
+
+	assert.NoError(t, typ.Ingest([]byte(`{"id":"1"}`), nil))
+	assert.Equal(t, []order{{ID: "order-1"}}, b.entries)
+
+	var verr *ValidationError
+	assert.True(t, errors.As(typ.Ingest([]byte(`{}`), nil), &verr))
+	assert.Error(t, verr.Err)
+
+	var derr *DecodeError
+	assert.True(t, errors.As(typ.Ingest([]byte(`{`), nil), &derr))
+	assert.Len(t, b.entries, 1)
+}
+
+func TestIngestSchema(t *testing.T) {
+	sv, err := schema.New([]byte(`{"type":"object","required":["id"]}`), nil, nil)
+	assert.NoError(t, err)
+	b := &recorder[json.RawMessage]{}
+	typ := New(Spec[json.RawMessage]{Name: "raw", Schema: sv, Batcher: b})
+
+	assert.NoError(t, typ.Ingest([]byte(`{"id":1,"extra":"kept"}`), nil))
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"id":1,"extra":"kept"}`)}, b.entries)
+
+	var verr *ValidationError
+	assert.True(t, errors.As(typ.Ingest([]byte(`{"other":1}`), nil), &verr))
+	assert.Len(t, verr.Violations, 1)
+	assert.Equal(t, "/id", verr.Violations[0].Pointer)
+	assert.Equal(t, sv.Raw(), typ.Schema())
+}
+
+func TestIngestAs(t *testing.T) {
+	b := &recorder[order]{}
+	typ := New(Spec[order]{
+		Name:     "order",
+		Validate: validator.New(),
+		Prepare:  func(o *order) { o.ID = "order-" + o.ID },
+		Batcher:  b,
+		Decoders: map[string]Decoder[order]{MediaMsgpack: Msgpack[order]},
+	})
+
+	body, err := msgpack.Marshal(map[string]any{"id": "1", "extra": true})
+	assert.NoError(t, err)
+	assert.NoError(t, typ.IngestAs(MediaMsgpack, body, nil))
+	assert.Equal(t, []order{{ID: "order-1"}}, b.entries)
+
+	body, err = msgpack.Marshal(map[string]any{})
+	assert.NoError(t, err)
+	var verr *ValidationError
+	assert.True(t, errors.As(typ.IngestAs(MediaMsgpack, body, nil), &verr))
+
+	var derr *DecodeError
+	assert.True(t, errors.As(typ.IngestAs(MediaMsgpack, []byte{0xc1}, nil), &derr))
+	assert.ErrorIs(t, typ.IngestAs(MediaProtobuf, nil, nil), ErrUnsupportedMediaType)
+	assert.Len(t, b.entries, 1)
+}
+
+func TestIngestAsSchema(t *testing.T) {
+	sv, err := schema.New([]byte(`{"type":"object","properties":{"id":{"type":"string","minLength":2}}}`), nil, nil)
+	assert.NoError(t, err)
+	b := &recorder[order]{}
+	typ := New(Spec[order]{
+		Name:     "order",
+		Schema:   sv,
+		Batcher:  b,
+		Decoders: map[string]Decoder[order]{MediaMsgpack: Msgpack[order]},
+	})
+
+	body, err := msgpack.Marshal(map[string]any{"id": "1"})
+	assert.NoError(t, err)
+	var verr *ValidationError
+	assert.True(t, errors.As(typ.IngestAs(MediaMsgpack, body, nil), &verr))
+	assert.Equal(t, "/id", verr.Violations[0].Pointer)
+	assert.Empty(t, b.entries)
+}
+
+func TestRegistry(t *testing.T) {
+	a := New(Spec[order]{Name: "b", Batcher: &recorder[order]{}})
+	r, err := NewRegistry(a, New(Spec[order]{Name: "a", Batcher: &recorder[order]{}}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, r.Names())
+
+	got, ok := r.Lookup("b")
+	assert.True(t, ok)
+	assert.Same(t, a, got)
+	_, ok = r.Lookup("c")
+	assert.False(t, ok)
+
+	assert.EqualError(t, r.Register(a), `event type "b" is already registered`)
+	assert.EqualError(t, r.Register(New(Spec[order]{})), "event type name is required")
+}
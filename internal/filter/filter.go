@@ -0,0 +1,107 @@
+// Package filter decides, with the rules declared for a sink in CONFIG_FILE,
+// which records the sink receives.
+package filter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"expvar"
+	"fmt"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/jsonptr"
+	"benzinga-webhook/internal/transform"
+)
+
+// Dropped counts records not delivered by "<sink>/<rule>". It is published at
+// /admin/debug/vars as "filter_dropped".
+var Dropped = expvar.NewMap("filter_dropped")
+
+// Errors counts "<sink>/<rule>" conditions that failed to evaluate; such rules
+// are treated as not matching. It is published as "filter_errors".
+var Errors = expvar.NewMap("filter_errors")
+
+type rule struct {
+	config.FilterRule
+	when *transform.Transform
+	key  []string
+}
+
+// Rules are the compiled filter rules of one sink. They implement sink.Filter.
+type Rules struct {
+	sink  string
+	rules []rule
+}
+
+// New compiles the rules of the named sink.
+func New(sink string, rules []config.FilterRule) (*Rules, error) {
+	r := &Rules{sink: sink}
+	for _, rc := range rules {
+		ru := rule{FilterRule: rc, key: jsonptr.Split(rc.Key)}
+		if rc.When != "" {
+			t, err := transform.Compile(rc.Name, rc.When)
+			if err != nil {
+				return nil, fmt.Errorf("filter: %w", err)
+			}
+			ru.when = t
+		}
+		r.rules = append(r.rules, ru)
+	}
+	return r, nil
+}
+
+// Keep reports whether the sink receives doc, a record decoded with
+// json.Decoder.UseNumber.
+func (r *Rules) Keep(doc any) bool {
+	for _, ru := range r.rules {
+		if !r.matches(ru, doc) {
+			continue
+		}
+		keep := ru.Action == config.FilterKeep ||
+			ru.Action == config.FilterSample && Sample(sampleKey(doc, ru.key), ru.Rate)
+		if !keep {
+			Dropped.Add(r.sink+"/"+ru.Name, 1)
+		}
+		return keep
+	}
+	return true
+}
+
+func (r *Rules) matches(ru rule, doc any) bool {
+	if ru.when == nil {
+		return true
+	}
+	out, err := ru.when.Run(context.Background(), doc)
+	if err != nil {
+		Errors.Add(r.sink+"/"+ru.Name, 1)
+		return false
+	}
+	// jq truthiness: everything but false and null.
+	return out != nil && out != false
+}
+
+// sampleKey returns the text of the value at key, e.g. "42" for a user_id of
+// 42, or "" when there is none.
+func sampleKey(doc any, key []string) string {
+	v, ok := jsonptr.Get(doc, key)
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+// Sample reports whether key falls in the rate fraction of keys that are
+// kept. The same key always gets the same answer for a given rate, and a key
+// kept at one rate is kept at every higher rate.
+func Sample(key string, rate float64) bool {
+	// FNV is cheaper but its high bits barely change between sequential ids.
+	sum := sha256.Sum256([]byte(key))
+	// The top 53 bits give a uniform float in [0, 1).
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11)/(1<<53) < rate
+}
@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"benzinga-webhook/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, raw string) any {
+	t.Helper()
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(&doc))
+	return doc
+}
+
+func TestKeep(t *testing.T) {
+	r, err := New("analytics", []config.FilterRule{
+		{Name: "vip", When: ".user_id == 7", Action: config.FilterKeep},
+		{Name: "incomplete", When: ".completed == false", Action: config.FilterDrop},
+		{Name: "none", Action: config.FilterSample, Rate: 0, Key: "/user_id"},
+	})
+	require.NoError(t, err)
+	Dropped.Init()
+
+	assert.True(t, r.Keep(decode(t, `{"user_id":7,"completed":false}`)))
+	assert.False(t, r.Keep(decode(t, `{"user_id":1,"completed":false}`)))
+	assert.False(t, r.Keep(decode(t, `{"user_id":1,"completed":true}`)))
+	assert.False(t, r.Keep(decode(t, `{"user_id":2}`)))
+
+	assert.Equal(t, "1", Dropped.Get("analytics/incomplete").String())
+	assert.Equal(t, "2", Dropped.Get("analytics/none").String())
+	assert.Nil(t, Dropped.Get("analytics/vip"))
+}
+
+func TestKeepWithoutMatch(t *testing.T) {
+	r, err := New("analytics", []config.FilterRule{
+		{Name: "broken", When: ".title | ascii_downcase", Action: config.FilterDrop},
+	})
+	require.NoError(t, err)
+	Errors.Init()
+
+	// A condition that fails to evaluate does not match.
+	assert.True(t, r.Keep(decode(t, `{"title":1}`)))
+	assert.Equal(t, "1", Errors.Get("analytics/broken").String())
+	assert.False(t, r.Keep(decode(t, `{"title":"Order"}`)))
+	assert.True(t, r.Keep(decode(t, `{"title":null}`)))
+}
+
+func TestSample(t *testing.T) {
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprint(i)
+		in := Sample(key, 0.1)
+		assert.Equal(t, in, Sample(key, 0.1), "same key, same answer")
+		if in {
+			kept++
+			assert.True(t, Sample(key, 0.5), "kept at higher rates")
+		}
+	}
+	assert.InDelta(t, 1000, kept, 100)
+	assert.False(t, Sample("1", 0))
+	assert.True(t, Sample("1", 1))
+}
+
+func TestSampleKey(t *testing.T) {
+	r, err := New("analytics", []config.FilterRule{
+		{Name: "half", Action: config.FilterSample, Rate: 0.5, Key: "/user_id"},
+	})
+	require.NoError(t, err)
+
+	// Every entry of a user shares its fate, whatever else it contains.
+	for i := 0; i < 20; i++ {
+		want := Sample(fmt.Sprint(i), 0.5)
+		for _, title := range []string{"a", "b"} {
+			doc := decode(t, fmt.Sprintf(`{"user_id":%d,"title":%q}`, i, title))
+			assert.Equal(t, want, r.Keep(doc))
+		}
+	}
+}
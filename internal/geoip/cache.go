@@ -0,0 +1,56 @@
+package geoip
+
+import (
+	"container/list"
+
+	"benzinga-webhook/internal/model"
+)
+
+// cache is a least-recently-used map from IP to lookup result, including
+// misses. It is not safe for concurrent use. A cache of size zero stores
+// nothing.
+type cache struct {
+	size  int
+	order *list.List // of *entry, most recently used first
+	items map[string]*list.Element
+}
+
+type entry struct {
+	ip  string
+	geo *model.Geo
+}
+
+func newCache(size int) *cache {
+	return &cache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *cache) get(ip string) (*model.Geo, bool) {
+	el, ok := c.items[ip]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).geo, true
+}
+
+func (c *cache) put(ip string, geo *model.Geo) {
+	if c.size <= 0 {
+		return
+	}
+	if el, ok := c.items[ip]; ok {
+		el.Value.(*entry).geo = geo
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[ip] = c.order.PushFront(&entry{ip: ip, geo: geo})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).ip)
+	}
+}
+
+func (c *cache) clear() {
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
@@ -0,0 +1,236 @@
+// Package geoip enriches login IPs with country, city, location and
+// autonomous system data from local MaxMind DB (mmdb) files.
+package geoip
+
+//go:generate sh -c "cd testdata && go run gen.go"
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/model"
+
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+)
+
+// record holds the fields read from a database. GeoLite2 and GeoIP2 City and
+// Country databases fill the first three, ASN databases the last two.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// database is one mmdb file, read fully into memory so that it can be swapped
+// out while lookups against the previous version are still running.
+type database struct {
+	path    string
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+}
+
+func open(path string) (*database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := maxminddb.FromBytes(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &database{path: path, reader: reader, modTime: info.ModTime(), size: int64(len(raw))}, nil
+}
+
+// changed reports whether the file at db.path differs from the loaded one.
+func (db *database) changed() bool {
+	info, err := os.Stat(db.path)
+	return err == nil && (!info.ModTime().Equal(db.modTime) || info.Size() != db.size)
+}
+
+// Enricher looks up IPs in one or more databases and merges the results, the
+// first database that has a field winning.
+type Enricher struct {
+	log      *zap.Logger
+	interval time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	reload sync.Mutex // serializes Reload
+
+	mu         sync.Mutex // guards the fields below
+	dbs        []*database
+	checked    time.Time
+	cache      *cache
+	generation int
+}
+
+// New opens the databases in GEOIP_DATABASES. It returns nil when none are
+// configured; a nil Enricher clears Geo instead of setting it.
+func New(cfg *config.Config, log *zap.Logger) (*Enricher, error) {
+	if len(cfg.GeoIPDatabases) == 0 {
+		return nil, nil
+	}
+	e := &Enricher{
+		log:      log,
+		interval: cfg.GeoIPReloadInterval,
+		Now:      time.Now,
+		cache:    newCache(cfg.GeoIPCacheSize),
+	}
+	for _, path := range cfg.GeoIPDatabases {
+		db, err := open(path)
+		if err != nil {
+			return nil, fmt.Errorf("geoip: %w", err)
+		}
+		e.dbs = append(e.dbs, db)
+	}
+	e.checked = e.now()
+	return e, nil
+}
+
+func (e *Enricher) now() time.Time {
+	if e.Now == nil {
+		return time.Now()
+	}
+	return e.Now()
+}
+
+// Logins sets Geo on every login whose IP is found, and clears it on the rest
+// so that clients cannot supply their own.
+func (e *Enricher) Logins(logins []model.Login) []model.Login {
+	for i := range logins {
+		logins[i].Geo = nil
+		if e != nil {
+			logins[i].Geo = e.Lookup(logins[i].IP)
+		}
+	}
+	return logins
+}
+
+// Lookup returns what the databases know about ip, or nil when it is not a
+// valid address or none of them has it. Results are cached until the next
+// reload and shared between callers, so they must not be modified.
+func (e *Enricher) Lookup(ip string) *model.Geo {
+	e.reloadIfDue()
+
+	e.mu.Lock()
+	geo, hit := e.cache.get(ip)
+	dbs, generation := e.dbs, e.generation
+	e.mu.Unlock()
+	if hit {
+		return geo
+	}
+
+	geo = lookup(dbs, ip)
+
+	e.mu.Lock()
+	if generation == e.generation {
+		e.cache.put(ip, geo)
+	}
+	e.mu.Unlock()
+	return geo
+}
+
+func lookup(dbs []*database, ip string) *model.Geo {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil
+	}
+	var geo model.Geo
+	for _, db := range dbs {
+		var rec record
+		// Errors come from looking up IPv6 addresses in IPv4-only databases
+		// or from corrupt records; either way the database has no answer.
+		if err := db.reader.Lookup(addr, &rec); err != nil {
+			continue
+		}
+		if geo.Country == "" {
+			geo.Country = rec.Country.ISOCode
+		}
+		if geo.City == "" {
+			geo.City = rec.City.Names["en"]
+		}
+		if geo.Location == nil && rec.Location.Latitude != nil && rec.Location.Longitude != nil {
+			geo.Location = &model.Location{Latitude: *rec.Location.Latitude, Longitude: *rec.Location.Longitude}
+		}
+		if geo.ASN == 0 {
+			geo.ASN, geo.ASOrg = rec.ASN, rec.ASOrg
+		}
+	}
+	if geo == (model.Geo{}) {
+		return nil
+	}
+	return &geo
+}
+
+// reloadIfDue calls Reload at most once per reload interval.
+func (e *Enricher) reloadIfDue() {
+	if e.interval <= 0 {
+		return
+	}
+	e.mu.Lock()
+	now := e.now()
+	due := now.Sub(e.checked) >= e.interval
+	if due {
+		e.checked = now
+	}
+	e.mu.Unlock()
+	if due {
+		e.Reload()
+	}
+}
+
+// Reload reopens every database whose file changed since it was loaded and
+// empties the cache if any did. A file that cannot be read, e.g. because it
+// is still being written, is logged and retried on the next reload while the
+// previous version stays in use.
+func (e *Enricher) Reload() {
+	e.reload.Lock()
+	defer e.reload.Unlock()
+
+	e.mu.Lock()
+	dbs := append([]*database(nil), e.dbs...)
+	e.mu.Unlock()
+
+	reloaded := false
+	for i, db := range dbs {
+		if !db.changed() {
+			continue
+		}
+		fresh, err := open(db.path)
+		if err != nil {
+			e.log.Warn("keeping previous GeoIP database", zap.String("path", db.path), zap.Error(err))
+			continue
+		}
+		dbs[i] = fresh
+		reloaded = true
+		e.log.Info("reloaded GeoIP database", zap.String("path", db.path))
+	}
+	if !reloaded {
+		return
+	}
+
+	e.mu.Lock()
+	e.dbs = dbs
+	e.cache.clear()
+	e.generation++
+	e.mu.Unlock()
+}
@@ -0,0 +1,139 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var london = &model.Geo{
+	Country:  "GB",
+	City:     "London",
+	Location: &model.Location{Latitude: 51.5142, Longitude: -0.0931},
+	ASN:      20712,
+	ASOrg:    "Andrews & Arnold Ltd",
+}
+
+func newEnricher(t *testing.T, cacheSize int, paths ...string) *Enricher {
+	t.Helper()
+	e, err := New(&config.Config{GeoIPDatabases: paths, GeoIPCacheSize: cacheSize, GeoIPReloadInterval: time.Minute}, zap.NewNop())
+	require.NoError(t, err)
+	return e
+}
+
+func TestLookup(t *testing.T) {
+	e := newEnricher(t, 10, "testdata/city.mmdb", "testdata/asn.mmdb")
+
+	assert.Equal(t, london, e.Lookup("81.2.69.142"))
+	assert.Equal(t, &model.Geo{
+		Country:  "JP",
+		City:     "Tokyo",
+		Location: &model.Location{Latitude: 35.685, Longitude: 139.7514},
+		ASN:      2914,
+		ASOrg:    "NTT America, Inc.",
+	}, e.Lookup("2001:218::7"))
+	assert.Nil(t, e.Lookup("10.0.0.1"), "not in the databases")
+	assert.Nil(t, e.Lookup("not an ip"))
+
+	asnOnly := newEnricher(t, 10, "testdata/asn.mmdb")
+	assert.Equal(t, &model.Geo{ASN: 209, ASOrg: "Qwest Communications Company, LLC"}, asnOnly.Lookup("216.160.83.56"))
+}
+
+func TestNew(t *testing.T) {
+	e, err := New(&config.Config{}, zap.NewNop())
+	assert.NoError(t, err)
+	assert.Nil(t, e, "enrichment is disabled without databases")
+
+	_, err = New(&config.Config{GeoIPDatabases: []string{"testdata/missing.mmdb"}}, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = New(&config.Config{GeoIPDatabases: []string{"testdata/gen.go"}}, zap.NewNop())
+	assert.ErrorContains(t, err, "testdata/gen.go")
+}
+
+func TestLogins(t *testing.T) {
+	e := newEnricher(t, 10, "testdata/city.mmdb", "testdata/asn.mmdb")
+	logins := []model.Login{
+		{IP: "81.2.69.142", Geo: &model.Geo{Country: "FR"}},
+		{IP: "192.168.0.1", Geo: &model.Geo{Country: "FR"}},
+	}
+
+	got := e.Logins(logins)
+	assert.Equal(t, london, got[0].Geo)
+	assert.Nil(t, got[1].Geo, "client-supplied geo is discarded")
+
+	var disabled *Enricher
+	got = disabled.Logins([]model.Login{{IP: "81.2.69.142", Geo: london}})
+	assert.Nil(t, got[0].Geo)
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	copyFile(t, "testdata/city.mmdb", path)
+	e := newEnricher(t, 10, path)
+	now := time.Now()
+	e.Now = func() time.Time { return now }
+
+	assert.Equal(t, "London", e.Lookup("81.2.69.142").City)
+
+	copyFile(t, "testdata/asn.mmdb", path)
+	assert.Equal(t, "London", e.Lookup("81.2.69.142").City, "changes are not picked up before the interval")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, &model.Geo{ASN: 20712, ASOrg: "Andrews & Arnold Ltd"}, e.Lookup("81.2.69.142"), "cache is emptied on reload")
+
+	// A broken file keeps the previous database in use.
+	require.NoError(t, os.WriteFile(path, []byte("truncated"), 0o644))
+	now = now.Add(time.Minute)
+	assert.Equal(t, uint(20712), e.Lookup("81.2.69.142").ASN)
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(2)
+	c.put("a", london)
+	c.put("b", nil)
+	_, _ = c.get("a")
+	c.put("c", nil)
+
+	geo, ok := c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, london, geo)
+	_, ok = c.get("b")
+	assert.False(t, ok, "least recently used entry is evicted")
+	geo, ok = c.get("c")
+	assert.True(t, ok, "misses are cached")
+	assert.Nil(t, geo)
+
+	c.clear()
+	_, ok = c.get("a")
+	assert.False(t, ok)
+
+	disabled := newCache(0)
+	disabled.put("a", london)
+	_, ok = disabled.get("a")
+	assert.False(t, ok)
+}
+
+// copyFile replaces dst with src, moving its modification time forward so the
+// change is seen even on filesystems with coarse timestamps.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	raw, err := os.ReadFile(src)
+	require.NoError(t, err)
+	var modTime time.Time
+	if info, err := os.Stat(dst); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	require.NoError(t, os.WriteFile(dst, raw, 0o644))
+	if !modTime.IsZero() {
+		require.NoError(t, os.Chtimes(dst, modTime, modTime))
+	}
+}
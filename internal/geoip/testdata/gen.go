@@ -0,0 +1,209 @@
+//go:build ignore
+
+// gen writes the MaxMind DB fixtures used by the geoip tests:
+//
+//	go run gen.go
+//
+// city.mmdb holds country, city and location records and asn.mmdb holds
+// autonomous system records, mirroring the split of the GeoLite2 databases.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"net/netip"
+	"os"
+	"sort"
+)
+
+type network struct {
+	prefix string
+	data   map[string]any
+}
+
+func main() {
+	city := []network{
+		{"81.2.69.0/24", place("GB", "London", 51.5142, -0.0931)},
+		{"216.160.83.0/24", place("US", "Milton", 47.2513, -122.3149)},
+		{"2001:218::/32", place("JP", "Tokyo", 35.685, 139.7514)},
+	}
+	asn := []network{
+		{"81.2.69.0/24", as(20712, "Andrews & Arnold Ltd")},
+		{"216.160.83.0/24", as(209, "Qwest Communications Company, LLC")},
+		{"2001:218::/32", as(2914, "NTT America, Inc.")},
+	}
+	write("city.mmdb", "Test-City", city)
+	write("asn.mmdb", "Test-ASN", asn)
+}
+
+func place(country, city string, lat, lon float64) map[string]any {
+	return map[string]any{
+		"country":  map[string]any{"iso_code": country},
+		"city":     map[string]any{"names": map[string]any{"en": city}},
+		"location": map[string]any{"latitude": lat, "longitude": lon},
+	}
+}
+
+func as(number uint32, org string) map[string]any {
+	return map[string]any{
+		"autonomous_system_number":       number,
+		"autonomous_system_organization": org,
+	}
+}
+
+// node is a search tree node. A child is either another node, the index of a
+// data record or, when both are unset, empty.
+type node struct {
+	child [2]*node
+	data  [2]int
+}
+
+func write(path, dbType string, networks []network) {
+	root := &node{data: [2]int{-1, -1}}
+	var records []map[string]any
+	for _, n := range networks {
+		prefix := netip.MustParsePrefix(n.prefix)
+		addr := prefix.Addr().As16()
+		bits := prefix.Bits()
+		if prefix.Addr().Is4() {
+			// IPv4 networks live under ::/96 in an IPv6 tree.
+			bits += 96
+			addr = [16]byte{}
+			copy(addr[12:], prefix.Addr().AsSlice())
+		}
+		cur := root
+		for i := 0; i < bits-1; i++ {
+			bit := addr[i/8] >> (7 - i%8) & 1
+			if cur.child[bit] == nil {
+				cur.child[bit] = &node{data: [2]int{-1, -1}}
+			}
+			cur = cur.child[bit]
+		}
+		last := bits - 1
+		cur.data[addr[last/8]>>(7-last%8)&1] = len(records)
+		records = append(records, n.data)
+	}
+
+	var nodes []*node
+	index := map[*node]int{}
+	var number func(n *node)
+	number = func(n *node) {
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.child {
+			if c != nil {
+				number(c)
+			}
+		}
+	}
+	number(root)
+
+	var section bytes.Buffer
+	offsets := make([]int, len(records))
+	for i, r := range records {
+		offsets[i] = section.Len()
+		encode(&section, r)
+	}
+
+	count := len(nodes)
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for side := 0; side < 2; side++ {
+			value := count
+			switch {
+			case n.child[side] != nil:
+				value = index[n.child[side]]
+			case n.data[side] >= 0:
+				value = count + 16 + offsets[n.data[side]]
+			}
+			out.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(section.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	encode(&out, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               dbType,
+		"description":                 map[string]any{"en": "benzinga-webhook test fixture"},
+		"ip_version":                  uint16(6),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(count),
+		"record_size":                 uint16(24),
+	})
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// encode writes v in the MaxMind DB data section format.
+func encode(w *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case string:
+		control(w, 2, len(v))
+		w.WriteString(v)
+	case float64:
+		control(w, 3, 8)
+		_ = binary.Write(w, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		unsigned(w, 5, uint64(v))
+	case uint32:
+		unsigned(w, 6, uint64(v))
+	case uint64:
+		unsigned(w, 9, v)
+	case map[string]any:
+		control(w, 7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encode(w, k)
+			encode(w, v[k])
+		}
+	case []any:
+		control(w, 11, len(v))
+		for _, item := range v {
+			encode(w, item)
+		}
+	default:
+		log.Fatalf("cannot encode %T", v)
+	}
+}
+
+func unsigned(w *bytes.Buffer, typ int, v uint64) {
+	var buf []byte
+	for ; v > 0; v >>= 8 {
+		buf = append([]byte{byte(v)}, buf...)
+	}
+	control(w, typ, len(buf))
+	w.Write(buf)
+}
+
+func control(w *bytes.Buffer, typ, size int) {
+	first := byte(typ << 5)
+	if typ > 7 {
+		first = 0
+	}
+	var extra []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 285:
+		first |= 29
+		extra = []byte{byte(size - 29)}
+	default:
+		first |= 30
+		extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	}
+	w.WriteByte(first)
+	if typ > 7 {
+		w.WriteByte(byte(typ - 7))
+	}
+	w.Write(extra)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/transform"
+
+	"go.uber.org/zap"
+)
+
+// Admin serves the endpoints under /admin.
+type Admin struct {
+	log        *zap.Logger
+	transforms *transform.Set
+}
+
+// NewAdmin creates an Admin for the transforms declared in CONFIG_FILE.
+func NewAdmin(log *zap.Logger, transforms *transform.Set) *Admin {
+	return &Admin{log: log, transforms: transforms}
+}
+
+// RequireToken rejects requests that do not carry "Authorization: Bearer
+// <token>".
+func RequireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeProblem(w, nil, apperror.UnauthorizedProblem(r.URL.Path))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// dryRunRequest names either configured transforms or an ad-hoc jq program to
+// run on Input.
+type dryRunRequest struct {
+	Transforms []string        `json:"transforms"`
+	JQ         string          `json:"jq"`
+	Input      json.RawMessage `json:"input"`
+}
+
+// dryRunResponse holds the transformed record, or Dropped when the pipeline
+// produced no output.
+type dryRunResponse struct {
+	Output  any  `json:"output"`
+	Dropped bool `json:"dropped"`
+}
+
+// DryRun runs transforms on a sample payload without batching it. Failures are
+// reported to the caller and are not counted in transform.Errors.
+func (a *Admin) DryRun(w http.ResponseWriter, r *http.Request) {
+	var req dryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, a.log, apperror.DecodeProblem(err, r.URL.Path))
+		return
+	}
+
+	var p transform.Pipeline
+	var err error
+	switch {
+	case len(req.Input) == 0:
+		err = errors.New("input is required")
+	case req.JQ != "" && len(req.Transforms) > 0:
+		err = errors.New("set either transforms or jq, not both")
+	case req.JQ != "":
+		var t *transform.Transform
+		if t, err = transform.Compile("dry-run", req.JQ); err == nil {
+			p = transform.Pipeline{t}
+		}
+	case len(req.Transforms) > 0:
+		p, err = a.transforms.Pipeline(req.Transforms...)
+	default:
+		err = errors.New("transforms or jq is required")
+	}
+	if err != nil {
+		writeProblem(w, a.log, apperror.TransformProblem(err, r.URL.Path))
+		return
+	}
+
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(req.Input))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		writeProblem(w, a.log, apperror.DecodeProblem(err, r.URL.Path))
+		return
+	}
+	out, err := p.Run(r.Context(), doc)
+	if err == nil {
+		// Check the output has a JSON encoding before committing to a 200.
+		_, err = json.Marshal(out)
+	}
+	if err != nil {
+		writeProblem(w, a.log, apperror.TransformProblem(err, r.URL.Path))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dryRunResponse{Output: out, Dropped: out == nil})
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/transform"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	h := RequireToken("s3cret")(ok)
+
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Basic s3cret":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusNoContent,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/x", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, want, rr.Code, header)
+		if want == http.StatusUnauthorized {
+			assert.Equal(t, apperror.ContentTypeProblem, rr.Header().Get("Content-Type"))
+			assert.Contains(t, rr.Body.String(), apperror.TypeUnauthorized)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	transforms, err := transform.New(&config.Config{Transforms: []config.TransformConfig{
+		{Name: "camel", JQ: `{userId: .user_id} + del(.user_id)`},
+		{Name: "no-meta", JQ: `del(.meta)`},
+		{Name: "completed", JQ: `select(.completed)`},
+	}})
+	require.NoError(t, err)
+	admin := NewAdmin(zap.NewNop(), transforms)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"configured", `{"transforms":["camel","no-meta"],"input":{"user_id":1,"total":99.90,"meta":{}}}`,
+			http.StatusOK, `{"output":{"userId":1,"total":99.9},"dropped":false}`},
+		{"ad hoc", `{"jq":".user_id + 1","input":{"user_id":1}}`, http.StatusOK, `{"output":2,"dropped":false}`},
+		{"dropped", `{"transforms":["completed"],"input":{"completed":false}}`, http.StatusOK, `{"output":null,"dropped":true}`},
+		{"unknown", `{"transforms":["missing"],"input":{}}`, http.StatusUnprocessableEntity, `unknown transform \"missing\"`},
+		{"compile error", `{"jq":"{","input":{}}`, http.StatusUnprocessableEntity, apperror.TypeTransformFailed},
+		{"runtime error", `{"jq":".a + 1","input":{"a":"x"}}`, http.StatusUnprocessableEntity, "cannot add"},
+		{"no encoding", `{"jq":"nan","input":{}}`, http.StatusUnprocessableEntity, apperror.TypeTransformFailed},
+		{"both", `{"jq":".","transforms":["camel"],"input":{}}`, http.StatusUnprocessableEntity, "not both"},
+		{"neither", `{"input":{}}`, http.StatusUnprocessableEntity, "transforms or jq is required"},
+		{"no input", `{"jq":"."}`, http.StatusUnprocessableEntity, "input is required"},
+		{"malformed", `{`, http.StatusBadRequest, apperror.TypeInvalidPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			admin.DryRun(rr, httptest.NewRequest(http.MethodPost, "/admin/transforms/dry-run", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status == http.StatusOK {
+				assert.JSONEq(t, tt.want, rr.Body.String())
+			} else {
+				assert.Contains(t, rr.Body.String(), tt.want)
+			}
+		})
+	}
+	assert.Nil(t, transform.Errors.Get("camel"), "dry runs are not counted")
+}
@@ -3,34 +3,44 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"benzinga-webhook/internal/apperror"
-	"benzinga-webhook/internal/batcher"
-	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/event"
+	"benzinga-webhook/internal/schema"
+	"benzinga-webhook/internal/versioning"
 
+	"github.com/go-chi/chi/v5"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
 )
 
-// PhoneValidator validates phone numbers using a custom pattern format (e.g., 123-4567-891).
+var legacyPhonePattern = regexp.MustCompile(`^\d{3}-\d{4}-\d{3}$`)
+
+// PhoneValidator validates phone numbers using the legacy pattern format (e.g., 123-4567-891).
+// Use phone.Set.Validate to accept the formats configured via PHONE_FORMATS.
 var PhoneValidator = func(fl validator.FieldLevel) bool {
-	pattern := `^\d{3}-\d{4}-\d{3}$`
-	matched, _ := regexp.MatchString(pattern, fl.Field().String())
-	return matched
+	return legacyPhonePattern.MatchString(fl.Field().String())
 }
 
-// Handler wraps HTTP handlers with logger and batcher.
+// Handler wraps HTTP handlers with logger and the event type registry.
 type Handler struct {
-	log      *zap.Logger
-	batch    batcher.Batcher
-	validate *validator.Validate
+	log    *zap.Logger
+	events *event.Registry
+	cfg    *config.Config
 }
 
-// New creates a new Handler instance.
-func New(log *zap.Logger, b batcher.Batcher, v *validator.Validate) *Handler {
-	return &Handler{log: log, batch: b, validate: v}
+// New creates a new Handler instance. POST /log ingests the event.LogEntry type
+// from events.
+func New(log *zap.Logger, events *event.Registry, cfg *config.Config) *Handler {
+	return &Handler{log: log, events: events, cfg: cfg}
 }
 
 // Healthz is a simple health check endpoint.
@@ -41,30 +51,220 @@ func (h *Handler) Healthz(w http.ResponseWriter, _ *http.Request) {
 
 // LogPayload receives and processes JSON payloads.
 func (h *Handler) LogPayload(w http.ResponseWriter, r *http.Request) {
-	var entry model.LogEntry
-	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
-		h.log.Error("failed to decode json", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
+	t, ok := h.events.Lookup(event.LogEntry)
+	if !ok {
+		h.writeUnknownEvent(w, r, event.LogEntry)
+		return
+	}
+	h.ingest(w, r, t)
+}
+
+// Event receives a payload of the event type named in the URL.
+func (h *Handler) Event(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "type")
+	t, ok := h.events.Lookup(name)
+	if !ok {
+		h.writeUnknownEvent(w, r, name)
+		return
+	}
+	h.ingest(w, r, t)
+}
+
+// Schema publishes the JSON Schema describing payloads accepted by POST /log.
+func (h *Handler) Schema(w http.ResponseWriter, _ *http.Request) {
+	raw := schema.Builtin()
+	if t, ok := h.events.Lookup(event.LogEntry); ok && t.Schema() != nil {
+		raw = t.Schema()
+	}
+	writeSchema(w, raw)
+}
+
+// EventSchema publishes the JSON Schema of the event type named in the URL.
+func (h *Handler) EventSchema(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "type")
+	t, ok := h.events.Lookup(name)
+	if !ok || t.Schema() == nil {
+		h.writeUnknownEvent(w, r, name)
+		return
+	}
+	writeSchema(w, t.Schema())
+}
+
+func writeSchema(w http.ResponseWriter, raw []byte) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(raw)
+}
+
+// ingest upgrades, validates and queues one event of type t. Bodies are JSON
+// unless Content-Type names one of the binary media types.
+func (h *Handler) ingest(w http.ResponseWriter, r *http.Request, t event.Type) {
+	trans := apperror.TranslatorFor(r.Header.Get("Accept-Language"))
+	var err error
+	mediaType := binaryMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "" {
+		body, readErr := io.ReadAll(r.Body)
+		if readErr != nil {
+			h.writeDecodeError(w, r, readErr)
+			return
+		}
+		err = t.IngestAs(mediaType, body, trans)
+	} else {
+		body, ok := h.readVersioned(w, r, t, trans)
+		if !ok {
+			return
+		}
+		err = t.Ingest(body, trans)
+	}
+
+	var validationErr *event.ValidationError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
 		_ = json.NewEncoder(w).Encode(map[string]string{
-			"error": "invalid request payload",
+			"status": "Ok",
 		})
-		return
+	case errors.As(err, &validationErr):
+		h.writeValidationError(w, r, trans, validationErr)
+	case errors.Is(err, event.ErrUnsupportedMediaType):
+		if h.legacyErrors() {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "unsupported media type"})
+			return
+		}
+		h.writeProblem(w, apperror.UnsupportedMediaProblem(mediaType, r.URL.Path))
+	case mediaType != "":
+		h.log.Error("failed to decode body", zap.String("content_type", mediaType))
+		if h.legacyErrors() {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request payload"})
+			return
+		}
+		h.writeProblem(w, apperror.BodyProblem(mediaType, r.URL.Path))
+	default:
+		h.writeDecodeError(w, r, err)
 	}
+}
 
-	if err := h.validate.Struct(entry); err != nil {
-		h.log.Warn("validation failed", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		validationError := apperror.CustomValidationError(err)
-		if err := json.NewEncoder(w).Encode(validationError); err != nil {
-			h.log.Error("unable to write response stream", zap.Error(err))
+// binaryMediaType returns the binary media type named by contentType, or ""
+// for JSON and anything else, which is decoded as JSON as before.
+func binaryMediaType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case event.MediaProtobuf, "application/protobuf":
+		return event.MediaProtobuf
+	case event.MediaMsgpack, "application/x-msgpack":
+		return event.MediaMsgpack
+	default:
+		return ""
+	}
+}
+
+// readVersioned reads the request body and upgrades it from the version the
+// producer declared to the current payload version of t.
+func (h *Handler) readVersioned(w http.ResponseWriter, r *http.Request, t event.Type, trans ut.Translator) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeDecodeError(w, r, err)
+		return nil, false
+	}
+
+	body, version, err := t.Upgrade(body, r.Header.Get(versioning.Header))
+	var decodeErr *event.DecodeError
+	var validationErr *event.ValidationError
+	switch {
+	case errors.As(err, &decodeErr):
+		h.writeDecodeError(w, r, decodeErr.Err)
+		return nil, false
+	case errors.As(err, &validationErr):
+		h.writeValidationError(w, r, trans, validationErr)
+		return nil, false
+	case err != nil:
+		h.log.Warn("schema version rejected", zap.String("version", version), zap.Error(err))
+		if h.legacyErrors() {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return nil, false
+		}
+		h.writeProblem(w, apperror.VersionProblem(err, r.URL.Path, t.Versions().Supported()))
+		return nil, false
+	}
+	return body, true
+}
+
+func (h *Handler) writeValidationError(w http.ResponseWriter, r *http.Request, trans ut.Translator, verr *event.ValidationError) {
+	if verr.Err != nil {
+		// Validator errors are summarized rather than logged, so rejected values
+		// never reach the logs.
+		h.log.Warn("validation failed", zap.Strings("violations", apperror.Summary(apperror.Violations(verr.Err, nil))))
+		if h.legacyErrors() {
+			h.writeLegacy(w, apperror.CustomValidationError(verr.Err))
 			return
 		}
+		setContentLanguage(w, trans)
+		h.writeProblem(w, apperror.ValidationProblem(verr.Err, r.URL.Path, trans))
+		return
+	}
+
+	h.log.Warn("schema validation failed", zap.Strings("violations", apperror.Summary(verr.Violations)))
+	if h.legacyErrors() {
+		h.writeLegacy(w, apperror.LegacyViolations(verr.Violations))
+		return
+	}
+	setContentLanguage(w, trans)
+	h.writeProblem(w, apperror.ViolationsProblem(verr.Violations, r.URL.Path, trans))
+}
+
+// setContentLanguage advertises the locale validation messages were rendered in.
+func setContentLanguage(w http.ResponseWriter, trans ut.Translator) {
+	w.Header().Set("Content-Language", strings.ReplaceAll(trans.Locale(), "_", "-"))
+}
+
+func (h *Handler) writeUnknownEvent(w http.ResponseWriter, r *http.Request, name string) {
+	if h.legacyErrors() {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "unknown event type"})
 		return
 	}
+	h.writeProblem(w, apperror.UnknownEventProblem(name, r.URL.Path, h.events.Names()))
+}
+
+func (h *Handler) writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	// Decode errors can quote the offending value, so only the summary is logged.
+	p := apperror.DecodeProblem(err, r.URL.Path)
+	h.log.Error("failed to decode json", zap.Strings("violations", apperror.Summary(p.Errors)))
+	if h.legacyErrors() {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "invalid request payload",
+		})
+		return
+	}
+	h.writeProblem(w, p)
+}
 
-	h.batch.Add(entry)
-	w.WriteHeader(http.StatusAccepted)
-	_ = json.NewEncoder(w).Encode(map[string]string{
-		"status": "Ok",
-	})
+func (h *Handler) writeLegacy(w http.ResponseWriter, errList []map[string]string) {
+	w.WriteHeader(http.StatusBadRequest)
+	if err := json.NewEncoder(w).Encode(errList); err != nil {
+		h.log.Error("unable to write response stream", zap.Error(err))
+	}
+}
+
+// legacyErrors reports whether errors should be rendered in the pre-RFC 7807 format.
+func (h *Handler) legacyErrors() bool {
+	return h.cfg != nil && h.cfg.ErrorFormat == config.ErrorFormatLegacy
+}
+
+// writeProblem renders p as an application/problem+json response.
+func (h *Handler) writeProblem(w http.ResponseWriter, p *apperror.Problem) {
+	writeProblem(w, h.log, p)
+}
+
+// writeProblem renders p, logging write failures to log unless it is nil.
+func writeProblem(w http.ResponseWriter, log *zap.Logger, p *apperror.Problem) {
+	w.Header().Set("Content-Type", apperror.ContentTypeProblem)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil && log != nil {
+		log.Error("unable to write response stream", zap.Error(err))
+	}
 }
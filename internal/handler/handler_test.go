@@ -4,18 +4,32 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/event"
 	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/pb"
+	"benzinga-webhook/internal/phone"
+	"benzinga-webhook/internal/schema"
+	"benzinga-webhook/internal/timestamp"
+	"benzinga-webhook/internal/versioning"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/protobuf/proto"
 )
 
 type mockBatcher struct {
@@ -28,6 +42,41 @@ func (m *mockBatcher) Add(entry model.LogEntry) {
 func (m *mockBatcher) Start() {}
 func (m *mockBatcher) Stop()  {}
 
+type rawBatcher struct {
+	entries []json.RawMessage
+}
+
+func (m *rawBatcher) Add(entry json.RawMessage) {
+	m.entries = append(m.entries, entry)
+}
+func (m *rawBatcher) Start() {}
+func (m *rawBatcher) Stop()  {}
+
+// newHandler builds a Handler whose registry holds only the log_entry type.
+func newHandler(log *zap.Logger, b *mockBatcher, v *validator.Validate, cfg *config.Config, phones *phone.Set, sv *schema.Validator, versions *versioning.Registry) *Handler {
+	_ = decimal.RegisterValidations(v)
+	_ = (&timestamp.Policy{}).RegisterValidations(v)
+	events, _ := event.NewRegistry(event.New(event.Spec[model.LogEntry]{
+		Name:       event.LogEntry,
+		Versions:   versions,
+		Schema:     sv,
+		SchemaMode: cfg.ValidationMode == config.ValidationModeSchema,
+		Validate:   v,
+		Prepare: func(entry *model.LogEntry) {
+			if phones != nil {
+				entry.Meta.PhoneNumbers.Home = phones.Normalize(entry.Meta.PhoneNumbers.Home)
+				entry.Meta.PhoneNumbers.Mobile = phones.Normalize(entry.Meta.PhoneNumbers.Mobile)
+			}
+		},
+		Batcher: b,
+		Decoders: map[string]event.Decoder[model.LogEntry]{
+			event.MediaProtobuf: pb.UnmarshalLogEntry,
+			event.MediaMsgpack:  event.Msgpack[model.LogEntry],
+		},
+	}))
+	return New(log, events, cfg)
+}
+
 func TestLogPayloadValidation(t *testing.T) {
 	core, _ := observer.New(zapcore.InfoLevel)
 	logger := zap.New(core)
@@ -35,7 +84,7 @@ func TestLogPayloadValidation(t *testing.T) {
 	err := validate.RegisterValidation("phoneformat", PhoneValidator)
 	assert.Nil(t, err)
 	batch := &mockBatcher{}
-	h := New(logger, batch, validate)
+	h := newHandler(logger, batch, validate, &config.Config{ErrorFormat: config.ErrorFormatLegacy}, nil, nil, nil)
 
 	tests := []struct {
 		name         string
@@ -48,7 +97,7 @@ func TestLogPayloadValidation(t *testing.T) {
 			name: "valid request",
 			payload: &model.LogEntry{
 				UserID: 1,
-				Total:  10.5,
+				Total:  decimal.MustParse("10.5"),
 				Title:  "valid title",
 				Meta: model.Meta{
 					Logins: []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "127.0.0.1"}},
@@ -65,7 +114,7 @@ func TestLogPayloadValidation(t *testing.T) {
 		{
 			name: "invalid request - missing user_id",
 			payload: &model.LogEntry{
-				Total: 10.5,
+				Total: decimal.MustParse("10.5"),
 				Title: "valid title",
 				Meta: model.Meta{
 					Logins: []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "127.0.0.1"}},
@@ -83,7 +132,7 @@ func TestLogPayloadValidation(t *testing.T) {
 			name: "invalid request - bad IP address",
 			payload: &model.LogEntry{
 				UserID: 2,
-				Total:  20.0,
+				Total:  decimal.MustParse("20.0"),
 				Title:  "invalid ip test",
 				Meta: model.Meta{
 					Logins: []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "not-an-ip"}},
@@ -95,13 +144,13 @@ func TestLogPayloadValidation(t *testing.T) {
 				Completed: false,
 			},
 			expectCode:   http.StatusBadRequest,
-			expectedBody: `[{"IP":"LogEntry.Meta.Logins[0].IP is invalid"}]`,
+			expectedBody: `[{"IP":"must be a valid IP address"}]`,
 		},
 		{
 			name: "invalid request - malformed timestamp",
 			payload: &model.LogEntry{
 				UserID: 3,
-				Total:  5.0,
+				Total:  decimal.MustParse("5.0"),
 				Title:  "bad timestamp",
 				Meta: model.Meta{
 					Logins: []model.Login{{Time: "08/08/2020", IP: "192.168.1.1"}},
@@ -113,13 +162,13 @@ func TestLogPayloadValidation(t *testing.T) {
 				Completed: true,
 			},
 			expectCode:   http.StatusBadRequest,
-			expectedBody: `[{"Time":"LogEntry.Meta.Logins[0].Time is invalid"}]`,
+			expectedBody: `[{"Time":"must be a valid datetime in RFC3339 format"}]`,
 		},
 		{
 			name: "invalid request - missing phone",
 			payload: &model.LogEntry{
 				UserID: 4,
-				Total:  15.0,
+				Total:  decimal.MustParse("15.0"),
 				Title:  "missing phone",
 				Meta: model.Meta{
 					Logins: []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "10.0.0.1"}},
@@ -137,7 +186,7 @@ func TestLogPayloadValidation(t *testing.T) {
 			name: "invalid request - total is zero",
 			payload: &model.LogEntry{
 				UserID: 5,
-				Total:  0,
+				Total:  decimal.MustParse("0"),
 				Title:  "zero total",
 				Meta: model.Meta{
 					Logins: []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "1.1.1.1"}},
@@ -154,7 +203,7 @@ func TestLogPayloadValidation(t *testing.T) {
 			name: "invalid request - empty title",
 			payload: &model.LogEntry{
 				UserID: 6,
-				Total:  12.5,
+				Total:  decimal.MustParse("12.5"),
 				Title:  "",
 				Meta: model.Meta{
 					Logins: []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "8.8.8.8"}},
@@ -169,7 +218,7 @@ func TestLogPayloadValidation(t *testing.T) {
 		},
 		{
 			name:         "invalid request - timestamp parsing failure",
-			expectedBody: `[{"Time":"LogEntry.Meta.Logins[0].Time is invalid"}]`,
+			expectedBody: `[{"Time":"must be a valid datetime in RFC3339 format"}]`,
 			expectCode:   http.StatusBadRequest,
 			payload:      nil,
 			rawBody:      `{"user_id":1,"total":9.99,"title":"fail ts","meta":{"logins":[{"time":"not-a-time","ip":"127.0.0.1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}},"completed":true}`,
@@ -185,7 +234,7 @@ func TestLogPayloadValidation(t *testing.T) {
 			name: "valid request with multiple logins",
 			payload: &model.LogEntry{
 				UserID: 7,
-				Total:  100.25,
+				Total:  decimal.MustParse("100.25"),
 				Title:  "multi-login",
 				Meta: model.Meta{
 					Logins: []model.Login{
@@ -228,12 +277,494 @@ func TestLogPayloadValidation(t *testing.T) {
 
 }
 
+func TestLogPayloadProblemDetails(t *testing.T) {
+	core, _ := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	err := validate.RegisterValidation("phoneformat", PhoneValidator)
+	assert.Nil(t, err)
+	batch := &mockBatcher{}
+	h := newHandler(logger, batch, validate, &config.Config{ErrorFormat: config.ErrorFormatProblem}, nil, nil, nil)
+
+	tests := []struct {
+		name         string
+		rawBody      string
+		expectCode   int
+		expectedType string
+		expectedErrs []apperror.Violation
+	}{
+		{
+			name:         "missing user_id and short title",
+			rawBody:      `{"total":9.99,"title":"ab","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`,
+			expectCode:   http.StatusBadRequest,
+			expectedType: apperror.TypeValidation,
+			expectedErrs: []apperror.Violation{
+				{Code: apperror.CodeRequired, Pointer: "/user_id", Value: float64(0), Message: "is required"},
+				{Code: apperror.CodeTooShort, Pointer: "/title", Value: "ab", Message: "must be at least 3 characters long"},
+			},
+		},
+		{
+			name:         "bad ip is reported by pointer without echoing the value",
+			rawBody:      `{"user_id":1,"total":9.99,"title":"bad ip","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"},{"time":"2020-08-08T01:52:50Z","ip":"nope"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`,
+			expectCode:   http.StatusBadRequest,
+			expectedType: apperror.TypeValidation,
+			expectedErrs: []apperror.Violation{
+				{Code: apperror.CodeInvalidIP, Pointer: "/meta/logins/1/ip", Message: "must be a valid IP address"},
+			},
+		},
+		{
+			name:         "wrong type",
+			rawBody:      `{"user_id":"one"}`,
+			expectCode:   http.StatusBadRequest,
+			expectedType: apperror.TypeInvalidPayload,
+			expectedErrs: []apperror.Violation{
+				{Code: apperror.CodeInvalidType, Pointer: "/user_id", Message: "must be of type int"},
+			},
+		},
+		{
+			name:         "malformed json",
+			rawBody:      `{`,
+			expectCode:   http.StatusBadRequest,
+			expectedType: apperror.TypeInvalidPayload,
+			expectedErrs: []apperror.Violation{
+				{Code: apperror.CodeMalformedJSON, Pointer: "", Message: "is not valid JSON"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/log", strings.NewReader(tc.rawBody))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			h.LogPayload(w, r)
+			assert.Equal(t, tc.expectCode, w.Code)
+			assert.Equal(t, apperror.ContentTypeProblem, w.Header().Get("Content-Type"))
+
+			var p apperror.Problem
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+			assert.Equal(t, tc.expectedType, p.Type)
+			assert.Equal(t, tc.expectCode, p.Status)
+			assert.Equal(t, "/log", p.Instance)
+			assert.Equal(t, tc.expectedErrs, p.Errors)
+		})
+	}
+}
+
+func TestLogPayloadLocalizedProblem(t *testing.T) {
+	core, _ := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", PhoneValidator)
+	h := newHandler(logger, &mockBatcher{}, validate, &config.Config{ErrorFormat: config.ErrorFormatProblem}, nil, nil, nil)
+
+	body := `{"user_id":1,"total":9.99,"meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`
+	r := httptest.NewRequest("POST", "/log", strings.NewReader(body))
+	r.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+	w := httptest.NewRecorder()
+
+	h.LogPayload(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "de", w.Header().Get("Content-Language"))
+
+	var p apperror.Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, []apperror.Violation{
+		{Code: apperror.CodeRequired, Pointer: "/title", Value: "", Message: "ist erforderlich"},
+	}, p.Errors)
+	assert.Equal(t, "Validierung der Anfrage fehlgeschlagen", p.Title)
+	assert.Equal(t, "Mindestens ein Feld hat die Validierung nicht bestanden.", p.Detail)
+}
+
+func TestRejectedValuesAreNotLogged(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", PhoneValidator)
+	h := newHandler(zap.New(core), &mockBatcher{}, validate, &config.Config{ErrorFormat: config.ErrorFormatProblem}, nil, nil, nil)
+
+	for _, body := range []string{
+		`{"user_id":1,"total":1,"title":"pii","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"10.9.8.777"}],"phone_numbers":{"home":"555-1212-123","mobile":"5551212999"}}}`,
+		`{"user_id":1,"total":1,"title":"pii","meta":{"logins":[],"phone_numbers":{"home":5551212999,"mobile":"555-1212-123"}}}`,
+	} {
+		w := httptest.NewRecorder()
+		h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	assert.Equal(t, 2, logs.Len())
+	for _, entry := range logs.All() {
+		encoded, err := json.Marshal(entry.ContextMap())
+		assert.NoError(t, err)
+		assert.NotContains(t, string(encoded), "10.9.8.777")
+		assert.NotContains(t, string(encoded), "5551212999")
+	}
+	assert.Equal(t, []any{"/meta/logins/0/ip: invalid_ip", "/meta/phone_numbers/mobile: invalid_phone"},
+		logs.All()[0].ContextMap()["violations"])
+}
+
+func TestLogPayloadDecimalTotal(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", PhoneValidator)
+	batch := &mockBatcher{}
+	h := newHandler(zap.NewNop(), batch, validate, &config.Config{ErrorFormat: config.ErrorFormatProblem}, nil, nil, nil)
+
+	body := `{"user_id":1,"total":99.990,"currency":"EUR","title":"amount","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`
+	w := httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(body)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	record, err := json.Marshal(batch.entries[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(record), `"total":99.990,"currency":"EUR"`)
+
+	for total, violation := range map[string]apperror.Violation{
+		"0.001": {Code: apperror.CodeTooPrecise, Pointer: "/total", Value: 0.001, Message: "must have at most 2 decimal places"},
+		"-5":    {Code: apperror.CodeTooSmall, Pointer: "/total", Value: -5.0, Message: "must be a positive number"},
+	} {
+		w = httptest.NewRecorder()
+		h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(strings.Replace(body, "99.990", total, 1))))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var p apperror.Problem
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+		assert.Equal(t, []apperror.Violation{violation}, p.Errors, total)
+	}
+
+	for _, total := range []string{"1e5000000", "1e-99999999999"} {
+		w = httptest.NewRecorder()
+		h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(strings.Replace(body, "99.990", total, 1))))
+		assert.Equal(t, http.StatusBadRequest, w.Code, total)
+	}
+
+	w = httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(strings.Replace(body, "EUR", "EURO", 1))))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_currency","pointer":"/currency"`)
+	assert.Len(t, batch.entries, 1)
+}
+
+func TestLogPayloadNormalizesPhoneNumbers(t *testing.T) {
+	core, _ := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+	cfg := &config.Config{ErrorFormat: config.ErrorFormatProblem, PhoneFormats: []string{"e164", "us"}, PhoneNormalize: true}
+	phones, err := phone.New(cfg)
+	assert.NoError(t, err)
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	assert.NoError(t, validate.RegisterValidation("phoneformat", phones.Validate))
+	batch := &mockBatcher{}
+	h := newHandler(logger, batch, validate, cfg, phones, nil, nil)
+
+	body := `{"user_id":1,"total":9.99,"title":"phones","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"}],"phone_numbers":{"home":"(415) 555-2671","mobile":"+14155550100"}}}`
+	w := httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(body)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, batch.entries, 1)
+	assert.Equal(t, model.PhoneNumbers{Home: "+14155552671", Mobile: "+14155550100"}, batch.entries[0].Meta.PhoneNumbers)
+
+	body = strings.Replace(body, "(415) 555-2671", "123-4567-891", 1)
+	w = httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"pointer":"/meta/phone_numbers/home"`)
+}
+
+func TestLogPayloadSchemaMode(t *testing.T) {
+	core, _ := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+	cfg := &config.Config{ErrorFormat: config.ErrorFormatProblem, ValidationMode: config.ValidationModeSchema, PhoneFormats: []string{"legacy"}}
+	phones, err := phone.New(cfg)
+	assert.NoError(t, err)
+	sv, err := schema.Load("", phones, nil)
+	assert.NoError(t, err)
+	batch := &mockBatcher{}
+	// The struct validator has no phoneformat tag registered, so struct validation would fail if it ran.
+	h := newHandler(logger, batch, validator.New(), cfg, phones, sv, nil)
+
+	valid := `{"user_id":1,"total":9.99,"title":"schema","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}},"completed":true}`
+	w := httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(valid)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, batch.entries, 1)
+	assert.Equal(t, "schema", batch.entries[0].Title)
+
+	invalid := strings.Replace(valid, `"title":"schema"`, `"title":"s"`, 1)
+	w = httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(invalid)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var p apperror.Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, []apperror.Violation{
+		{Code: apperror.CodeTooShort, Pointer: "/title", Value: "s", Message: "must be at least 3 characters long"},
+	}, p.Errors)
+
+	cfg.ErrorFormat = config.ErrorFormatLegacy
+	w = httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(invalid)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `[{"title":"must be at least 3 characters long"}]`, strings.TrimSpace(w.Body.String()))
+}
+
+// logEntryV0 is a hypothetical version 0 log entry, which sent the title as
+// "name".
+type logEntryV0 struct {
+	UserID int    `json:"user_id" validate:"required,gte=1"`
+	Name   string `json:"name" validate:"required,min=3"`
+}
+
+func TestLogPayloadVersioning(t *testing.T) {
+	core, _ := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", PhoneValidator)
+	versions := versioning.MustRegistry(
+		versioning.Version{Name: "0", Model: logEntryV0{}, Upgrade: func(doc map[string]any) (map[string]any, error) {
+			doc["title"] = doc["name"]
+			delete(doc, "name")
+			return doc, nil
+		}},
+		versioning.Version{Name: "1"},
+	)
+	batch := &mockBatcher{}
+	h := newHandler(logger, batch, validate, &config.Config{ErrorFormat: config.ErrorFormatProblem}, nil, nil, versions)
+
+	v0 := `{"user_id":1,"total":9.99,"name":"from v0","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`
+	r := httptest.NewRequest("POST", "/log", strings.NewReader(v0))
+	r.Header.Set(versioning.Header, "0")
+	w := httptest.NewRecorder()
+	h.LogPayload(w, r)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "from v0", batch.entries[0].Title)
+
+	v1 := strings.Replace(v0, `"name":"from v0"`, `"title":"from v1","schema_version":"1"`, 1)
+	w = httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(v1)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "from v1", batch.entries[1].Title)
+
+	// Undeclared payloads are the current version and are not upgraded.
+	unversioned := strings.Replace(v0, `"name":"from v0"`, `"title":"unversioned"`, 1)
+	w = httptest.NewRecorder()
+	h.LogPayload(w, httptest.NewRequest("POST", "/log", strings.NewReader(unversioned)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "unversioned", batch.entries[2].Title)
+
+	r = httptest.NewRequest("POST", "/log", strings.NewReader(v0))
+	r.Header.Set(versioning.Header, "3")
+	w = httptest.NewRecorder()
+	h.LogPayload(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var p apperror.Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, apperror.TypeUnsupportedVersion, p.Type)
+	assert.Equal(t, []string{"0", "1"}, p.SupportedVersions)
+	assert.Equal(t, `unsupported schema version "3"; supported versions: 0, 1`, p.Detail)
+
+	// Version 0 payloads are validated in their own shape before upgrading.
+	r = httptest.NewRequest("POST", "/log", strings.NewReader(strings.Replace(v0, `"name":"from v0"`, `"name":"v0"`, 1)))
+	r.Header.Set(versioning.Header, "0")
+	w = httptest.NewRecorder()
+	h.LogPayload(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	p = apperror.Problem{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, apperror.TypeValidation, p.Type)
+	assert.Equal(t, []apperror.Violation{
+		{Code: apperror.CodeTooShort, Pointer: "/name", Value: "v0", Message: "must be at least 3 characters long"},
+	}, p.Errors)
+	assert.Len(t, batch.entries, 3)
+}
+
+func TestSchema(t *testing.T) {
+	h := newHandler(zap.NewNop(), &mockBatcher{}, validator.New(), &config.Config{}, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	h.Schema(w, httptest.NewRequest(http.MethodGet, "/schema", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, schema.Builtin(), w.Body.Bytes())
+}
+
+func TestEvent(t *testing.T) {
+	order, err := schema.New([]byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"required": ["order_id"],
+		"properties": {"order_id": {"type": "string", "minLength": 3}}
+	}`), nil, nil)
+	assert.NoError(t, err)
+	orders := &rawBatcher{}
+	events, err := event.NewRegistry(event.New(event.Spec[json.RawMessage]{
+		Name:    "order",
+		Schema:  order,
+		Batcher: orders,
+	}))
+	assert.NoError(t, err)
+	h := New(zap.NewNop(), events, &config.Config{ErrorFormat: config.ErrorFormatProblem})
+
+	r := chi.NewRouter()
+	r.Post("/events/{type}", h.Event)
+	r.Get("/events/{type}/schema", h.EventSchema)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events/order", strings.NewReader(`{"order_id":"A-100","extra":true}`)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"order_id":"A-100","extra":true}`)}, orders.entries)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events/order", strings.NewReader(`{"order_id":"A"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var p apperror.Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, []apperror.Violation{
+		{Code: apperror.CodeTooShort, Pointer: "/order_id", Value: "A", Message: "must be at least 3 characters long"},
+	}, p.Errors)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events/refund", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	p = apperror.Problem{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, apperror.TypeUnknownEvent, p.Type)
+	assert.Equal(t, []string{"order"}, p.SupportedTypes)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events/order/schema", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, order.Raw(), w.Body.Bytes())
+	assert.Len(t, orders.entries, 1)
+}
+
+// sampleBodies returns the same log entry encoded in every accepted media type.
+func sampleBodies(t testing.TB) map[string][]byte {
+	entry := model.LogEntry{
+		UserID: 1,
+		Total:  decimal.MustParse("99.90"),
+		Title:  "binary",
+		Meta: model.Meta{
+			Logins:       []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "127.0.0.1"}},
+			PhoneNumbers: model.PhoneNumbers{Home: "555-1212-123", Mobile: "555-1212-456"},
+		},
+		Completed: true,
+	}
+	jsonBody, err := json.Marshal(entry)
+	require.NoError(t, err)
+	protoBody, err := proto.Marshal(pb.FromModel(entry))
+	require.NoError(t, err)
+	var msgpackBody bytes.Buffer
+	enc := msgpack.NewEncoder(&msgpackBody)
+	enc.SetCustomStructTag("json")
+	require.NoError(t, enc.Encode(entry))
+	return map[string][]byte{
+		"application/json":  jsonBody,
+		event.MediaProtobuf: protoBody,
+		event.MediaMsgpack:  msgpackBody.Bytes(),
+	}
+}
+
+func TestLogPayloadBinary(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", PhoneValidator)
+	batch := &mockBatcher{}
+	h := newHandler(zap.NewNop(), batch, validate, &config.Config{ErrorFormat: config.ErrorFormatProblem}, nil, nil, nil)
+
+	for mediaType, body := range sampleBodies(t) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/log", bytes.NewReader(body))
+		r.Header.Set("Content-Type", mediaType)
+		h.LogPayload(w, r)
+		assert.Equal(t, http.StatusAccepted, w.Code, mediaType)
+	}
+	require.Len(t, batch.entries, 3)
+	for _, entry := range batch.entries {
+		assert.Equal(t, batch.entries[0], entry)
+	}
+	assert.Equal(t, "99.90", batch.entries[0].Total.String())
+
+	// Binary bodies get the same validation as JSON.
+	invalid, err := proto.Marshal(&pb.LogEntry{UserId: 1, Total: "1", Title: "x"})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/log", bytes.NewReader(invalid))
+	r.Header.Set("Content-Type", event.MediaProtobuf)
+	h.LogPayload(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"too_short","pointer":"/title"`)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/log", strings.NewReader("\xc1"))
+	r.Header.Set("Content-Type", "application/x-msgpack")
+	h.LogPayload(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var p apperror.Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, []apperror.Violation{{Code: apperror.CodeMalformedBody, Message: "is not valid application/msgpack"}}, p.Errors)
+
+	// A float total must have a decimal value.
+	nan, err := msgpack.Marshal(map[string]any{"user_id": 1, "total": math.NaN(), "title": "binary"})
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/log", bytes.NewReader(nan))
+	r.Header.Set("Content-Type", event.MediaMsgpack)
+	h.LogPayload(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, batch.entries, 3)
+}
+
+func TestEventUnsupportedMediaType(t *testing.T) {
+	events, err := event.NewRegistry(event.New(event.Spec[json.RawMessage]{Name: "order", Batcher: &rawBatcher{}}))
+	assert.NoError(t, err)
+	h := New(zap.NewNop(), events, &config.Config{ErrorFormat: config.ErrorFormatProblem})
+	r := chi.NewRouter()
+	r.Post("/events/{type}", h.Event)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/events/order", strings.NewReader("\x08\x01"))
+	req.Header.Set("Content-Type", "application/x-protobuf; proto=benzinga.webhook.v1.LogEntry")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Body.String(), apperror.TypeUnsupportedMedia)
+}
+
+func BenchmarkLogPayload(b *testing.B) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", PhoneValidator)
+	batch := &mockBatcher{}
+	h := newHandler(zap.NewNop(), batch, validate, &config.Config{ErrorFormat: config.ErrorFormatProblem}, nil, nil, nil)
+
+	bodies := sampleBodies(b)
+	for _, mediaType := range []string{"application/json", event.MediaProtobuf, event.MediaMsgpack} {
+		body := bodies[mediaType]
+		b.Run(mediaType, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			for i := 0; i < b.N; i++ {
+				batch.entries = batch.entries[:0]
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodPost, "/log", bytes.NewReader(body))
+				r.Header.Set("Content-Type", mediaType)
+				h.LogPayload(w, r)
+				if w.Code != http.StatusAccepted {
+					b.Fatalf("status %d: %s", w.Code, w.Body)
+				}
+			}
+		})
+	}
+}
+
 func TestHealthz(t *testing.T) {
 	core, _ := observer.New(zapcore.InfoLevel)
 	logger := zap.New(core)
 	validate := validator.New()
 	batch := &mockBatcher{}
-	h := New(logger, batch, validate)
+	h := newHandler(logger, batch, validate, &config.Config{}, nil, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
 	w := httptest.NewRecorder()
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/replay"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Replay serves the replay endpoints under /admin.
+type Replay struct {
+	log     *zap.Logger
+	replays *replay.Manager
+}
+
+// NewReplay creates a Replay starting replays with replays.
+func NewReplay(log *zap.Logger, replays *replay.Manager) *Replay {
+	return &Replay{log: log, replays: replays}
+}
+
+// replayRequest selects the archived records to replay and the sinks to
+// replay them to. Zero fields other than Sinks match anything.
+type replayRequest struct {
+	Archive  string    `json:"archive"`
+	Sinks    []string  `json:"sinks"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Type     string    `json:"type"`
+	UserID   *int64    `json:"user_id"`
+	BatchIDs []string  `json:"batch_ids"`
+	Rate     float64   `json:"rate"`
+}
+
+// Start starts a replay in the background and responds 202 with its status,
+// to be polled at the URL in the Location header.
+func (rp *Replay) Start(w http.ResponseWriter, r *http.Request) {
+	var req replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, rp.log, apperror.DecodeProblem(err, r.URL.Path))
+		return
+	}
+	opts := replay.Options{
+		Filter: replay.Filter{From: req.From, To: req.To, Type: req.Type, BatchIDs: req.BatchIDs},
+		Rate:   req.Rate,
+	}
+	if req.UserID != nil {
+		opts.UserID = strconv.FormatInt(*req.UserID, 10)
+	}
+	var err error
+	switch {
+	case req.Rate < 0:
+		err = errors.New("rate must not be negative")
+	case !req.From.IsZero() && !req.To.IsZero() && !req.From.Before(req.To):
+		err = errors.New("from must be before to")
+	}
+	if err != nil {
+		writeProblem(w, rp.log, apperror.ReplayProblem(err, r.URL.Path))
+		return
+	}
+
+	st, err := rp.replays.Start(req.Archive, req.Sinks, opts)
+	if err != nil {
+		writeProblem(w, rp.log, apperror.ReplayProblem(err, r.URL.Path))
+		return
+	}
+	w.Header().Set("Location", r.URL.Path+"/"+st.ID)
+	rp.writeStatus(w, http.StatusAccepted, st)
+}
+
+// Status responds with the status of the replay named in the URL.
+func (rp *Replay) Status(w http.ResponseWriter, r *http.Request) {
+	st, ok := rp.replays.Status(chi.URLParam(r, "id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	rp.writeStatus(w, http.StatusOK, st)
+}
+
+// Cancel stops the replay named in the URL and responds 204. Batches already
+// delivered stay delivered.
+func (rp *Replay) Cancel(w http.ResponseWriter, r *http.Request) {
+	if !rp.replays.Cancel(chi.URLParam(r, "id")) {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rp *Replay) writeStatus(w http.ResponseWriter, code int, st replay.Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		rp.log.Error("failed to encode replay status", zap.Error(err))
+	}
+}
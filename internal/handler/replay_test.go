@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/replay"
+	"benzinga-webhook/internal/sink"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingSink keeps the batches it is sent.
+type recordingSink struct {
+	name    string
+	mu      sync.Mutex
+	batches []sink.Batch
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Send(_ context.Context, b sink.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, b)
+	return nil
+}
+
+func (s *recordingSink) sent() []sink.Batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sink.Batch(nil), s.batches...)
+}
+
+func newReplayServer(t *testing.T) (*httptest.Server, *recordingSink) {
+	dir := t.TempDir()
+	a, err := sink.NewArchive("archive", config.ArchiveConfig{Dir: dir, SegmentBytes: 1 << 20, SegmentAge: time.Hour}, zap.NewNop())
+	require.NoError(t, err)
+	for i, user := range []string{`{"user_id":1}`, `{"user_id":2}`} {
+		require.NoError(t, a.Send(context.Background(), sink.Batch{
+			ID:        fmt.Sprintf("b%d", i+1),
+			Type:      "log_entry",
+			CreatedAt: time.Date(2024, 6, 1, i, 0, 0, 0, time.UTC),
+			Records:   []json.RawMessage{json.RawMessage(user)},
+		}))
+	}
+	cfg := &config.Config{Sinks: []config.SinkConfig{
+		{Name: "archive", Type: "file", Archive: config.ArchiveConfig{Dir: dir}},
+		{Name: "warehouse", Type: "http"},
+	}}
+	warehouse := &recordingSink{name: "warehouse"}
+	m := replay.NewManager(cfg, []sink.Sink{warehouse}, zap.NewNop())
+	rp := NewReplay(zap.NewNop(), m)
+	r := chi.NewRouter()
+	r.Post("/admin/replay", rp.Start)
+	r.Get("/admin/replay/{id}", rp.Status)
+	r.Delete("/admin/replay/{id}", rp.Cancel)
+	srv := httptest.NewServer(r)
+	t.Cleanup(func() {
+		m.Close()
+		srv.Close()
+	})
+	return srv, warehouse
+}
+
+func TestReplay(t *testing.T) {
+	srv, warehouse := newReplayServer(t)
+
+	resp, err := http.Post(srv.URL+"/admin/replay", "application/json",
+		strings.NewReader(`{"sinks":["warehouse"],"from":"2024-06-01T00:30:00Z","user_id":2}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var st replay.Status
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+	assert.Equal(t, "/admin/replay/"+st.ID, resp.Header.Get("Location"))
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(srv.URL + resp.Header.Get("Location"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+		return st.State == replay.StateDone
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, st.Batches)
+	batches := warehouse.sent()
+	require.Len(t, batches, 1)
+	assert.Equal(t, "b2", batches[0].ID)
+	assert.Equal(t, st.ID, batches[0].Replay)
+
+	req, err := http.NewRequest(http.MethodDelete, srv.URL+"/admin/replay/"+st.ID, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL + "/admin/replay/nope")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestReplayInvalid(t *testing.T) {
+	srv, _ := newReplayServer(t)
+	for body, detail := range map[string]string{
+		`{"sinks":["lake"]}`:                `unknown sink "lake"`,
+		`{"sinks":["warehouse"],"rate":-1}`: "rate must not be negative",
+		`{"sinks":["warehouse"],"from":"2024-06-02T00:00:00Z","to":"2024-06-01T00:00:00Z"}`: "from must be before to",
+	} {
+		resp, err := http.Post(srv.URL+"/admin/replay", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		var p apperror.Problem
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, body)
+		assert.Equal(t, apperror.TypeInvalidReplay, p.Type)
+		assert.Equal(t, detail, p.Detail)
+	}
+}
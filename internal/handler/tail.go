@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/tail"
+
+	"go.uber.org/zap"
+)
+
+// keepAlive is how often an idle /tail stream gets a comment, so that proxies
+// do not close it.
+const keepAlive = 15 * time.Second
+
+// Tail serves GET /tail.
+type Tail struct {
+	log       *zap.Logger
+	hub       *tail.Hub
+	keepAlive time.Duration
+}
+
+// NewTail creates a Tail streaming the events published to hub.
+func NewTail(log *zap.Logger, hub *tail.Hub) *Tail {
+	return &Tail{log: log, hub: hub, keepAlive: keepAlive}
+}
+
+// Stream streams the events accepted from now on as Server-Sent Events named
+// after their type, with their sequence number as id. The type, user_id and
+// title query parameters filter them as described by tail.Filter. A stream
+// that falls too far behind ends with a "dropped" event.
+func (t *Tail) Stream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := tail.Filter{Type: q.Get("type"), UserID: q.Get("user_id"), Title: q.Get("title")}
+	if f.UserID != "" {
+		if _, err := strconv.ParseInt(f.UserID, 10, 64); err != nil {
+			writeProblem(w, t.log, apperror.ParameterProblem("user_id", "must be an integer", r.URL.Path))
+			return
+		}
+	}
+
+	sub := t.hub.Subscribe(f)
+	if sub == nil {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer t.hub.Unsubscribe(sub)
+
+	// The server's write timeout would cut the stream short.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		t.log.Error("tail stream cannot be flushed", zap.Error(err))
+		return
+	}
+	t.log.Info("tail subscriber connected", zap.String("type", f.Type))
+
+	ticker := time.NewTicker(t.keepAlive)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case ev, ok := <-sub.C:
+			if !ok {
+				if t.hub.Dropped(sub) {
+					t.log.Warn("tail subscriber dropped for falling behind")
+					_, _ = fmt.Fprint(w, "event: dropped\ndata: {}\n\n")
+					_ = rc.Flush()
+				}
+				return
+			}
+			// Records are compact JSON, so they fit on one data line.
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, ev.Record)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
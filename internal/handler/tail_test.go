@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/tail"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTailServer(t *testing.T, buffer int) (*tail.Hub, *Tail, *httptest.Server) {
+	hub, err := tail.New(config.TailConfig{Buffer: buffer, Redact: config.DefaultTailRedact}, nil)
+	require.NoError(t, err)
+	tl := NewTail(zap.NewNop(), hub)
+	srv := httptest.NewServer(RequireToken("secret")(http.HandlerFunc(tl.Stream)))
+	t.Cleanup(func() {
+		hub.Close()
+		srv.Close()
+	})
+	return hub, tl, srv
+}
+
+func tailEntry(userID int) model.LogEntry {
+	return model.LogEntry{
+		UserID: userID,
+		Title:  "Order",
+		Meta: model.Meta{
+			Logins:       []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "81.2.69.142"}},
+			PhoneNumbers: model.PhoneNumbers{Home: "555-1212-123", Mobile: "555-1212-456"},
+		},
+	}
+}
+
+func TestTailStreamsMatchingEvents(t *testing.T) {
+	hub, tl, srv := newTailServer(t, 8)
+	tl.keepAlive = 10 * time.Millisecond
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/tail?user_id=2", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, 2*time.Second, 5*time.Millisecond)
+	hub.Publish("log_entry", tailEntry(1))
+	hub.Publish("log_entry", tailEntry(2))
+
+	lines := bufio.NewScanner(resp.Body)
+	var frame []string
+	for lines.Scan() && (len(frame) == 0 || lines.Text() != "") {
+		if line := lines.Text(); line != "" && !strings.HasPrefix(line, ":") {
+			frame = append(frame, line)
+		}
+	}
+	require.Len(t, frame, 3)
+	assert.Equal(t, "id: 1", frame[0])
+	assert.Equal(t, "event: log_entry", frame[1])
+	assert.Contains(t, frame[2], `"user_id":2`)
+	assert.Contains(t, frame[2], `"ip":"81.2.69.0"`)
+	assert.NotContains(t, frame[2], "555-1212")
+
+	// Idle streams get keep-alive comments.
+	for lines.Scan() && lines.Text() != ": keep-alive" {
+	}
+	assert.NoError(t, lines.Err())
+}
+
+func TestTailRequiresToken(t *testing.T) {
+	_, _, srv := newTailServer(t, 8)
+	resp, err := http.Get(srv.URL + "/tail")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestTailInvalidFilter(t *testing.T) {
+	hub, err := tail.New(config.TailConfig{}, nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	NewTail(zap.NewNop(), hub).Stream(w, httptest.NewRequest(http.MethodGet, "/tail?user_id=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), apperror.TypeInvalidParameter)
+	assert.Zero(t, hub.Subscribers())
+}
+
+// blockingWriter holds every write until gate is closed, like a subscriber
+// on a slow connection.
+type blockingWriter struct {
+	header http.Header
+	gate   chan struct{}
+	mu     sync.Mutex
+	buf    bytes.Buffer
+}
+
+func (w *blockingWriter) Header() http.Header { return w.header }
+func (w *blockingWriter) WriteHeader(int)     {}
+func (w *blockingWriter) Flush()              {}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func TestTailDropsSlowSubscriber(t *testing.T) {
+	hub, err := tail.New(config.TailConfig{Buffer: 1}, nil)
+	require.NoError(t, err)
+	w := &blockingWriter{header: http.Header{}, gate: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		NewTail(zap.NewNop(), hub).Stream(w, httptest.NewRequest(http.MethodGet, "/tail", nil).WithContext(ctx))
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	// The first event is being written, the second buffered and the third
+	// does not fit.
+	hub.Publish("log_entry", tailEntry(1))
+	require.Eventually(t, func() bool {
+		hub.Publish("log_entry", tailEntry(1))
+		return hub.Subscribers() == 0
+	}, 2*time.Second, 5*time.Millisecond)
+	close(w.gate)
+	<-done
+
+	out := w.buf.String()
+	assert.True(t, strings.HasSuffix(out, "event: dropped\ndata: {}\n\n"), out)
+}
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/event"
+	"benzinga-webhook/internal/versioning"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxFrameSize bounds a single frame; larger ones close the connection.
+	maxFrameSize = 1 << 20
+	// pauseAbove is the batcher load from which frames are no longer read, so
+	// that producers are slowed down by TCP flow control instead of having
+	// their entries dropped.
+	pauseAbove = 0.8
+	// pausePoll is how often a paused connection checks the load again.
+	pausePoll = 50 * time.Millisecond
+	// writeWait bounds writing a single frame.
+	writeWait = 10 * time.Second
+)
+
+// Statuses of an Ack frame.
+const (
+	StatusAck  = "ack"
+	StatusNack = "nack"
+)
+
+// Ack is the frame sent back for every frame received, in the order they were
+// received. Seq numbers the frames of a connection from 1. A nack carries the
+// problem document POST /log would have responded with.
+type Ack struct {
+	Seq     int               `json:"seq"`
+	Status  string            `json:"status"`
+	Problem *apperror.Problem `json:"problem,omitempty"`
+}
+
+// WebSocket serves GET /ws/log, where long-lived producers stream log entries
+// over one connection: text frames hold JSON entries, as accepted by POST
+// /log, and binary frames protobuf ones.
+type WebSocket struct {
+	log        *zap.Logger
+	events     *event.Registry
+	limit      int
+	ping       time.Duration
+	pauseAbove float64
+	upgrader   websocket.Upgrader
+	quit       chan struct{}
+
+	mu     sync.Mutex // guards the fields below
+	open   int        // connections accepted, including those still upgrading
+	conns  map[*websocket.Conn]struct{}
+	closed bool
+}
+
+// NewWebSocket creates a WebSocket accepting up to cfg.WSMaxConnections
+// connections.
+func NewWebSocket(log *zap.Logger, events *event.Registry, cfg *config.Config) *WebSocket {
+	return &WebSocket{
+		log:        log,
+		events:     events,
+		limit:      cfg.WSMaxConnections,
+		ping:       cfg.WSPingInterval,
+		pauseAbove: pauseAbove,
+		quit:       make(chan struct{}),
+		conns:      make(map[*websocket.Conn]struct{}),
+	}
+}
+
+// Log upgrades the request to a WebSocket connection and ingests every frame
+// received as an event.LogEntry, acknowledging each with an Ack. The
+// Accept-Language and schema version headers of the request apply to every
+// frame. Requests beyond the connection limit are refused with 503.
+func (ws *WebSocket) Log(w http.ResponseWriter, r *http.Request) {
+	t, ok := ws.events.Lookup(event.LogEntry)
+	if !ok {
+		writeProblem(w, ws.log, apperror.UnknownEventProblem(event.LogEntry, r.URL.Path, ws.events.Names()))
+		return
+	}
+	if !ws.reserve() {
+		ws.log.Warn("websocket connection refused", zap.Int("limit", ws.limit))
+		writeProblem(w, ws.log, apperror.ConnectionLimitProblem(ws.limit, r.URL.Path))
+		return
+	}
+	// The upgrader responds to requests it rejects itself.
+	conn, err := ws.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		ws.release(nil)
+		return
+	}
+	if !ws.register(conn) {
+		ws.release(conn)
+		_ = conn.Close()
+		return
+	}
+	defer func() {
+		ws.release(conn)
+		_ = conn.Close()
+	}()
+
+	trans := apperror.TranslatorFor(r.Header.Get("Accept-Language"))
+	ws.serve(conn, t, trans, r.Header.Get(versioning.Header), r.URL.Path)
+}
+
+// Close closes every open connection with a going-away close frame and
+// refuses new ones. It is called on shutdown, as http.Server.Shutdown does not
+// wait for hijacked connections.
+func (ws *WebSocket) Close() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return
+	}
+	ws.closed = true
+	close(ws.quit)
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for conn := range ws.conns {
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+		_ = conn.Close()
+	}
+}
+
+// reserve counts a new connection unless the limit is reached.
+func (ws *WebSocket) reserve() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed || ws.open >= ws.limit {
+		return false
+	}
+	ws.open++
+	return true
+}
+
+// register tracks conn so that Close can reach it. It reports false when the
+// WebSocket was closed while conn was upgrading.
+func (ws *WebSocket) register(conn *websocket.Conn) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return false
+	}
+	ws.conns[conn] = struct{}{}
+	return true
+}
+
+// release undoes reserve and register.
+func (ws *WebSocket) release(conn *websocket.Conn) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.open--
+	delete(ws.conns, conn)
+}
+
+// serve reads frames until the connection fails, is closed or misses a pong.
+func (ws *WebSocket) serve(conn *websocket.Conn, t event.Type, trans ut.Translator, version, instance string) {
+	done := make(chan struct{})
+	defer close(done)
+	go ws.heartbeat(conn, done)
+
+	conn.SetReadLimit(maxFrameSize)
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * ws.ping))
+	})
+	conn.SetPingHandler(func(data string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * ws.ping))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeWait))
+		var netErr net.Error
+		if errors.Is(err, websocket.ErrCloseSent) || errors.As(err, &netErr) {
+			return nil
+		}
+		return err
+	})
+
+	for seq := 1; ; seq++ {
+		if !ws.wait(t) {
+			return
+		}
+		// The deadline is only set while reading, so that a paused connection
+		// is not dropped for the pongs it does not read.
+		_ = conn.SetReadDeadline(time.Now().Add(2 * ws.ping))
+		kind, body, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				ws.log.Info("websocket connection lost", zap.Error(err))
+			}
+			return
+		}
+
+		ack := Ack{Seq: seq, Status: StatusAck}
+		if p := ws.ingest(t, kind, body, trans, version, instance); p != nil {
+			ack.Status, ack.Problem = StatusNack, p
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := conn.WriteJSON(ack); err != nil {
+			return
+		}
+	}
+}
+
+// wait blocks while the batcher of t is loaded above pauseAbove. It reports
+// false when the WebSocket is closed meanwhile.
+func (ws *WebSocket) wait(t event.Type) bool {
+	if t.Load() < ws.pauseAbove {
+		return true
+	}
+	ws.log.Debug("websocket paused", zap.String("type", t.Name()))
+	for t.Load() >= ws.pauseAbove {
+		select {
+		case <-ws.quit:
+			return false
+		case <-time.After(pausePoll):
+		}
+	}
+	return true
+}
+
+// heartbeat pings conn every ping interval until done is closed.
+func (ws *WebSocket) heartbeat(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(ws.ping)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ingest ingests one frame and returns the problem it was rejected with, or
+// nil.
+func (ws *WebSocket) ingest(t event.Type, kind int, body []byte, trans ut.Translator, version, instance string) *apperror.Problem {
+	var err error
+	mediaType := ""
+	if kind == websocket.BinaryMessage {
+		mediaType = event.MediaProtobuf
+		err = t.IngestAs(mediaType, body, trans)
+	} else {
+		var detected string
+		body, detected, err = t.Upgrade(body, version)
+		var decodeErr *event.DecodeError
+		var validationErr *event.ValidationError
+		if err != nil && !errors.As(err, &decodeErr) && !errors.As(err, &validationErr) {
+			ws.log.Warn("schema version rejected", zap.String("version", detected), zap.Error(err))
+			return apperror.VersionProblem(err, instance, t.Versions().Supported())
+		}
+		if err == nil {
+			err = t.Ingest(body, trans)
+		}
+	}
+
+	var validationErr *event.ValidationError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &validationErr) && validationErr.Err != nil:
+		ws.log.Warn("validation failed", zap.Strings("violations", apperror.Summary(apperror.Violations(validationErr.Err, nil))))
+		return apperror.ValidationProblem(validationErr.Err, instance, trans)
+	case errors.As(err, &validationErr):
+		ws.log.Warn("schema validation failed", zap.Strings("violations", apperror.Summary(validationErr.Violations)))
+		return apperror.ViolationsProblem(validationErr.Violations, instance, trans)
+	case errors.Is(err, event.ErrUnsupportedMediaType):
+		return apperror.UnsupportedMediaProblem(mediaType, instance)
+	case mediaType != "":
+		ws.log.Error("failed to decode frame", zap.String("content_type", mediaType))
+		return apperror.BodyProblem(mediaType, instance)
+	default:
+		p := apperror.DecodeProblem(err, instance)
+		ws.log.Error("failed to decode json", zap.Strings("violations", apperror.Summary(p.Errors)))
+		return p
+	}
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/event"
+	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/pb"
+	"benzinga-webhook/internal/timestamp"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// loadBatcher records entries and reports a load set by the test.
+type loadBatcher struct {
+	mu      sync.Mutex
+	entries []model.LogEntry
+	load    atomic.Value // of float64
+}
+
+func (b *loadBatcher) Add(entry model.LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, entry)
+}
+
+func (b *loadBatcher) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+func (b *loadBatcher) Load() float64 {
+	load, _ := b.load.Load().(float64)
+	return load
+}
+
+func (b *loadBatcher) Start() {}
+func (b *loadBatcher) Stop()  {}
+
+// newWebSocketServer serves a WebSocket for a log_entry type batched into b.
+func newWebSocketServer(t *testing.T, b *loadBatcher, cfg *config.Config) (*WebSocket, string) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", PhoneValidator)
+	_ = decimal.RegisterValidations(validate)
+	_ = (&timestamp.Policy{}).RegisterValidations(validate)
+	events, err := event.NewRegistry(event.New(event.Spec[model.LogEntry]{
+		Name:     event.LogEntry,
+		Validate: validate,
+		Batcher:  b,
+		Decoders: map[string]event.Decoder[model.LogEntry]{event.MediaProtobuf: pb.UnmarshalLogEntry},
+	}))
+	require.NoError(t, err)
+	ws := NewWebSocket(zap.NewNop(), events, cfg)
+	srv := httptest.NewServer(http.HandlerFunc(ws.Log))
+	t.Cleanup(func() {
+		ws.Close()
+		srv.Close()
+	})
+	return ws, "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/log"
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readAck(t *testing.T, conn *websocket.Conn) Ack {
+	var ack Ack
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	require.NoError(t, conn.ReadJSON(&ack))
+	return ack
+}
+
+func TestWebSocketAcks(t *testing.T) {
+	b := &loadBatcher{}
+	_, url := newWebSocketServer(t, b, &config.Config{WSMaxConnections: 1, WSPingInterval: time.Minute})
+	conn := dial(t, url)
+	bodies := sampleBodies(t)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, bodies["application/json"]))
+	assert.Equal(t, Ack{Seq: 1, Status: StatusAck}, readAck(t, conn))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"user_id":1,"total":1,"title":"x"}`)))
+	ack := readAck(t, conn)
+	assert.Equal(t, 2, ack.Seq)
+	assert.Equal(t, StatusNack, ack.Status)
+	if assert.NotNil(t, ack.Problem) {
+		assert.Equal(t, apperror.TypeValidation, ack.Problem.Type)
+		assert.Equal(t, "/ws/log", ack.Problem.Instance)
+		assert.Contains(t, apperror.Summary(ack.Problem.Errors), "/title: too_short")
+	}
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{`)))
+	ack = readAck(t, conn)
+	assert.Equal(t, StatusNack, ack.Status)
+	assert.Equal(t, apperror.TypeInvalidPayload, ack.Problem.Type)
+
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, bodies[event.MediaProtobuf]))
+	assert.Equal(t, Ack{Seq: 4, Status: StatusAck}, readAck(t, conn))
+
+	invalid, err := proto.Marshal(&pb.LogEntry{UserId: 1, Total: "1", Title: "x"})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, invalid))
+	ack = readAck(t, conn)
+	assert.Equal(t, StatusNack, ack.Status)
+	assert.Equal(t, apperror.TypeValidation, ack.Problem.Type)
+
+	assert.Equal(t, 2, b.Len())
+}
+
+func TestWebSocketConnectionLimit(t *testing.T) {
+	_, url := newWebSocketServer(t, &loadBatcher{}, &config.Config{WSMaxConnections: 1, WSPingInterval: time.Minute})
+	first := dial(t, url)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	var p apperror.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	assert.Equal(t, apperror.TypeTooManyConnections, p.Type)
+
+	// The slot is freed once the first connection is gone.
+	require.NoError(t, first.Close())
+	assert.Eventually(t, func() bool {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocketPausesWhileQueueIsFull(t *testing.T) {
+	b := &loadBatcher{}
+	b.load.Store(0.9)
+	_, url := newWebSocketServer(t, b, &config.Config{WSMaxConnections: 2, WSPingInterval: time.Minute})
+	conn := dial(t, url)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, sampleBodies(t)["application/json"]))
+	_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err := conn.ReadMessage()
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr) && netErr.Timeout(), "frame read while paused: %v", err)
+	assert.Zero(t, b.Len())
+
+	// A timed out gorilla connection cannot be read again, so a new one is
+	// used to check that reading resumes.
+	b.load.Store(0.0)
+	conn = dial(t, url)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, sampleBodies(t)["application/json"]))
+	assert.Equal(t, StatusAck, readAck(t, conn).Status)
+}
+
+func TestWebSocketHeartbeat(t *testing.T) {
+	_, url := newWebSocketServer(t, &loadBatcher{}, &config.Config{WSMaxConnections: 1, WSPingInterval: 20 * time.Millisecond})
+	conn := dial(t, url)
+
+	// Pings that are not answered get the connection closed.
+	var pings atomic.Int32
+	conn.SetPingHandler(func(string) error {
+		pings.Add(1)
+		return nil
+	})
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	var netErr net.Error
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "connection not closed: %v", err)
+	assert.Positive(t, pings.Load())
+}
+
+func TestWebSocketClose(t *testing.T) {
+	ws, url := newWebSocketServer(t, &loadBatcher{}, &config.Config{WSMaxConnections: 1, WSPingInterval: time.Minute})
+	conn := dial(t, url)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, sampleBodies(t)["application/json"]))
+	readAck(t, conn)
+
+	ws.Close()
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "%v", err)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,123 @@
+// Package ingest serves the gRPC Ingest service declared in
+// internal/pb/ingest.proto, feeding the same event types as package handler.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/event"
+	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/pb"
+
+	ut "github.com/go-playground/universal-translator"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements pb.IngestServer.
+type Server struct {
+	pb.UnimplementedIngestServer
+	log    *zap.Logger
+	events *event.Registry
+}
+
+// New returns a Server queueing entries to the event.LogEntry type of events.
+func New(log *zap.Logger, events *event.Registry) *Server {
+	return &Server{log: log, events: events}
+}
+
+// Log validates and queues one entry.
+func (s *Server) Log(ctx context.Context, m *pb.LogEntry) (*pb.LogResult, error) {
+	t, err := s.logEntries()
+	if err != nil {
+		return nil, err
+	}
+	res := s.ingest(t, m, translator(ctx))
+	if res.Accepted {
+		return res, nil
+	}
+	st := status.New(codes.InvalidArgument, res.Error)
+	if detailed, err := st.WithDetails(res); err == nil {
+		st = detailed
+	}
+	return nil, st.Err()
+}
+
+// LogStream validates and queues every entry of the stream.
+func (s *Server) LogStream(stream pb.Ingest_LogStreamServer) error {
+	t, err := s.logEntries()
+	if err != nil {
+		return err
+	}
+	trans := translator(stream.Context())
+	out := &pb.LogStreamResult{}
+	for index := uint32(0); ; index++ {
+		m, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(out)
+		}
+		if err != nil {
+			return err
+		}
+		res := s.ingest(t, m, trans)
+		if res.Accepted {
+			out.Accepted++
+			continue
+		}
+		res.Index = index
+		out.Rejected++
+		out.Rejections = append(out.Rejections, res)
+	}
+}
+
+func (s *Server) logEntries() (event.Type, error) {
+	t, ok := s.events.Lookup(event.LogEntry)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "event type %q is not registered", event.LogEntry)
+	}
+	return t, nil
+}
+
+// ingest converts, validates and queues m, and reports the outcome.
+func (s *Server) ingest(t event.Type, m *pb.LogEntry, trans ut.Translator) *pb.LogResult {
+	entry, err := m.Model()
+	if err != nil {
+		return &pb.LogResult{Error: err.Error()}
+	}
+	err = event.IngestEntry[model.LogEntry](t, entry, trans)
+	var verr *event.ValidationError
+	switch {
+	case err == nil:
+		return &pb.LogResult{Accepted: true}
+	case errors.As(err, &verr):
+		violations := verr.Violations
+		if verr.Err != nil {
+			violations = apperror.Violations(verr.Err, trans)
+		}
+		// Only the summary is logged, so rejected values never reach the logs.
+		s.log.Warn("validation failed", zap.String("transport", "grpc"), zap.Strings("violations", apperror.Summary(violations)))
+		res := &pb.LogResult{Error: "validation failed"}
+		for _, v := range violations {
+			res.Violations = append(res.Violations, &pb.Violation{Code: v.Code, Pointer: v.Pointer, Message: v.Message})
+		}
+		return res
+	default:
+		return &pb.LogResult{Error: err.Error()}
+	}
+}
+
+// translator picks the language of violation messages from the
+// accept-language metadata of the call.
+func translator(ctx context.Context) ut.Translator {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var acceptLanguage string
+	if values := md.Get("accept-language"); len(values) > 0 {
+		acceptLanguage = values[0]
+	}
+	return apperror.TranslatorFor(acceptLanguage)
+}
@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/event"
+	"benzinga-webhook/internal/handler"
+	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/pb"
+	"benzinga-webhook/internal/timestamp"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type recorder struct {
+	entries []model.LogEntry
+	panics  bool
+}
+
+func (r *recorder) Add(entry model.LogEntry) {
+	if r.panics {
+		panic("queue broken")
+	}
+	r.entries = append(r.entries, entry)
+}
+
+func (r *recorder) Start() {}
+func (r *recorder) Stop()  {}
+
+// newClient serves a Server over an in-process listener and returns a client
+// for it with the batcher entries are queued to.
+func newClient(t *testing.T) (pb.IngestClient, *recorder) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	_ = validate.RegisterValidation("phoneformat", handler.PhoneValidator)
+	require.NoError(t, decimal.RegisterValidations(validate))
+	require.NoError(t, (&timestamp.Policy{}).RegisterValidations(validate))
+	b := &recorder{}
+	events, err := event.NewRegistry(event.New(event.Spec[model.LogEntry]{
+		Name:     event.LogEntry,
+		Validate: validate,
+		Batcher:  b,
+	}))
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer(Recovery(zap.NewNop())...)
+	pb.RegisterIngestServer(gs, New(zap.NewNop(), events))
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewIngestClient(conn), b
+}
+
+func valid(userID int64) *pb.LogEntry {
+	return &pb.LogEntry{
+		UserId: userID,
+		Total:  "99.90",
+		Title:  "grpc entry",
+		Meta: &pb.Meta{
+			Logins:       []*pb.Login{{Time: "2020-08-08T01:52:50Z", Ip: "127.0.0.1"}},
+			PhoneNumbers: &pb.PhoneNumbers{Home: "555-1212-123", Mobile: "555-1212-456"},
+		},
+	}
+}
+
+func TestLog(t *testing.T) {
+	client, b := newClient(t)
+	ctx := context.Background()
+
+	res, err := client.Log(ctx, valid(1))
+	require.NoError(t, err)
+	assert.True(t, res.GetAccepted())
+	require.Len(t, b.entries, 1)
+	assert.Equal(t, "99.90", b.entries[0].Total.String())
+
+	invalid := valid(2)
+	invalid.Title = "x"
+	invalid.Meta.Logins[0].Ip = "not-an-ip"
+	_, err = client.Log(metadata.AppendToOutgoingContext(ctx, "accept-language", "de"), invalid)
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	detail := st.Details()[0].(*pb.LogResult)
+	assert.False(t, detail.GetAccepted())
+	var pointers []string
+	for _, v := range detail.GetViolations() {
+		pointers = append(pointers, v.GetPointer())
+		assert.NotEmpty(t, v.GetMessage())
+	}
+	assert.ElementsMatch(t, []string{"/title", "/meta/logins/0/ip"}, pointers)
+
+	invalid = valid(3)
+	invalid.Total = "ten"
+	_, err = client.Log(ctx, invalid)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Len(t, b.entries, 1)
+}
+
+func TestLogStream(t *testing.T) {
+	client, b := newClient(t)
+
+	stream, err := client.LogStream(context.Background())
+	require.NoError(t, err)
+	invalid := valid(2)
+	invalid.UserId = 0
+	for _, m := range []*pb.LogEntry{valid(1), invalid, valid(3)} {
+		require.NoError(t, stream.Send(m))
+	}
+	res, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint32(2), res.GetAccepted())
+	assert.Equal(t, uint32(1), res.GetRejected())
+	require.Len(t, res.GetRejections(), 1)
+	rejection := res.GetRejections()[0]
+	assert.Equal(t, uint32(1), rejection.GetIndex())
+	if assert.Len(t, rejection.GetViolations(), 1) {
+		v := rejection.GetViolations()[0]
+		assert.Equal(t, apperror.CodeRequired, v.GetCode())
+		assert.Equal(t, "/user_id", v.GetPointer())
+	}
+	require.Len(t, b.entries, 2)
+	assert.Equal(t, []int{1, 3}, []int{b.entries[0].UserID, b.entries[1].UserID})
+}
+
+func TestRecovery(t *testing.T) {
+	client, b := newClient(t)
+	ctx := context.Background()
+	b.panics = true
+
+	_, err := client.Log(ctx, valid(1))
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	stream, err := client.LogStream(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(valid(2)))
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// The server is still serving.
+	b.panics = false
+	res, err := client.Log(ctx, valid(3))
+	require.NoError(t, err)
+	assert.True(t, res.Accepted)
+	require.Len(t, b.entries, 1)
+}
@@ -0,0 +1,32 @@
+package ingest
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recovery returns the server options that turn a panic in a call into a
+// codes.Internal error, as net/http recovers a panic in a request, instead of
+// crashing the server.
+func Recovery(log *zap.Logger) []grpc.ServerOption {
+	recovered := func(method string, err *error) {
+		if p := recover(); p != nil {
+			log.Error("gRPC call panicked", zap.String("method", method), zap.Any("panic", p), zap.Stack("stack"))
+			*err = status.Error(codes.Internal, "internal error")
+		}
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+			defer recovered(info.FullMethod, &err)
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+			defer recovered(info.FullMethod, &err)
+			return handler(srv, ss)
+		}),
+	}
+}
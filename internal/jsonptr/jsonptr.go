@@ -0,0 +1,105 @@
+// Package jsonptr matches RFC 6901 JSON pointers, extended with a wildcard, in
+// documents decoded into map[string]any and []any.
+package jsonptr
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Wildcard matches any array index or object key.
+const Wildcard = "*"
+
+// Split returns the unescaped reference tokens of pointer.
+func Split(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens
+}
+
+// Escape returns token escaped for use in a pointer.
+func Escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// Rewrite calls fn for every value in doc matched by tokens and replaces it
+// with the returned value. When fn returns keep=false the value is removed
+// from its object, or nulled in its array so later indices do not shift.
+func Rewrite(doc any, tokens []string, fn func(v any) (replacement any, keep bool)) {
+	RewritePath(doc, tokens, func(_ string, v any) (any, bool) { return fn(v) })
+}
+
+// RewritePath is like Rewrite but also passes fn the pointer of each value,
+// with wildcards resolved, e.g. "/meta/logins/0/ip" for "/meta/logins/*/ip".
+func RewritePath(doc any, tokens []string, fn func(pointer string, v any) (replacement any, keep bool)) {
+	rewrite(doc, "", tokens, fn)
+}
+
+func rewrite(doc any, prefix string, tokens []string, fn func(pointer string, v any) (any, bool)) {
+	if len(tokens) == 0 {
+		return
+	}
+	last := len(tokens) == 1
+	switch n := doc.(type) {
+	case map[string]any:
+		for key, child := range n {
+			if tokens[0] != Wildcard && tokens[0] != key {
+				continue
+			}
+			pointer := prefix + "/" + Escape(key)
+			if !last {
+				rewrite(child, pointer, tokens[1:], fn)
+				continue
+			}
+			if v, keep := fn(pointer, child); keep {
+				n[key] = v
+			} else {
+				delete(n, key)
+			}
+		}
+	case []any:
+		for i, child := range n {
+			if tokens[0] != Wildcard && tokens[0] != strconv.Itoa(i) {
+				continue
+			}
+			pointer := prefix + "/" + strconv.Itoa(i)
+			if !last {
+				rewrite(child, pointer, tokens[1:], fn)
+				continue
+			}
+			if v, keep := fn(pointer, child); keep {
+				n[i] = v
+			} else {
+				n[i] = nil
+			}
+		}
+	}
+}
+
+// Get returns the value tokens point to in doc. Wildcard is not expanded.
+func Get(doc any, tokens []string) (any, bool) {
+	for _, t := range tokens {
+		switch n := doc.(type) {
+		case map[string]any:
+			v, ok := n[t]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []any:
+			i, err := strconv.Atoi(t)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, false
+			}
+			doc = n[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
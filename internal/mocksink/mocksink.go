@@ -0,0 +1,316 @@
+// Package mocksink is a fake HTTP sink for tests and local end-to-end runs. It
+// records the batches posted to it and answers them as a scripted scenario
+// says, injecting latency, error statuses, Retry-After headers, connection
+// resets and partial reads.
+package mocksink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIPrefix starts the paths of the inspection API. Requests to any other
+// path are answered as batches.
+const APIPrefix = "/_mock"
+
+// Duration is a time.Duration written as a string such as "250ms" in JSON.
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	*d = Duration(v)
+	return err
+}
+
+// Step says how to answer requests. The zero Step reads the whole body and
+// responds 200 at once.
+type Step struct {
+	// Status is the response status, 200 when zero.
+	Status int `json:"status,omitempty"`
+	// Delay is how long to wait before responding.
+	Delay Duration `json:"delay,omitempty"`
+	// RetryAfter is sent as the Retry-After header, e.g. "2".
+	RetryAfter string `json:"retry_after,omitempty"`
+	// Reset closes the connection with a TCP reset instead of responding.
+	Reset bool `json:"reset,omitempty"`
+	// ReadBytes stops reading the body after that many bytes, before
+	// responding or resetting. Zero reads all of it. With Reset, the client
+	// is cut off while still sending.
+	ReadBytes int `json:"read_bytes,omitempty"`
+	// Times is how many requests the step answers, 1 when zero.
+	Times int `json:"times,omitempty"`
+}
+
+// Scenario lists the steps answering requests, in order. Once all but the
+// last have been used up, the last answers every request; an empty scenario
+// responds 200 to all of them.
+type Scenario struct {
+	Steps []Step `json:"steps"`
+}
+
+// Request is a request the mock received.
+type Request struct {
+	// Seq numbers requests from 1.
+	Seq        int         `json:"seq"`
+	ReceivedAt time.Time   `json:"received_at"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Header     http.Header `json:"header"`
+	// Records holds the records of a JSON array or NDJSON body read in full.
+	Records []json.RawMessage `json:"records,omitempty"`
+	// Body holds the body as read when it is not JSON or was read in part.
+	Body    []byte `json:"body,omitempty"`
+	Partial bool   `json:"partial,omitempty"`
+	// Status is the response status, 0 when the connection was reset.
+	Status int `json:"status"`
+}
+
+// Accepted reports whether the request was read in full and answered 2xx,
+// which a sink takes as delivery.
+func (r Request) Accepted() bool {
+	return !r.Partial && r.Status >= 200 && r.Status < 300
+}
+
+// Server is the mock sink, an http.Handler. It is safe for concurrent use.
+type Server struct {
+	// OnRequest, if set, is called with every request recorded.
+	OnRequest func(Request)
+
+	api http.Handler
+
+	mu       sync.Mutex // guards the fields below
+	scenario Scenario
+	step     int // index into scenario.Steps
+	used     int // requests answered by the current step
+	requests []Request
+}
+
+// New creates a Server answering requests as scenario says.
+func New(scenario Scenario) *Server {
+	s := &Server{scenario: scenario}
+	r := chi.NewRouter()
+	r.Get(APIPrefix+"/requests", s.serveRequests)
+	r.Delete(APIPrefix+"/requests", s.serveReset)
+	r.Get(APIPrefix+"/scenario", s.serveScenario)
+	r.Put(APIPrefix+"/scenario", s.serveSetScenario)
+	s.api = r
+	return s
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Batches returns the records of the accepted requests, one slice per batch.
+func (s *Server) Batches() [][]json.RawMessage {
+	var batches [][]json.RawMessage
+	for _, r := range s.Requests() {
+		if r.Accepted() {
+			batches = append(batches, r.Records)
+		}
+	}
+	return batches
+}
+
+// Hits returns how many requests were received.
+func (s *Server) Hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// SetScenario replaces the scenario and starts it from its first step.
+func (s *Server) SetScenario(scenario Scenario) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scenario, s.step, s.used = scenario, 0, 0
+}
+
+// Reset forgets the requests received and restarts the scenario.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests, s.step, s.used = nil, 0, 0
+}
+
+// next returns the step answering the next request.
+func (s *Server) next() Step {
+	steps := s.scenario.Steps
+	if len(steps) == 0 {
+		return Step{}
+	}
+	st := steps[s.step]
+	s.used++
+	if s.step < len(steps)-1 && s.used >= max(st.Times, 1) {
+		s.step, s.used = s.step+1, 0
+	}
+	return st
+}
+
+// ServeHTTP answers a batch, or an inspection API request under APIPrefix.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == APIPrefix || strings.HasPrefix(r.URL.Path, APIPrefix+"/") {
+		s.api.ServeHTTP(w, r)
+		return
+	}
+
+	req := Request{ReceivedAt: time.Now().UTC(), Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone()}
+	s.mu.Lock()
+	st := s.next()
+	s.mu.Unlock()
+
+	var body io.Reader = r.Body
+	if st.ReadBytes > 0 {
+		body = io.LimitReader(r.Body, int64(st.ReadBytes))
+	}
+	raw, err := io.ReadAll(body)
+	switch {
+	case err != nil:
+		req.Partial = true
+	case st.ReadBytes > 0 && len(raw) == st.ReadBytes:
+		// The body was cut short if there is more of it.
+		var more [1]byte
+		n, _ := io.ReadFull(r.Body, more[:])
+		req.Partial = n == 1
+	}
+	if records, ok := parseRecords(raw); ok && !req.Partial {
+		req.Records = records
+	} else {
+		req.Body = raw
+	}
+	if st.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Duration(st.Delay)):
+		}
+	}
+	if !st.Reset {
+		req.Status = st.Status
+		if req.Status == 0 {
+			req.Status = http.StatusOK
+		}
+	}
+	s.record(req)
+
+	if st.Reset {
+		reset(w)
+		return
+	}
+	if st.RetryAfter != "" {
+		w.Header().Set("Retry-After", st.RetryAfter)
+	}
+	w.WriteHeader(req.Status)
+	if req.Status >= 400 {
+		_, _ = io.WriteString(w, http.StatusText(req.Status)+"\n")
+	}
+}
+
+func (s *Server) record(req Request) {
+	s.mu.Lock()
+	req.Seq = len(s.requests) + 1
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+	if s.OnRequest != nil {
+		s.OnRequest(req)
+	}
+}
+
+// reset closes the connection of w with a TCP reset, or just closes it when it
+// is not TCP.
+func reset(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// parseRecords splits a JSON array or NDJSON body into records.
+func parseRecords(body []byte) ([]json.RawMessage, bool) {
+	var records []json.RawMessage
+	if json.Unmarshal(body, &records) == nil {
+		return records, true
+	}
+	lines := bufio.NewScanner(bytes.NewReader(body))
+	lines.Buffer(nil, len(body)+1)
+	for lines.Scan() {
+		line := bytes.TrimSpace(lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, false
+		}
+		records = append(records, bytes.Clone(line))
+	}
+	return records, len(records) > 0
+}
+
+func (s *Server) serveRequests(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.Requests())
+}
+
+func (s *Server) serveReset(w http.ResponseWriter, _ *http.Request) {
+	s.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) serveScenario(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	scenario := s.scenario
+	s.mu.Unlock()
+	writeJSON(w, scenario)
+}
+
+func (s *Server) serveSetScenario(w http.ResponseWriter, r *http.Request) {
+	var scenario Scenario
+	if err := json.NewDecoder(r.Body).Decode(&scenario); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.SetScenario(scenario)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// LoadScenario reads a Scenario from the JSON file at path.
+func LoadScenario(path string) (Scenario, error) {
+	var scenario Scenario
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return scenario, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&scenario); err != nil {
+		return scenario, fmt.Errorf("%s: %w", path, err)
+	}
+	return scenario, nil
+}
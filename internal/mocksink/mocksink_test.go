@@ -0,0 +1,172 @@
+package mocksink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func post(t *testing.T, url, body string) (*http.Response, error) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestScenario(t *testing.T) {
+	s := New(Scenario{Steps: []Step{
+		{Status: http.StatusServiceUnavailable, RetryAfter: "2", Times: 2},
+		{Status: http.StatusBadRequest},
+		{Delay: Duration(50 * time.Millisecond)},
+	}})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	for i, want := range []int{503, 503, 400, 200, 200} {
+		start := time.Now()
+		resp, err := post(t, srv.URL, `[{"a":1},{"a":2}]`)
+		require.NoError(t, err)
+		assert.Equal(t, want, resp.StatusCode, i)
+		if want == 503 {
+			assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+		}
+		if want == 200 {
+			assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		}
+	}
+
+	assert.Equal(t, 5, s.Hits())
+	batches := s.Batches()
+	require.Len(t, batches, 2)
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"a":1}`), json.RawMessage(`{"a":2}`)}, batches[0])
+	requests := s.Requests()
+	assert.Equal(t, 1, requests[0].Seq)
+	assert.Equal(t, "application/json", requests[0].Header.Get("Content-Type"))
+	assert.False(t, requests[0].Accepted())
+}
+
+func TestNDJSONAndBinaryBodies(t *testing.T) {
+	s := New(Scenario{})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	_, err := post(t, srv.URL, "{\"a\":1}\n{\"a\":2}\n")
+	require.NoError(t, err)
+	_, err = post(t, srv.URL, "\x0a\x02b1")
+	require.NoError(t, err)
+
+	requests := s.Requests()
+	require.Len(t, requests, 2)
+	assert.Len(t, requests[0].Records, 2)
+	assert.Nil(t, requests[1].Records)
+	assert.Equal(t, []byte("\x0a\x02b1"), requests[1].Body)
+}
+
+func TestReset(t *testing.T) {
+	s := New(Scenario{Steps: []Step{{Reset: true}, {}}})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	_, err := post(t, srv.URL, `[{}]`)
+	assert.Error(t, err)
+	resp, err := post(t, srv.URL, `[{}]`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	requests := s.Requests()
+	require.Len(t, requests, 2)
+	assert.Zero(t, requests[0].Status)
+	assert.Len(t, s.Batches(), 1)
+}
+
+func TestPartialRead(t *testing.T) {
+	s := New(Scenario{Steps: []Step{{ReadBytes: 4, Reset: true}, {ReadBytes: 4}}})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	body := `[` + strings.Repeat(`{"a":1},`, 1<<16) + `{}]`
+	_, err := post(t, srv.URL, body)
+	assert.Error(t, err)
+	// A body no longer than ReadBytes is read in full.
+	resp, err := post(t, srv.URL, `[{}]`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	requests := s.Requests()
+	require.Len(t, requests, 2)
+	assert.True(t, requests[0].Partial)
+	assert.Equal(t, []byte(`[{"a`), requests[0].Body)
+	assert.False(t, requests[1].Partial)
+	assert.Len(t, s.Batches(), 1)
+}
+
+func TestAPI(t *testing.T) {
+	s := New(Scenario{})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	_, err := post(t, srv.URL+"/ingest", `[{"a":1}]`)
+	require.NoError(t, err)
+
+	resp, err := http.Get(srv.URL + APIPrefix + "/requests")
+	require.NoError(t, err)
+	var requests []Request
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&requests))
+	resp.Body.Close()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/ingest", requests[0].Path)
+	assert.Equal(t, http.StatusOK, requests[0].Status)
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL+APIPrefix+"/scenario",
+		bytes.NewReader([]byte(`{"steps":[{"status":500,"delay":"1ms"}]}`)))
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, err = http.Get(srv.URL + APIPrefix + "/scenario")
+	require.NoError(t, err)
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.JSONEq(t, `{"steps":[{"status":500,"delay":"1ms"}]}`, string(got))
+
+	resp, err = post(t, srv.URL, `[{}]`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodDelete, srv.URL+APIPrefix+"/requests", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Zero(t, s.Hits())
+}
+
+func TestLoadScenario(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"steps":[{"status":429,"retry_after":"1","times":3},{"delay":"250ms"}]}`), 0o600))
+	scenario, err := LoadScenario(path)
+	require.NoError(t, err)
+	assert.Equal(t, Scenario{Steps: []Step{
+		{Status: 429, RetryAfter: "1", Times: 3},
+		{Delay: Duration(250 * time.Millisecond)},
+	}}, scenario)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"steps":[{"stauts":500}]}`), 0o600))
+	_, err = LoadScenario(path)
+	assert.ErrorContains(t, err, `unknown field "stauts"`)
+}
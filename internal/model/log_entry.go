@@ -1,10 +1,34 @@
 // Package model contains data structures used across the webhook application.
 package model
 
+import "benzinga-webhook/internal/decimal"
+
 // Login represents a login event with a timestamp and originating IP address.
 type Login struct {
-	Time string `json:"time" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	// Time is forwarded in UTC. maxfuture and maxage reject times outside the
+	// window configured by LOGIN_MAX_FUTURE and LOGIN_MAX_AGE.
+	Time string `json:"time" validate:"required,datetime=2006-01-02T15:04:05Z07:00,maxfuture,maxage"`
 	IP   string `json:"ip" validate:"required,ip"`
+	// OriginalTime is Time as sent, set by the server when it was not already in UTC.
+	OriginalTime string `json:"original_time,omitempty"`
+	// Geo is set by the server from GEOIP_DATABASES when IP is found there.
+	Geo *Geo `json:"geo,omitempty"`
+}
+
+// Geo describes where an IP address is located and which network announces it.
+type Geo struct {
+	// Country is the ISO 3166-1 alpha-2 code, e.g. "GB".
+	Country  string    `json:"country,omitempty"`
+	City     string    `json:"city,omitempty"`
+	Location *Location `json:"location,omitempty"`
+	ASN      uint      `json:"asn,omitempty"`
+	ASOrg    string    `json:"as_org,omitempty"`
+}
+
+// Location is an approximate position in decimal degrees.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
 }
 
 // PhoneNumbers holds the home and mobile phone numbers of a user.
@@ -21,9 +45,28 @@ type Meta struct {
 
 // LogEntry represents the structure of incoming JSON payloads from the webhook.
 type LogEntry struct {
-	UserID    int     `json:"user_id" validate:"required,gte=1"`
-	Total     float64 `json:"total" validate:"required,gt=0"`
-	Title     string  `json:"title" validate:"required,min=3"`
-	Meta      Meta    `json:"meta" validate:"required"`
-	Completed bool    `json:"completed"`
+	UserID int `json:"user_id" validate:"required,gte=1"`
+	// Total is an exact amount that fits a DECIMAL(18,2) column. It is forwarded
+	// exactly as received, e.g. 99.90 is not rewritten as 99.9.
+	Total decimal.Decimal `json:"total" validate:"required,gt=0,maxscale=2,maxprecision=18"`
+	// Currency is the optional ISO 4217 code Total is denominated in.
+	Currency  string `json:"currency,omitempty" validate:"omitempty,iso4217"`
+	Title     string `json:"title" validate:"required,min=3"`
+	Meta      Meta   `json:"meta" validate:"required"`
+	Completed bool   `json:"completed"`
+	// ReceivedAt is set by the server to the UTC time the entry was accepted.
+	ReceivedAt string `json:"received_at,omitempty"`
+}
+
+// LoginAlert reports suspicious logins of a user, see internal/detect.
+type LoginAlert struct {
+	Kind   string `json:"kind"`
+	UserID int    `json:"user_id"`
+	// DetectedAt is the UTC server time the alert was raised.
+	DetectedAt string `json:"detected_at"`
+	// Logins are the logins that raised the alert, oldest first.
+	Logins      []Login `json:"logins"`
+	DistanceKM  float64 `json:"distance_km,omitempty"`
+	SpeedKMH    float64 `json:"speed_kmh,omitempty"`
+	DistinctIPs int     `json:"distinct_ips,omitempty"`
 }
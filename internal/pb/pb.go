@@ -0,0 +1,109 @@
+// Package pb holds the Protocol Buffers messages and gRPC service declared in
+// webhook.proto and ingest.proto, and their conversion to and from package
+// model.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative webhook.proto ingest.proto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/model"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the media type of protobuf request and response bodies.
+const ContentType = "application/x-protobuf"
+
+// UnmarshalLogEntry decodes a LogEntry message into entry.
+func UnmarshalLogEntry(body []byte, entry *model.LogEntry) error {
+	var m LogEntry
+	if err := proto.Unmarshal(body, &m); err != nil {
+		return err
+	}
+	e, err := m.Model()
+	if err != nil {
+		return err
+	}
+	*entry = e
+	return nil
+}
+
+// Model converts m to a model.LogEntry. An empty total is left zero for
+// validation to reject.
+func (m *LogEntry) Model() (model.LogEntry, error) {
+	e := model.LogEntry{
+		UserID:     int(m.GetUserId()),
+		Currency:   m.GetCurrency(),
+		Title:      m.GetTitle(),
+		Completed:  m.GetCompleted(),
+		ReceivedAt: m.GetReceivedAt(),
+		Meta: model.Meta{PhoneNumbers: model.PhoneNumbers{
+			Home:   m.GetMeta().GetPhoneNumbers().GetHome(),
+			Mobile: m.GetMeta().GetPhoneNumbers().GetMobile(),
+		}},
+	}
+	if m.GetTotal() != "" {
+		total, err := decimal.Parse(m.GetTotal())
+		if err != nil {
+			return model.LogEntry{}, fmt.Errorf("total: %w", err)
+		}
+		e.Total = total
+	}
+	for _, l := range m.GetMeta().GetLogins() {
+		login := model.Login{Time: l.GetTime(), IP: l.GetIp(), OriginalTime: l.GetOriginalTime()}
+		if g := l.GetGeo(); g != nil {
+			login.Geo = &model.Geo{Country: g.GetCountry(), City: g.GetCity(), ASN: uint(g.GetAsn()), ASOrg: g.GetAsOrg()}
+			if loc := g.GetLocation(); loc != nil {
+				login.Geo.Location = &model.Location{Latitude: loc.GetLatitude(), Longitude: loc.GetLongitude()}
+			}
+		}
+		e.Meta.Logins = append(e.Meta.Logins, login)
+	}
+	return e, nil
+}
+
+// FromModel converts e to a LogEntry message.
+func FromModel(e model.LogEntry) *LogEntry {
+	m := &LogEntry{
+		UserId:     int64(e.UserID),
+		Total:      e.Total.String(),
+		Currency:   e.Currency,
+		Title:      e.Title,
+		Completed:  e.Completed,
+		ReceivedAt: e.ReceivedAt,
+		Meta: &Meta{PhoneNumbers: &PhoneNumbers{
+			Home:   e.Meta.PhoneNumbers.Home,
+			Mobile: e.Meta.PhoneNumbers.Mobile,
+		}},
+	}
+	for _, l := range e.Meta.Logins {
+		login := &Login{Time: l.Time, Ip: l.IP, OriginalTime: l.OriginalTime}
+		if g := l.Geo; g != nil {
+			login.Geo = &Geo{Country: g.Country, City: g.City, Asn: uint32(g.ASN), AsOrg: g.ASOrg}
+			if g.Location != nil {
+				login.Geo.Location = &Location{Latitude: g.Location.Latitude, Longitude: g.Location.Longitude}
+			}
+		}
+		m.Meta.Logins = append(m.Meta.Logins, login)
+	}
+	return m
+}
+
+// LogEntryFromJSON converts the JSON encoding of a model.LogEntry, as found in
+// sink batches, to a LogEntry message. Records that do not have exactly that
+// shape, e.g. because a transform rewrote them, are rejected rather than
+// silently losing fields.
+func LogEntryFromJSON(record json.RawMessage) (*LogEntry, error) {
+	var e model.LogEntry
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&e); err != nil {
+		return nil, err
+	}
+	return FromModel(e), nil
+}
@@ -0,0 +1,65 @@
+package pb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRoundTrip(t *testing.T) {
+	entry := model.LogEntry{
+		UserID:   42,
+		Total:    decimal.MustParse("99.90"),
+		Currency: "EUR",
+		Title:    "round trip",
+		Meta: model.Meta{
+			Logins: []model.Login{
+				{Time: "2024-06-01T12:00:00Z", IP: "81.2.69.1", OriginalTime: "2024-06-01T14:00:00+02:00", Geo: &model.Geo{
+					Country: "GB", City: "London", Location: &model.Location{Latitude: 51.5142, Longitude: -0.0931},
+					ASN: 20712, ASOrg: "Andrews & Arnold Ltd",
+				}},
+				{Time: "2024-06-01T13:00:00Z", IP: "127.0.0.1"},
+			},
+			PhoneNumbers: model.PhoneNumbers{Home: "555-1212-123", Mobile: "555-1212-456"},
+		},
+		Completed:  true,
+		ReceivedAt: "2024-06-01T13:00:01Z",
+	}
+
+	raw, err := proto.Marshal(FromModel(entry))
+	require.NoError(t, err)
+	var back model.LogEntry
+	require.NoError(t, UnmarshalLogEntry(raw, &back))
+	assert.Equal(t, entry, back)
+
+	record, err := json.Marshal(entry)
+	require.NoError(t, err)
+	m, err := LogEntryFromJSON(record)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(FromModel(entry), m))
+}
+
+func TestUnmarshalLogEntry(t *testing.T) {
+	var entry model.LogEntry
+	raw, err := proto.Marshal(&LogEntry{UserId: 1, Title: "no total"})
+	require.NoError(t, err)
+	require.NoError(t, UnmarshalLogEntry(raw, &entry))
+	assert.True(t, entry.Total.IsZero())
+
+	raw, err = proto.Marshal(&LogEntry{Total: "ten"})
+	require.NoError(t, err)
+	assert.EqualError(t, UnmarshalLogEntry(raw, &entry), `total: invalid decimal "ten"`)
+
+	assert.Error(t, UnmarshalLogEntry([]byte{0xff}, &entry))
+}
+
+func TestLogEntryFromJSONRejectsOtherShapes(t *testing.T) {
+	_, err := LogEntryFromJSON(json.RawMessage(`{"userId":1}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+// Package phone validates phone numbers against configured formats and
+// normalizes accepted numbers into E.164.
+package phone
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"benzinga-webhook/internal/config"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Format is a named, precompiled phone number pattern.
+type Format struct {
+	Name string
+	// CallingCode is the country calling code prepended during normalization,
+	// e.g. "1" for NANP numbers. Formats without one are never normalized.
+	CallingCode string
+	// TrunkPrefix is the national dialling prefix dropped during normalization, e.g. "0".
+	TrunkPrefix string
+	pattern     *regexp.Regexp
+}
+
+// builtins are the formats that can be enabled by name via PHONE_FORMATS.
+var builtins = map[string]config.PhoneFormat{
+	"e164":   {Name: "e164", Pattern: `^\+[1-9]\d{1,14}$`},
+	"legacy": {Name: "legacy", Pattern: `^\d{3}-\d{4}-\d{3}$`},
+	"us":     {Name: "us", CallingCode: "1", Pattern: `^(\+1[ .-]?)?\(?[2-9]\d{2}\)?[ .-]?\d{3}[ .-]?\d{4}$`},
+	"gb":     {Name: "gb", CallingCode: "44", TrunkPrefix: "0", Pattern: `^(\+44 ?|0)(\d{4} ?\d{6}|\d{2} ?\d{4} ?\d{4})$`},
+	"in":     {Name: "in", CallingCode: "91", TrunkPrefix: "0", Pattern: `^(\+91[ -]?|0)?[6-9]\d{4}[ -]?\d{5}$`},
+}
+
+var e164 = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+// Set validates numbers against any of its formats.
+type Set struct {
+	formats   []Format
+	normalize bool
+}
+
+// New compiles the formats enabled in cfg. Built-in names are resolved first,
+// then custom formats are appended in configuration order.
+func New(cfg *config.Config) (*Set, error) {
+	specs := make([]config.PhoneFormat, 0, len(cfg.PhoneFormats)+len(cfg.PhoneCustomFormats))
+	for _, name := range cfg.PhoneFormats {
+		spec, ok := builtins[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown phone format %q", name)
+		}
+		specs = append(specs, spec)
+	}
+	specs = append(specs, cfg.PhoneCustomFormats...)
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no phone formats configured")
+	}
+
+	s := &Set{normalize: cfg.PhoneNormalize}
+	for _, spec := range specs {
+		pattern, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("phone format %q: %w", spec.Name, err)
+		}
+		s.formats = append(s.formats, Format{
+			Name:        spec.Name,
+			CallingCode: spec.CallingCode,
+			TrunkPrefix: spec.TrunkPrefix,
+			pattern:     pattern,
+		})
+	}
+	return s, nil
+}
+
+// Match returns the first format that accepts number.
+func (s *Set) Match(number string) (Format, bool) {
+	for _, f := range s.formats {
+		if f.pattern.MatchString(number) {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// Validate is a validator.Func for the "phoneformat" tag.
+func (s *Set) Validate(fl validator.FieldLevel) bool {
+	_, ok := s.Match(fl.Field().String())
+	return ok
+}
+
+// Normalize rewrites number into E.164 when normalization is enabled and the
+// matching format carries a calling code. Other numbers are returned unchanged.
+func (s *Set) Normalize(number string) string {
+	if !s.normalize {
+		return number
+	}
+	f, ok := s.Match(number)
+	if !ok || (f.CallingCode == "" && !e164.MatchString(number)) {
+		return number
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+	if strings.HasPrefix(number, "+") {
+		return "+" + digits
+	}
+	if f.TrunkPrefix != "" {
+		digits = strings.TrimPrefix(digits, f.TrunkPrefix)
+	}
+	return "+" + f.CallingCode + digits
+}
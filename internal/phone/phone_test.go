@@ -0,0 +1,90 @@
+package phone
+
+import (
+	"testing"
+
+	"benzinga-webhook/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_UnknownFormat(t *testing.T) {
+	_, err := New(&config.Config{PhoneFormats: []string{"mars"}})
+	assert.EqualError(t, err, `unknown phone format "mars"`)
+}
+
+func TestNew_InvalidCustomPattern(t *testing.T) {
+	_, err := New(&config.Config{PhoneCustomFormats: []config.PhoneFormat{{Name: "bad", Pattern: "("}}})
+	assert.ErrorContains(t, err, `phone format "bad"`)
+}
+
+func TestNew_NoFormats(t *testing.T) {
+	_, err := New(&config.Config{})
+	assert.Error(t, err)
+}
+
+func TestMatch(t *testing.T) {
+	s, err := New(&config.Config{
+		PhoneFormats: []string{"e164", "legacy", "us", "gb", "in"},
+		PhoneCustomFormats: []config.PhoneFormat{
+			{Name: "fr", CallingCode: "33", TrunkPrefix: "0", Pattern: `^0[1-9](\d{2}){4}$`},
+		},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		number string
+		format string
+		ok     bool
+	}{
+		{"+14155552671", "e164", true},
+		{"123-4567-891", "legacy", true},
+		{"(415) 555-2671", "us", true},
+		{"+1 415-555-2671", "us", true},
+		{"020 7946 0958", "gb", true},
+		{"98765 43210", "in", true},
+		{"0612345678", "fr", true},
+		{"555-1212", "", false},
+		{"+0123", "", false},
+		{"", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.number, func(t *testing.T) {
+			f, ok := s.Match(tc.number)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.format, f.Name)
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cfg := &config.Config{
+		PhoneFormats: []string{"e164", "legacy", "us", "gb", "in"},
+		PhoneCustomFormats: []config.PhoneFormat{
+			{Name: "fr", CallingCode: "33", TrunkPrefix: "0", Pattern: `^0[1-9](\d{2}){4}$`},
+		},
+		PhoneNormalize: true,
+	}
+	s, err := New(cfg)
+	assert.NoError(t, err)
+
+	tests := map[string]string{
+		"+14155552671":     "+14155552671",
+		"(415) 555-2671":   "+14155552671",
+		"+1 415-555-2671":  "+14155552671",
+		"020 7946 0958":    "+442079460958",
+		"+44 20 7946 0958": "+442079460958",
+		"98765 43210":      "+919876543210",
+		"0612345678":       "+33612345678",
+		"123-4567-891":     "123-4567-891",
+		"not a number":     "not a number",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, s.Normalize(in), in)
+	}
+
+	cfg.PhoneNormalize = false
+	s, err = New(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "(415) 555-2671", s.Normalize("(415) 555-2671"))
+}
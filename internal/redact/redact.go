@@ -0,0 +1,142 @@
+// Package redact masks, hashes, truncates or drops personal data in records
+// before they are delivered to a sink.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/jsonptr"
+)
+
+type rule struct {
+	path   []string
+	action string
+	keep   int
+}
+
+// Redactor applies a fixed list of rules to JSON records.
+type Redactor struct {
+	rules []rule
+	key   []byte
+}
+
+// New compiles rules. key is required when any rule uses config.RedactHash.
+func New(rules []config.RedactRule, key []byte) (*Redactor, error) {
+	r := &Redactor{key: key}
+	for _, rc := range rules {
+		if !strings.HasPrefix(rc.Field, "/") {
+			return nil, fmt.Errorf("redact %q: field must be a JSON pointer", rc.Field)
+		}
+		switch rc.Action {
+		case config.RedactMask, config.RedactTruncateIP, config.RedactDrop:
+		case config.RedactHash:
+			if len(key) == 0 {
+				return nil, fmt.Errorf("redact %q: hash requires a key", rc.Field)
+			}
+		default:
+			return nil, fmt.Errorf("redact %q: unknown action %q", rc.Field, rc.Action)
+		}
+		r.rules = append(r.rules, rule{path: jsonptr.Split(rc.Field), action: rc.Action, keep: rc.Keep})
+	}
+	return r, nil
+}
+
+// Empty reports whether r has no rules.
+func (r *Redactor) Empty() bool {
+	return r == nil || len(r.rules) == 0
+}
+
+// Apply returns record with every rule applied. Fields that do not exist are
+// left alone. It implements sink.Stage.
+func (r *Redactor) Apply(record json.RawMessage) (json.RawMessage, error) {
+	if r.Empty() {
+		return record, nil
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("redact: decode record: %w", err)
+	}
+	r.Doc(doc)
+	return json.Marshal(doc)
+}
+
+// Doc applies every rule to a document decoded with json.Decoder.UseNumber,
+// in place.
+func (r *Redactor) Doc(doc any) {
+	if r.Empty() {
+		return
+	}
+	for _, ru := range r.rules {
+		jsonptr.Rewrite(doc, ru.path, func(v any) (any, bool) {
+			if ru.action == config.RedactDrop {
+				return nil, false
+			}
+			return r.rewrite(v, ru), true
+		})
+	}
+}
+
+// rewrite applies a value action. Objects, arrays and nulls are left alone.
+func (r *Redactor) rewrite(value any, ru rule) any {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case bool:
+		s = fmt.Sprint(v)
+	default:
+		return value
+	}
+
+	switch ru.action {
+	case config.RedactMask:
+		return Mask(s, ru.keep)
+	case config.RedactHash:
+		return r.Hash(s)
+	case config.RedactTruncateIP:
+		return TruncateIP(s)
+	}
+	return value
+}
+
+// Mask replaces every character but the last keep with '*'.
+func Mask(s string, keep int) string {
+	runes := []rune(s)
+	if keep > len(runes) {
+		keep = len(runes)
+	}
+	return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+}
+
+// Hash returns the hex HMAC-SHA256 of s, so equal values stay joinable
+// downstream without being readable.
+func (r *Redactor) Hash(s string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TruncateIP zeroes the host part of an address: IPv4 addresses keep their /24
+// and IPv6 addresses their /48. Values that are not IP addresses are masked.
+func TruncateIP(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return Mask(s, 0)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,75 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+
+	"benzinga-webhook/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const entry = `{"user_id":1,"total":99.990,"meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"203.0.113.77"},{"time":"2020-08-08T02:52:50Z","ip":"2001:db8:abcd:12::1"}],"phone_numbers":{"home":"555-1212-123","mobile":"+14155550100"}}}`
+
+func TestApply(t *testing.T) {
+	r, err := New([]config.RedactRule{
+		{Field: "/meta/logins/*/ip", Action: config.RedactTruncateIP},
+		{Field: "/meta/phone_numbers/home", Action: config.RedactMask, Keep: 3},
+		{Field: "/meta/phone_numbers/mobile", Action: config.RedactHash},
+		{Field: "/user_id", Action: config.RedactDrop},
+		{Field: "/meta/missing/field", Action: config.RedactDrop},
+	}, []byte("secret"))
+	assert.NoError(t, err)
+
+	out, err := r.Apply(json.RawMessage(entry))
+	assert.NoError(t, err)
+
+	var doc struct {
+		UserID *int        `json:"user_id"`
+		Total  json.Number `json:"total"`
+		Meta   struct {
+			Logins []struct {
+				IP string `json:"ip"`
+			} `json:"logins"`
+			PhoneNumbers map[string]string `json:"phone_numbers"`
+		} `json:"meta"`
+	}
+	assert.NoError(t, json.Unmarshal(out, &doc))
+	assert.Nil(t, doc.UserID)
+	assert.Equal(t, json.Number("99.990"), doc.Total)
+	assert.Equal(t, "203.0.113.0", doc.Meta.Logins[0].IP)
+	assert.Equal(t, "2001:db8:abcd::", doc.Meta.Logins[1].IP)
+	assert.Equal(t, "*********123", doc.Meta.PhoneNumbers["home"])
+	assert.Equal(t, r.Hash("+14155550100"), doc.Meta.PhoneNumbers["mobile"])
+	assert.Len(t, doc.Meta.PhoneNumbers["mobile"], 64)
+	assert.NotContains(t, string(out), "4155550100")
+}
+
+func TestHashIsKeyed(t *testing.T) {
+	rules := []config.RedactRule{{Field: "/ip", Action: config.RedactHash}}
+	a, _ := New(rules, []byte("a"))
+	b, _ := New(rules, []byte("b"))
+	assert.Equal(t, a.Hash("127.0.0.1"), a.Hash("127.0.0.1"))
+	assert.NotEqual(t, a.Hash("127.0.0.1"), b.Hash("127.0.0.1"))
+
+	_, err := New(rules, nil)
+	assert.EqualError(t, err, `redact "/ip": hash requires a key`)
+	_, err = New([]config.RedactRule{{Field: "/ip", Action: "encrypt"}}, nil)
+	assert.EqualError(t, err, `redact "/ip": unknown action "encrypt"`)
+}
+
+func TestHelpers(t *testing.T) {
+	assert.Equal(t, "****", Mask("abcd", 0))
+	assert.Equal(t, "ab", Mask("ab", 5))
+	assert.Equal(t, "10.1.2.0", TruncateIP("10.1.2.3"))
+	assert.Equal(t, "10.1.2.0", TruncateIP("::ffff:10.1.2.3"))
+	assert.Equal(t, "*****", TruncateIP("bogus"))
+}
+
+func TestDropArrayElement(t *testing.T) {
+	r, err := New([]config.RedactRule{{Field: "/meta/logins/0", Action: config.RedactDrop}}, nil)
+	assert.NoError(t, err)
+	out, err := r.Apply(json.RawMessage(`{"meta":{"logins":[{"ip":"1.1.1.1"},{"ip":"2.2.2.2"}]}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"meta":{"logins":[null,{"ip":"2.2.2.2"}]}}`, string(out))
+}
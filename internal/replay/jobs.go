@@ -0,0 +1,171 @@
+package replay
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/sink"
+
+	"go.uber.org/zap"
+)
+
+// Job states.
+const (
+	StateRunning  = "running"
+	StateDone     = "done"
+	StateFailed   = "failed"
+	StateCanceled = "canceled"
+)
+
+// keepFinished caps the finished replays whose status a Manager keeps. The
+// oldest are forgotten first.
+var keepFinished = 100
+
+// Status reports on a replay started by a Manager.
+type Status struct {
+	ID      string   `json:"id"`
+	State   string   `json:"state"`
+	Archive string   `json:"archive,omitempty"`
+	Sinks   []string `json:"sinks"`
+	Progress
+	// Error says why a failed replay stopped.
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type job struct {
+	status Status
+	cancel context.CancelFunc
+}
+
+// Manager runs replays in the background for the admin API. It is safe for
+// concurrent use.
+type Manager struct {
+	cfg   *config.Config
+	sinks []sink.Sink
+	log   *zap.Logger
+
+	mu       sync.Mutex // guards the fields below
+	jobs     map[string]*job
+	finished []string // IDs of finished jobs, oldest first
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewManager creates a Manager replaying the archives of the file sinks in
+// cfg to sinks.
+func NewManager(cfg *config.Config, sinks []sink.Sink, log *zap.Logger) *Manager {
+	return &Manager{cfg: cfg, sinks: sinks, log: log, jobs: make(map[string]*job)}
+}
+
+// Start starts replaying the archive of the named file sink to the named
+// sinks, as Run does, and returns its status. opts.ID is set to a new replay
+// ID. The error reports an archive or sink that cannot be replayed.
+func (m *Manager) Start(archive string, sinkNames []string, opts Options) (Status, error) {
+	dir, err := Source(m.cfg, archive)
+	if err != nil {
+		return Status{}, err
+	}
+	targets, err := Targets(m.sinks, m.cfg, dir, sinkNames)
+	if err != nil {
+		return Status{}, err
+	}
+	opts.ID = sink.NewBatchID()
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		status: Status{
+			ID:        opts.ID,
+			State:     StateRunning,
+			Archive:   archive,
+			Sinks:     sinkNames,
+			StartedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		cancel()
+		return Status{}, context.Canceled
+	}
+	m.jobs[opts.ID] = j
+	m.wg.Add(1)
+	go m.run(ctx, j, dir, targets, opts)
+	return j.status, nil
+}
+
+func (m *Manager) run(ctx context.Context, j *job, dir string, targets []sink.Sink, opts Options) {
+	defer m.wg.Done()
+	defer j.cancel()
+	log := m.log.With(zap.String("replay", opts.ID))
+	log.Info("replay started", zap.String("dir", dir), zap.Strings("sinks", j.status.Sinks))
+	p, err := Run(ctx, dir, targets, opts, func(p Progress) {
+		m.mu.Lock()
+		j.status.Progress = p
+		m.mu.Unlock()
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	finished := time.Now().UTC()
+	j.status.Progress, j.status.FinishedAt = p, &finished
+	switch {
+	case ctx.Err() != nil:
+		j.status.State = StateCanceled
+	case err != nil:
+		j.status.State, j.status.Error = StateFailed, err.Error()
+	default:
+		j.status.State = StateDone
+	}
+	m.finished = append(m.finished, opts.ID)
+	for len(m.finished) > keepFinished {
+		delete(m.jobs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+	log.Info("replay finished",
+		zap.String("state", j.status.State),
+		zap.Int("batches", p.Batches),
+		zap.Int("records", p.Records),
+		zap.Int("failed", p.Failed),
+		zap.Error(err))
+}
+
+// Status returns the status of the replay with the given ID. Only the last
+// finished replays are kept.
+func (m *Manager) Status(id string) (Status, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Status{}, false
+	}
+	return j.status, true
+}
+
+// Cancel stops the replay with the given ID, abandoning the batch being sent.
+// It reports whether the replay exists.
+func (m *Manager) Cancel(id string) bool {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if ok {
+		j.cancel()
+	}
+	return ok
+}
+
+// Close cancels the replays still running and waits for them to stop. Start
+// fails afterwards.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	for _, j := range m.jobs {
+		j.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
@@ -0,0 +1,321 @@
+// Package replay re-delivers batches kept by a file sink's archive, for
+// example to a sink that was down or misconfigured when they were accepted.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/sink"
+)
+
+// maxLine bounds the archive lines read.
+const maxLine = 4 << 20
+
+// Filter selects the archived records to replay. Zero fields match anything.
+type Filter struct {
+	// From and To bound when batches were created: From inclusive, To
+	// exclusive.
+	From, To time.Time
+	// Type is the event type of the batches.
+	Type string
+	// BatchIDs lists the batches to replay.
+	BatchIDs []string
+	// UserID is the user_id of the records, as a decimal string.
+	UserID string
+}
+
+func (f Filter) matchBatch(typ, id string, created time.Time) bool {
+	if !f.From.IsZero() && created.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !created.Before(f.To) {
+		return false
+	}
+	if f.Type != "" && typ != f.Type {
+		return false
+	}
+	if len(f.BatchIDs) == 0 {
+		return true
+	}
+	for _, want := range f.BatchIDs {
+		if id == want {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) matchRecord(record json.RawMessage) bool {
+	if f.UserID == "" {
+		return true
+	}
+	var doc struct {
+		UserID json.Number `json:"user_id"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	return dec.Decode(&doc) == nil && doc.UserID.String() == f.UserID
+}
+
+// Read calls fn with the archived batches in dir that f matches, in the order
+// they were archived, keeping only the records f matches. Each batch keeps its
+// ID, type and creation time. Lines that cannot be decoded, such as one being
+// written, are skipped and counted. Read stops at the first error fn returns.
+func Read(dir string, f Filter, fn func(sink.Batch) error) (skipped int, err error) {
+	segments, err := selectSegments(dir, f)
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range segments {
+		n, err := readSegment(dir, name, f, fn)
+		skipped += n
+		if err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
+// selectSegments returns the names, as written, of the segments holding a
+// batch f matches according to the archive index.
+func selectSegments(dir string, f Filter) ([]string, error) {
+	index, err := os.Open(filepath.Join(dir, sink.IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+	var names []string
+	seen := make(map[string]bool)
+	lines := bufio.NewScanner(index)
+	lines.Buffer(nil, 1<<20)
+	for lines.Scan() {
+		var e sink.IndexEntry
+		if err := json.Unmarshal(lines.Bytes(), &e); err != nil {
+			continue
+		}
+		if !seen[e.Segment] && f.matchBatch(e.Type, e.BatchID, e.CreatedAt) {
+			seen[e.Segment] = true
+			names = append(names, e.Segment)
+		}
+	}
+	return names, lines.Err()
+}
+
+// readSegment calls fn with the matching batches of the named segment, which
+// may have been compressed since it was indexed. Segments deleted by the
+// retention limits are skipped.
+func readSegment(dir, name string, f Filter, fn func(sink.Batch) error) (int, error) {
+	path := filepath.Join(dir, name)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		path = strings.TrimSuffix(path, sink.SegmentSuffix) + sink.CompressedSuffix
+		file, err = os.Open(path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, sink.CompressedSuffix) {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", name, err)
+		}
+		r = zr
+	}
+
+	// The lines of a batch are written together, so a batch ends where the
+	// next begins.
+	var skipped int
+	var batch *sink.Batch
+	emit := func() error {
+		if batch == nil || len(batch.Records) == 0 {
+			return nil
+		}
+		return fn(*batch)
+	}
+	lines := bufio.NewScanner(r)
+	lines.Buffer(nil, maxLine)
+	for lines.Scan() {
+		var line sink.ArchiveLine
+		if err := json.Unmarshal(lines.Bytes(), &line); err != nil {
+			skipped++
+			continue
+		}
+		if batch == nil || line.BatchID != batch.ID {
+			if err := emit(); err != nil {
+				return skipped, err
+			}
+			batch = &sink.Batch{ID: line.BatchID, Type: line.Type, CreatedAt: line.CreatedAt}
+		}
+		if f.matchBatch(line.Type, line.BatchID, line.CreatedAt) && f.matchRecord(line.Record) {
+			batch.Records = append(batch.Records, line.Record)
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return skipped, fmt.Errorf("%s: %w", name, err)
+	}
+	return skipped, emit()
+}
+
+// Options configures a replay.
+type Options struct {
+	Filter
+	// ID is set as the Replay of every batch delivered.
+	ID string
+	// Rate caps the records sent per second. Zero means no limit.
+	Rate float64
+}
+
+// Progress counts what a replay has done so far.
+type Progress struct {
+	// Batches and Records count what was delivered to every target sink.
+	Batches int `json:"batches"`
+	Records int `json:"records"`
+	// Failed counts the batches at least one sink did not accept after its
+	// retries, and LastError says why the last of them failed.
+	Failed    int    `json:"failed"`
+	LastError string `json:"last_error,omitempty"`
+	// Skipped counts archive lines that could not be decoded.
+	Skipped int `json:"skipped"`
+}
+
+// Run replays the batches in the archive dir that opts selects to targets,
+// sending each through the sink's filter, stages and retries as on first
+// delivery, with Replay set to opts.ID. A batch that fails is counted and the
+// replay moves on. progress, if not nil, is called after every batch. Run
+// returns early with ctx's error when ctx is done.
+func Run(ctx context.Context, dir string, targets []sink.Sink, opts Options, progress func(Progress)) (Progress, error) {
+	var p Progress
+	var attempted int // records, including those of failed batches
+	start := time.Now()
+	skipped, err := Read(dir, opts.Filter, func(b sink.Batch) error {
+		if opts.Rate > 0 {
+			due := start.Add(time.Duration(float64(attempted) / opts.Rate * float64(time.Second)))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		attempted += len(b.Records)
+		b.Replay = opts.ID
+		var failed error
+		for _, s := range targets {
+			kept := keep(s, b)
+			if len(kept.Records) == 0 {
+				continue
+			}
+			if err := s.Send(ctx, kept); err != nil {
+				failed = fmt.Errorf("batch %s: sink %q: %w", b.ID, s.Name(), err)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if failed != nil {
+			p.Failed++
+			p.LastError = failed.Error()
+		} else {
+			p.Batches++
+			p.Records += len(b.Records)
+		}
+		if progress != nil {
+			progress(p)
+		}
+		return nil
+	})
+	p.Skipped = skipped
+	return p, err
+}
+
+// keep returns b with only the records the filter of s keeps, as a batcher
+// would have.
+func keep(s sink.Sink, b sink.Batch) sink.Batch {
+	f, ok := sink.FilterOf(s)
+	if !ok {
+		return b
+	}
+	records := make([]json.RawMessage, 0, len(b.Records))
+	for _, record := range b.Records {
+		var doc any
+		dec := json.NewDecoder(bytes.NewReader(record))
+		dec.UseNumber()
+		if dec.Decode(&doc) == nil && f.Keep(doc) {
+			records = append(records, record)
+		}
+	}
+	b.Records = records
+	return b
+}
+
+// Source returns the archive directory of the file sink named name, or of the
+// only file sink configured when name is empty.
+func Source(cfg *config.Config, name string) (string, error) {
+	var dirs []string
+	for _, sc := range cfg.Sinks {
+		if sc.Type != "file" {
+			continue
+		}
+		if sc.Name == name {
+			return sc.Archive.Dir, nil
+		}
+		dirs = append(dirs, sc.Archive.Dir)
+	}
+	switch {
+	case name != "":
+		return "", fmt.Errorf("archive %q is not a file sink", name)
+	case len(dirs) == 1:
+		return dirs[0], nil
+	case len(dirs) == 0:
+		return "", errors.New("no file sink is configured")
+	default:
+		return "", errors.New("archive is required: more than one file sink is configured")
+	}
+}
+
+// Targets returns the named sinks, which must not include the file sink
+// archiving to dir, the archive being replayed. That sink must not have
+// stages either: its records were archived after them and would go through
+// them twice, once more in the targets.
+func Targets(sinks []sink.Sink, cfg *config.Config, dir string, names []string) ([]sink.Sink, error) {
+	if len(names) == 0 {
+		return nil, errors.New("at least one sink is required")
+	}
+	for _, sc := range cfg.Sinks {
+		if sc.Type == "file" && sc.Archive.Dir == dir && (len(sc.Transforms) > 0 || len(sc.Redact) > 0 || len(sc.Encrypt) > 0) {
+			return nil, fmt.Errorf("archive %q has transforms, redact or encrypt and cannot be replayed", sc.Name)
+		}
+	}
+	targets := make([]sink.Sink, 0, len(names))
+	for _, name := range names {
+		for _, sc := range cfg.Sinks {
+			if sc.Name == name && sc.Type == "file" && sc.Archive.Dir == dir {
+				return nil, fmt.Errorf("sink %q is the archive being replayed", name)
+			}
+		}
+		named := sink.Named(sinks, name)
+		if len(named) == 0 {
+			return nil, fmt.Errorf("unknown sink %q", name)
+		}
+		targets = append(targets, named[0])
+	}
+	return targets, nil
+}
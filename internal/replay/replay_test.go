@@ -0,0 +1,242 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/filter"
+	"benzinga-webhook/internal/sink"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var day = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// archive writes b1 to b4, an hour apart, to a new archive rotated after every
+// batch, and returns its directory.
+func archive(t *testing.T) string {
+	dir := t.TempDir()
+	a, err := sink.NewArchive("archive", config.ArchiveConfig{Dir: dir, SegmentBytes: 1, SegmentAge: time.Hour}, zap.NewNop())
+	require.NoError(t, err)
+	batches := []sink.Batch{
+		{ID: "b1", Type: "log_entry", Records: []json.RawMessage{json.RawMessage(`{"user_id":1}`), json.RawMessage(`{"user_id":2}`)}},
+		{ID: "b2", Type: "log_entry", Records: []json.RawMessage{json.RawMessage(`{"user_id":2}`)}},
+		{ID: "b3", Type: "order", Records: []json.RawMessage{json.RawMessage(`{"id":"o-1"}`)}},
+		{ID: "b4", Type: "log_entry", Records: []json.RawMessage{json.RawMessage(`{"user_id":1}`)}},
+	}
+	for i, b := range batches {
+		b.CreatedAt = day.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, a.Send(context.Background(), b))
+	}
+	return dir
+}
+
+func read(t *testing.T, dir string, f Filter) []sink.Batch {
+	var got []sink.Batch
+	_, err := Read(dir, f, func(b sink.Batch) error {
+		got = append(got, b)
+		return nil
+	})
+	require.NoError(t, err)
+	return got
+}
+
+func ids(batches []sink.Batch) []string {
+	var ids []string
+	for _, b := range batches {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
+
+func TestRead(t *testing.T) {
+	dir := archive(t)
+
+	all := read(t, dir, Filter{})
+	assert.Equal(t, []string{"b1", "b2", "b3", "b4"}, ids(all))
+	assert.Equal(t, day.Add(time.Hour), all[1].CreatedAt)
+	assert.Len(t, all[0].Records, 2)
+
+	assert.Equal(t, []string{"b2", "b3"}, ids(read(t, dir, Filter{From: day.Add(time.Hour), To: day.Add(3 * time.Hour)})))
+	assert.Equal(t, []string{"b1", "b2", "b4"}, ids(read(t, dir, Filter{Type: "log_entry"})))
+	assert.Equal(t, []string{"b2"}, ids(read(t, dir, Filter{BatchIDs: []string{"b2"}})))
+
+	user := read(t, dir, Filter{UserID: "2"})
+	assert.Equal(t, []string{"b1", "b2"}, ids(user))
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"user_id":2}`)}, user[0].Records)
+}
+
+// recorder is a sink that keeps what it is sent and fails batches listed in
+// fail.
+type recorder struct {
+	name string
+	fail map[string]bool
+
+	mu      sync.Mutex
+	batches []sink.Batch
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Send(_ context.Context, b sink.Batch) error {
+	if r.fail[b.ID] {
+		return errors.New("unavailable")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, b)
+	return nil
+}
+
+func (r *recorder) sent() []sink.Batch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]sink.Batch(nil), r.batches...)
+}
+
+func TestRun(t *testing.T) {
+	dir := archive(t)
+	ok := &recorder{name: "ok"}
+	failing := &recorder{name: "failing", fail: map[string]bool{"b2": true}}
+	f, err := filter.New("users", []config.FilterRule{
+		{Name: "others", When: `.user_id != 1`, Action: config.FilterDrop},
+	})
+	require.NoError(t, err)
+	users := &recorder{name: "users"}
+
+	var reports []Progress
+	start := time.Now()
+	p, err := Run(context.Background(), dir, []sink.Sink{ok, failing, sink.WithFilter(users, f)},
+		Options{Filter: Filter{Type: "log_entry"}, ID: "r1", Rate: 40},
+		func(p Progress) { reports = append(reports, p) })
+	require.NoError(t, err)
+
+	// Four records at 40 a second: b4 waits for the three before it.
+	assert.GreaterOrEqual(t, time.Since(start), 75*time.Millisecond)
+	assert.Equal(t, 2, p.Batches)
+	assert.Equal(t, 3, p.Records)
+	assert.Equal(t, 1, p.Failed)
+	assert.Equal(t, `batch b2: sink "failing": unavailable`, p.LastError)
+	assert.Len(t, reports, 3)
+	assert.Equal(t, p, reports[2])
+
+	assert.Equal(t, []string{"b1", "b2", "b4"}, ids(ok.sent()))
+	for _, b := range ok.sent() {
+		assert.Equal(t, "r1", b.Replay)
+	}
+	sent := users.sent()
+	assert.Equal(t, []string{"b1", "b4"}, ids(sent))
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"user_id":1}`)}, sent[0].Records)
+}
+
+func TestRunCanceled(t *testing.T) {
+	dir := archive(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p, err := Run(ctx, dir, []sink.Sink{&recorder{name: "ok"}}, Options{Rate: 1}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, p.Batches)
+}
+
+func managerConfig(dir string) *config.Config {
+	return &config.Config{Sinks: []config.SinkConfig{
+		{Name: "archive", Type: "file", Archive: config.ArchiveConfig{Dir: dir}},
+		{Name: "warehouse", Type: "http"},
+	}}
+}
+
+func TestManager(t *testing.T) {
+	dir := archive(t)
+	warehouse := &recorder{name: "warehouse"}
+	m := NewManager(managerConfig(dir), []sink.Sink{warehouse}, zap.NewNop())
+	defer m.Close()
+
+	_, err := m.Start("", []string{"archive"}, Options{})
+	assert.EqualError(t, err, `sink "archive" is the archive being replayed`)
+	_, err = m.Start("", []string{"lake"}, Options{})
+	assert.EqualError(t, err, `unknown sink "lake"`)
+	_, err = m.Start("warehouse", []string{"warehouse"}, Options{})
+	assert.EqualError(t, err, `archive "warehouse" is not a file sink`)
+
+	st, err := m.Start("", []string{"warehouse"}, Options{Filter: Filter{UserID: "1"}})
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, st.State)
+	require.Eventually(t, func() bool {
+		st, _ = m.Status(st.ID)
+		return st.State != StateRunning
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, StateDone, st.State)
+	assert.Equal(t, 2, st.Records)
+	assert.NotNil(t, st.FinishedAt)
+	assert.Equal(t, st.ID, warehouse.sent()[0].Replay)
+
+	_, ok := m.Status("nope")
+	assert.False(t, ok)
+	assert.False(t, m.Cancel("nope"))
+}
+
+func TestManagerForgetsOldReplays(t *testing.T) {
+	defer func(n int) { keepFinished = n }(keepFinished)
+	keepFinished = 2
+	m := NewManager(managerConfig(archive(t)), []sink.Sink{&recorder{name: "warehouse"}}, zap.NewNop())
+	defer m.Close()
+
+	var started []string
+	for i := 0; i < 3; i++ {
+		st, err := m.Start("archive", []string{"warehouse"}, Options{})
+		require.NoError(t, err)
+		started = append(started, st.ID)
+		require.Eventually(t, func() bool {
+			st, _ = m.Status(st.ID)
+			return st.State != StateRunning
+		}, 2*time.Second, 5*time.Millisecond)
+	}
+	_, ok := m.Status(started[0])
+	assert.False(t, ok, "the oldest finished replay is forgotten")
+	for _, id := range started[1:] {
+		_, ok = m.Status(id)
+		assert.True(t, ok)
+	}
+}
+
+func TestManagerRejectsArchiveStages(t *testing.T) {
+	dir := archive(t)
+	cfg := managerConfig(dir)
+	cfg.Sinks[0].Encrypt = []string{"/user_id"}
+	cfg.Sinks[1].Encrypt = []string{"/user_id"}
+	warehouse := &recorder{name: "warehouse"}
+	m := NewManager(cfg, []sink.Sink{warehouse}, zap.NewNop())
+	defer m.Close()
+
+	// The archived values are already encrypted, and would be encrypted again.
+	_, err := m.Start("archive", []string{"warehouse"}, Options{})
+	assert.EqualError(t, err, `archive "archive" has transforms, redact or encrypt and cannot be replayed`)
+	assert.Empty(t, warehouse.sent())
+}
+
+func TestManagerCancel(t *testing.T) {
+	dir := archive(t)
+	m := NewManager(managerConfig(dir), []sink.Sink{&recorder{name: "warehouse"}}, zap.NewNop())
+
+	// At one record a minute, the second batch waits until canceled.
+	st, err := m.Start("archive", []string{"warehouse"}, Options{Rate: 1.0 / 60})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		st, _ = m.Status(st.ID)
+		return st.Batches == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.True(t, m.Cancel(st.ID))
+	m.Close()
+	st, _ = m.Status(st.ID)
+	assert.Equal(t, StateCanceled, st.State)
+
+	_, err = m.Start("archive", []string{"warehouse"}, Options{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
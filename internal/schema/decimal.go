@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"benzinga-webhook/internal/decimal"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// decimalMeta validates the custom maxScale and maxPrecision keywords, which
+// mirror the maxscale and maxprecision struct tags.
+var decimalMeta = jsonschema.MustCompileString("urn:benzinga-webhook:schema:decimal-keywords", `{
+	"properties": {
+		"maxScale": {"type": "integer", "minimum": 0},
+		"maxPrecision": {"type": "integer", "minimum": 1}
+	}
+}`)
+
+type decimalCompiler struct{}
+
+func (decimalCompiler) Compile(_ jsonschema.CompilerContext, m map[string]any) (jsonschema.ExtSchema, error) {
+	ds := decimalSchema{scale: -1, precision: -1}
+	if v, ok := m["maxScale"]; ok {
+		n, err := keywordInt(v)
+		if err != nil {
+			return nil, err
+		}
+		ds.scale = n
+	}
+	if v, ok := m["maxPrecision"]; ok {
+		n, err := keywordInt(v)
+		if err != nil {
+			return nil, err
+		}
+		ds.precision = n
+	}
+	if ds.scale < 0 && ds.precision < 0 {
+		return nil, nil
+	}
+	return ds, nil
+}
+
+func keywordInt(v any) (int, error) {
+	n, err := v.(json.Number).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal keyword value %v: %w", v, err)
+	}
+	return int(n), nil
+}
+
+// decimalSchema bounds the scale and precision of numbers as written in the
+// payload; -1 disables a bound.
+type decimalSchema struct {
+	scale     int
+	precision int
+}
+
+func (ds decimalSchema) Validate(ctx jsonschema.ValidationContext, v any) error {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil
+	}
+	d, err := decimal.Parse(string(n))
+	if err != nil {
+		return nil
+	}
+	if ds.scale >= 0 && d.Scale() > ds.scale {
+		return ctx.Error("maxScale", "must have at most %d decimal places", ds.scale)
+	}
+	if ds.precision >= 0 && d.Precision() > ds.precision {
+		return ctx.Error("maxPrecision", "must have at most %d digits", ds.precision)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+// Package schema validates payloads against a JSON Schema document and maps
+// failures onto apperror violations.
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/phone"
+	"benzinga-webhook/internal/timestamp"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed log_entry.schema.json
+var files embed.FS
+
+// LogEntryFile is the name of the built-in schema describing model.LogEntry.
+const LogEntryFile = "log_entry.schema.json"
+
+var quotedProperty = regexp.MustCompile(`'([^']*)'`)
+
+// formatTags maps JSON Schema formats onto the catalog tags used by struct validation.
+var formatTags = map[string]string{
+	"date-time": "datetime",
+	"ip":        "ip",
+	"ipv4":      "ip",
+	"ipv6":      "ip",
+	"phone":     "phoneformat",
+	"currency":  "iso4217",
+}
+
+// currencies checks the "currency" format with the validator's ISO 4217 list.
+var currencies = validator.New()
+
+// Validator validates decoded JSON documents against a compiled schema.
+type Validator struct {
+	raw      []byte
+	doc      any
+	compiled *jsonschema.Schema
+}
+
+// Builtin returns the raw built-in schema for model.LogEntry.
+func Builtin() []byte {
+	raw, _ := files.ReadFile(LogEntryFile)
+	return raw
+}
+
+// Load compiles the schema at path, or the built-in LogEntry schema when path is empty.
+// The "phone" format is checked against phones, "ip" accepts IPv4 and IPv6 addresses
+// and "currency" accepts ISO 4217 codes. Numbers may be bounded with the maxScale
+// and maxPrecision keywords, and timestamps with the maxFuture and maxAge
+// keywords, which apply the window of ts.
+func Load(path string, phones *phone.Set, ts *timestamp.Policy) (*Validator, error) {
+	raw := Builtin()
+	if path != "" {
+		var err error
+		if raw, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("read schema: %w", err)
+		}
+	}
+	return New(raw, phones, ts)
+}
+
+// New compiles a raw JSON Schema document.
+func New(raw []byte, phones *phone.Set, ts *timestamp.Policy) (*Validator, error) {
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+
+	c := jsonschema.NewCompiler()
+	c.AssertFormat = true
+	c.RegisterExtension("decimal", decimalMeta, decimalCompiler{})
+	c.RegisterExtension("window", windowMeta, windowCompiler{policy: ts})
+	c.Formats["currency"] = func(v any) bool {
+		s, ok := v.(string)
+		return !ok || currencies.Var(s, "iso4217") == nil
+	}
+	c.Formats["ip"] = func(v any) bool {
+		s, ok := v.(string)
+		return !ok || net.ParseIP(s) != nil
+	}
+	c.Formats["phone"] = func(v any) bool {
+		s, ok := v.(string)
+		if !ok || phones == nil {
+			return true
+		}
+		_, matched := phones.Match(s)
+		return matched
+	}
+	if err := c.AddResource(LogEntryFile, bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("load schema: %w", err)
+	}
+	compiled, err := c.Compile(LogEntryFile)
+	if err != nil {
+		return nil, fmt.Errorf("compile schema: %w", err)
+	}
+	return &Validator{raw: raw, doc: doc, compiled: compiled}, nil
+}
+
+// Raw returns the schema document as loaded.
+func (v *Validator) Raw() []byte {
+	return v.raw
+}
+
+// Validate checks a document decoded with json.Decoder.UseNumber and returns
+// one violation per failing keyword, with messages rendered by trans.
+func (v *Validator) Validate(instance any, trans ut.Translator) []apperror.Violation {
+	err := v.compiled.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []apperror.Violation{{Code: apperror.CodeInvalid, Message: apperror.Message(trans, "", "")}}
+	}
+
+	var violations []apperror.Violation
+	for _, leaf := range leaves(ve) {
+		violations = append(violations, v.violations(leaf, instance, trans)...)
+	}
+	return violations
+}
+
+func leaves(ve *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(ve.Causes) == 0 {
+		return []*jsonschema.ValidationError{ve}
+	}
+	var out []*jsonschema.ValidationError
+	for _, c := range ve.Causes {
+		out = append(out, leaves(c)...)
+	}
+	return out
+}
+
+func (v *Validator) violations(ve *jsonschema.ValidationError, instance any, trans ut.Translator) []apperror.Violation {
+	keywordPtr := ve.AbsoluteKeywordLocation[strings.IndexByte(ve.AbsoluteKeywordLocation, '#')+1:]
+	keyword := keywordPtr[strings.LastIndexByte(keywordPtr, '/')+1:]
+	param, _ := resolve(v.doc, keywordPtr)
+	value, _ := resolve(instance, ve.InstanceLocation)
+
+	tag, code := keyword, apperror.CodeInvalid
+	switch keyword {
+	case "required":
+		return perProperty(ve, instance, apperror.CodeRequired, apperror.Message(trans, "required", ""))
+	case "additionalProperties":
+		return perProperty(ve, instance, apperror.CodeNotAllowed, apperror.Message(trans, "unknown", ""))
+	case "minimum":
+		tag, code = "gte", apperror.CodeTooSmall
+	case "exclusiveMinimum":
+		tag, code = "gt", apperror.CodeTooSmall
+	case "minLength":
+		tag, code = "min", apperror.CodeTooShort
+	case "maxScale":
+		tag, code = "maxscale", apperror.CodeTooPrecise
+	case "maxPrecision":
+		tag, code = "maxprecision", apperror.CodeTooPrecise
+	case "maxFuture":
+		tag, code = "maxfuture", apperror.CodeInFuture
+	case "maxAge":
+		tag, code = "maxage", apperror.CodeTooOld
+	case "type":
+		code = apperror.CodeInvalidType
+	case "format":
+		tag = formatTags[fmt.Sprint(param)]
+		switch tag {
+		case "datetime":
+			code = apperror.CodeInvalidDateTime
+		case "ip":
+			code = apperror.CodeInvalidIP
+		case "phoneformat":
+			code = apperror.CodeInvalidPhone
+		case "iso4217":
+			code = apperror.CodeInvalidCurrency
+		}
+	}
+
+	return []apperror.Violation{{
+		Code:    code,
+		Pointer: ve.InstanceLocation,
+		Value:   apperror.RejectedValue(ve.InstanceLocation, value),
+		Message: apperror.Message(trans, tag, paramString(param)),
+	}}
+}
+
+// perProperty reports one violation for each property named in a "required" or
+// "additionalProperties" error message.
+func perProperty(ve *jsonschema.ValidationError, instance any, code, msg string) []apperror.Violation {
+	var out []apperror.Violation
+	for _, m := range quotedProperty.FindAllStringSubmatch(ve.Message, -1) {
+		ptr := ve.InstanceLocation + "/" + escape(m[1])
+		value, _ := resolve(instance, ptr)
+		out = append(out, apperror.Violation{
+			Code:    code,
+			Pointer: ptr,
+			Value:   apperror.RejectedValue(ptr, value),
+			Message: msg,
+		})
+	}
+	return out
+}
+
+// resolve looks up an RFC 6901 JSON pointer in a decoded JSON document.
+func resolve(doc any, pointer string) (any, bool) {
+	if pointer == "" {
+		return doc, true
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch node := doc.(type) {
+		case map[string]any:
+			next, ok := node[token]
+			if !ok {
+				return nil, false
+			}
+			doc = next
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func paramString(param any) string {
+	switch p := param.(type) {
+	case nil:
+		return ""
+	case []any:
+		parts := make([]string, len(p))
+		for i, item := range p {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, " or ")
+	default:
+		return fmt.Sprint(p)
+	}
+}
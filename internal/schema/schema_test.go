@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/apperror"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/decimal"
+	"benzinga-webhook/internal/model"
+	"benzinga-webhook/internal/phone"
+	"benzinga-webhook/internal/timestamp"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, body string) any {
+	t.Helper()
+	var doc any
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	assert.NoError(t, dec.Decode(&doc))
+	return doc
+}
+
+func legacyPhones(t *testing.T) *phone.Set {
+	t.Helper()
+	phones, err := phone.New(&config.Config{PhoneFormats: []string{"legacy"}})
+	assert.NoError(t, err)
+	return phones
+}
+
+// TestBuiltinMatchesStructTags checks that the built-in schema reports the same
+// codes and pointers as struct-tag validation of model.LogEntry.
+func TestBuiltinMatchesStructTags(t *testing.T) {
+	phones := legacyPhones(t)
+	window := &timestamp.Policy{
+		MaxFuture: time.Hour,
+		MaxAge:    30 * 24 * time.Hour,
+		Now:       func() time.Time { return time.Date(2020, 8, 9, 0, 0, 0, 0, time.UTC) },
+	}
+	sv, err := Load("", phones, window)
+	assert.NoError(t, err)
+
+	validate := validator.New()
+	validate.RegisterTagNameFunc(apperror.JSONTagName)
+	assert.NoError(t, validate.RegisterValidation("phoneformat", phones.Validate))
+	assert.NoError(t, decimal.RegisterValidations(validate))
+	assert.NoError(t, window.RegisterValidations(validate))
+
+	payloads := map[string]string{
+		"valid":           `{"user_id":1,"total":9.99,"title":"ok!","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}},"completed":true}`,
+		"small values":    `{"user_id":0,"total":0,"title":"ab","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"127.0.0.1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`,
+		"bad login":       `{"user_id":1,"total":1,"title":"abc","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"::1"},{"time":"08/08/2020","ip":"x"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`,
+		"bad phones":      `{"user_id":1,"total":1,"title":"abc","meta":{"logins":[],"phone_numbers":{"home":"5551212","mobile":"555-1212-45"}}}`,
+		"amounts":         `{"user_id":1,"total":9.999,"currency":"XYZ","title":"abc","meta":{"logins":[],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`,
+		"too many digits": `{"user_id":1,"total":1234567890123456789,"currency":"EUR","title":"abc","meta":{"logins":[],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`,
+		"login window":    `{"user_id":1,"total":1,"title":"abc","meta":{"logins":[{"time":"2020-08-09T02:00:00+02:00","ip":"::1"},{"time":"2020-08-09T02:00:00Z","ip":"::1"},{"time":"2019-01-01T00:00:00Z","ip":"::1"}],"phone_numbers":{"home":"555-1212-123","mobile":"555-1212-456"}}}`,
+		"missing fields":  `{"user_id":1,"total":1,"title":"abc","meta":{"logins":[],"phone_numbers":{}}}`,
+	}
+
+	for name, body := range payloads {
+		t.Run(name, func(t *testing.T) {
+			var entry model.LogEntry
+			assert.NoError(t, json.Unmarshal([]byte(body), &entry))
+			fromStruct := map[string]string{}
+			for _, v := range apperror.Violations(validate.Struct(entry), nil) {
+				if v.Code == apperror.CodeRequired && v.Pointer == "/user_id" || v.Pointer == "/total" && v.Code == apperror.CodeRequired {
+					// required on a numeric zero value is reported as too_small by the schema.
+					v.Code = apperror.CodeTooSmall
+				}
+				fromStruct[v.Pointer] = v.Code
+			}
+
+			fromSchema := map[string]string{}
+			for _, v := range sv.Validate(decode(t, body), nil) {
+				fromSchema[v.Pointer] = v.Code
+			}
+			assert.Equal(t, fromStruct, fromSchema)
+			if name == "login window" {
+				assert.Equal(t, map[string]string{
+					"/meta/logins/1/time": apperror.CodeInFuture,
+					"/meta/logins/2/time": apperror.CodeTooOld,
+				}, fromSchema)
+			}
+		})
+	}
+}
+
+func TestValidateMessagesAndValues(t *testing.T) {
+	sv, err := Load("", legacyPhones(t), nil)
+	assert.NoError(t, err)
+
+	body := `{"user_id":"7","total":9.99,"title":"ab","meta":{"logins":[{"time":"2020-08-08T01:52:50Z","ip":"10.0.0"}],"phone_numbers":{"home":"555-1212-123"}}}`
+	violations := sv.Validate(decode(t, body), apperror.TranslatorFor("es"))
+
+	assert.ElementsMatch(t, []apperror.Violation{
+		{Code: apperror.CodeInvalidType, Pointer: "/user_id", Value: "7", Message: "debe ser de tipo integer"},
+		{Code: apperror.CodeTooShort, Pointer: "/title", Value: "ab", Message: "debe tener al menos 3 caracteres"},
+		{Code: apperror.CodeInvalidIP, Pointer: "/meta/logins/0/ip", Message: "debe ser una dirección IP válida"},
+		{Code: apperror.CodeRequired, Pointer: "/meta/phone_numbers/mobile", Message: "es obligatorio"},
+	}, violations)
+}
+
+func TestLoadCustomSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom.schema.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"required": ["user_id"],
+		"properties": {"user_id": {"type": "integer", "minimum": 10}},
+		"additionalProperties": false
+	}`), 0o600))
+
+	sv, err := Load(path, nil, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, string(sv.Raw()), `"minimum": 10`)
+
+	violations := sv.Validate(decode(t, `{"user_id":3,"extra":true}`), nil)
+	assert.ElementsMatch(t, []apperror.Violation{
+		{Code: apperror.CodeTooSmall, Pointer: "/user_id", Value: json.Number("3"), Message: "must be greater than or equal to 10"},
+		{Code: apperror.CodeNotAllowed, Pointer: "/extra", Value: true, Message: "is not an allowed field"},
+	}, violations)
+	assert.Empty(t, sv.Validate(decode(t, `{"user_id":10}`), nil))
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.json"), nil, nil)
+	assert.ErrorContains(t, err, "read schema")
+
+	_, err = New([]byte(`{`), nil, nil)
+	assert.ErrorContains(t, err, "parse schema")
+
+	_, err = New([]byte(`{"type": 12}`), nil, nil)
+	assert.ErrorContains(t, err, "compile schema")
+}
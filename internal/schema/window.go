@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"time"
+
+	"benzinga-webhook/internal/timestamp"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// windowMeta validates the custom maxFuture and maxAge keywords, which mirror
+// the maxfuture and maxage struct tags. Their bounds come from the policy the
+// schema is compiled with rather than from the schema itself.
+var windowMeta = jsonschema.MustCompileString("urn:benzinga-webhook:schema:window-keywords", `{
+	"properties": {
+		"maxFuture": {"type": "boolean"},
+		"maxAge": {"type": "boolean"}
+	}
+}`)
+
+type windowCompiler struct {
+	policy *timestamp.Policy
+}
+
+func (c windowCompiler) Compile(_ jsonschema.CompilerContext, m map[string]any) (jsonschema.ExtSchema, error) {
+	future, _ := m["maxFuture"].(bool)
+	age, _ := m["maxAge"].(bool)
+	if c.policy == nil || !future && !age {
+		return nil, nil
+	}
+	return windowSchema{policy: c.policy, future: future, age: age}, nil
+}
+
+type windowSchema struct {
+	policy *timestamp.Policy
+	future bool
+	age    bool
+}
+
+func (ws windowSchema) Validate(ctx jsonschema.ValidationContext, v any) error {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	if ws.future && ws.policy.InFuture(t) {
+		return ctx.Error("maxFuture", "must not be more than %s in the future", ws.policy.MaxFuture)
+	}
+	if ws.age && ws.policy.TooOld(t) {
+		return ctx.Error("maxAge", "must not be more than %s in the past", ws.policy.MaxAge)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"benzinga-webhook/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// Archive files. Segments are named after the time they were opened, so that
+// they sort in the order they were written.
+const (
+	// IndexFile lists every batch archived, one IndexEntry per line.
+	IndexFile = "index.ndjson"
+	// SegmentSuffix ends the name of the segment being written.
+	SegmentSuffix = ".ndjson"
+	// CompressedSuffix ends the name of a closed segment.
+	CompressedSuffix = ".ndjson.gz"
+
+	segmentPrefix = "segment-"
+	segmentLayout = "20060102T150405.000000000Z"
+)
+
+// ArchiveLine is one line of a segment: a record and the batch it was
+// delivered in.
+type ArchiveLine struct {
+	BatchID   string          `json:"batch_id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Record    json.RawMessage `json:"record"`
+}
+
+// IndexEntry locates an archived batch: its records are Count lines of
+// Segment starting at line Line, counting from 1. Segment is the name the
+// segment was written under; once closed, it carries CompressedSuffix instead
+// of SegmentSuffix.
+type IndexEntry struct {
+	BatchID   string    `json:"batch_id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Segment   string    `json:"segment"`
+	Line      int       `json:"line"`
+	Count     int       `json:"count"`
+}
+
+// Archive appends batches to NDJSON segment files in a directory. Segments
+// are rotated by size and age, gzip-compressed once closed and deleted by
+// age and total size. It is safe for concurrent use.
+type Archive struct {
+	name string
+	cfg  config.ArchiveConfig
+	log  *zap.Logger
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	mu     sync.Mutex // guards the fields below
+	active *os.File
+	path   string    // of active
+	opened time.Time // when active was opened
+	size   int64     // of active
+	lines  int       // in active
+}
+
+// NewArchive creates the archive directory if needed. Segments left open by
+// a previous run are compressed and the retention limits applied.
+func NewArchive(name string, cfg config.ArchiveConfig, log *zap.Logger) (*Archive, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("archive %q: %w", name, err)
+	}
+	a := &Archive{name: name, cfg: cfg, log: log, Now: time.Now}
+	open, err := filepath.Glob(filepath.Join(cfg.Dir, segmentPrefix+"*"+SegmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range open {
+		if err := compress(path); err != nil {
+			return nil, fmt.Errorf("archive %q: %w", name, err)
+		}
+	}
+	if err := a.retain(); err != nil {
+		return nil, fmt.Errorf("archive %q: %w", name, err)
+	}
+	return a, nil
+}
+
+func (a *Archive) now() time.Time {
+	if a.Now == nil {
+		return time.Now()
+	}
+	return a.Now()
+}
+
+// Name returns the configured sink name.
+func (a *Archive) Name() string {
+	return a.name
+}
+
+// Send appends the records of b to the active segment, one ArchiveLine per
+// line, and lists b in the index. Both are synced to disk before it returns.
+func (a *Archive) Send(_ context.Context, b Batch) error {
+	var buf bytes.Buffer
+	for _, record := range b.Records {
+		line, err := marshal(ArchiveLine{BatchID: b.ID, Type: b.Type, CreatedAt: b.CreatedAt, Record: record})
+		if err != nil {
+			return fmt.Errorf("archive %q: encode record: %w", a.name, err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	if a.active != nil && (a.size >= a.cfg.SegmentBytes || now.Sub(a.opened) >= a.cfg.SegmentAge) {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	if a.active == nil {
+		if err := a.open(now); err != nil {
+			return err
+		}
+	}
+
+	entry := IndexEntry{
+		BatchID:   b.ID,
+		Type:      b.Type,
+		CreatedAt: b.CreatedAt,
+		Segment:   filepath.Base(a.path),
+		Line:      a.lines + 1,
+		Count:     len(b.Records),
+	}
+	if _, err := a.active.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("archive %q: %w", a.name, err)
+	}
+	if err := a.active.Sync(); err != nil {
+		return fmt.Errorf("archive %q: %w", a.name, err)
+	}
+	a.size += int64(buf.Len())
+	a.lines += len(b.Records)
+	if err := a.index(entry); err != nil {
+		return fmt.Errorf("archive %q: index: %w", a.name, err)
+	}
+	return nil
+}
+
+// open starts a segment named after now, or just after the previous segment
+// when the clock has not moved on since.
+func (a *Archive) open(now time.Time) error {
+	if !now.After(a.opened) {
+		now = a.opened.Add(time.Nanosecond)
+	}
+	path := filepath.Join(a.cfg.Dir, segmentPrefix+now.UTC().Format(segmentLayout)+SegmentSuffix)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("archive %q: %w", a.name, err)
+	}
+	a.active, a.path, a.opened, a.size, a.lines = f, path, now, 0, 0
+	return nil
+}
+
+// rotate closes and compresses the active segment, then applies the
+// retention limits.
+func (a *Archive) rotate() error {
+	if err := a.active.Close(); err != nil {
+		return fmt.Errorf("archive %q: %w", a.name, err)
+	}
+	a.active = nil
+	if err := compress(a.path); err != nil {
+		return fmt.Errorf("archive %q: %w", a.name, err)
+	}
+	if err := a.retain(); err != nil {
+		// Delivery succeeded; the limits are applied again on the next rotation.
+		a.log.Warn("archive retention failed", zap.String("sink", a.name), zap.Error(err))
+	}
+	return nil
+}
+
+func (a *Archive) index(entry IndexEntry) error {
+	line, err := marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(a.cfg.Dir, IndexFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// retain deletes compressed segments older than MaxAge, then the oldest
+// until they total at most MaxBytes, and drops their batches from the index.
+func (a *Archive) retain() error {
+	if a.cfg.MaxAge <= 0 && a.cfg.MaxBytes <= 0 {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(a.cfg.Dir, segmentPrefix+"*"+CompressedSuffix))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	type segment struct {
+		path string
+		size int64
+	}
+	segments := make([]segment, 0, len(paths))
+	var total int64
+	now := a.now()
+	deleted := make(map[string]bool)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if a.cfg.MaxAge > 0 && now.Sub(info.ModTime()) > a.cfg.MaxAge {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			deleted[segmentName(path)] = true
+			continue
+		}
+		segments = append(segments, segment{path: path, size: info.Size()})
+		total += info.Size()
+	}
+	for len(segments) > 0 && a.cfg.MaxBytes > 0 && total > a.cfg.MaxBytes {
+		if err := os.Remove(segments[0].path); err != nil {
+			return err
+		}
+		deleted[segmentName(segments[0].path)] = true
+		total -= segments[0].size
+		segments = segments[1:]
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	a.log.Info("archive segments deleted", zap.String("sink", a.name), zap.Int("segments", len(deleted)))
+	return pruneIndex(filepath.Join(a.cfg.Dir, IndexFile), deleted)
+}
+
+// segmentName returns the name a segment was written under.
+func segmentName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), CompressedSuffix) + SegmentSuffix
+}
+
+// pruneIndex rewrites the index at path without the batches of the deleted
+// segments.
+func pruneIndex(path string, deleted map[string]bool) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var kept bytes.Buffer
+	lines := bufio.NewScanner(bytes.NewReader(raw))
+	lines.Buffer(nil, 1<<20)
+	for lines.Scan() {
+		var entry IndexEntry
+		if err := json.Unmarshal(lines.Bytes(), &entry); err == nil && deleted[entry.Segment] {
+			continue
+		}
+		kept.Write(lines.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	return writeFile(path, kept.Bytes())
+}
+
+// compress replaces the segment at path with its gzip-compressed copy.
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst := strings.TrimSuffix(path, SegmentSuffix) + CompressedSuffix
+	tmp, err := os.OpenFile(dst+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+	zw := gzip.NewWriter(tmp)
+	zw.Name = filepath.Base(path)
+	if _, err := io.Copy(zw, src); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// writeFile replaces the file at path with data atomically.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
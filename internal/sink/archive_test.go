@@ -0,0 +1,184 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newArchive(t *testing.T, cfg config.ArchiveConfig, now *time.Time) *Archive {
+	if cfg.Dir == "" {
+		cfg.Dir = t.TempDir()
+	}
+	if cfg.SegmentBytes == 0 {
+		cfg.SegmentBytes = 64 << 20
+	}
+	if cfg.SegmentAge == 0 {
+		cfg.SegmentAge = time.Hour
+	}
+	a, err := NewArchive("archive", cfg, zap.NewNop())
+	require.NoError(t, err)
+	a.Now = func() time.Time { return *now }
+	return a
+}
+
+func archiveBatch(id string, records ...string) Batch {
+	b := Batch{ID: id, Type: "log_entry", CreatedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	for _, r := range records {
+		b.Records = append(b.Records, json.RawMessage(r))
+	}
+	return b
+}
+
+// readLines returns the lines of a plain or gzip-compressed file.
+func readLines(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var r interface{ Read([]byte) (int, error) } = f
+	if filepath.Ext(path) == ".gz" {
+		zr, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		r = zr
+	}
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	require.NoError(t, s.Err())
+	return lines
+}
+
+func readIndex(t *testing.T, dir string) []IndexEntry {
+	var entries []IndexEntry
+	for _, line := range readLines(t, filepath.Join(dir, IndexFile)) {
+		var e IndexEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func segments(t *testing.T, dir, suffix string) []string {
+	paths, err := filepath.Glob(filepath.Join(dir, segmentPrefix+"*"+suffix))
+	require.NoError(t, err)
+	return paths
+}
+
+func TestArchiveWritesSegmentsAndIndex(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := newArchive(t, config.ArchiveConfig{}, &now)
+	dir := a.cfg.Dir
+
+	require.NoError(t, a.Send(context.Background(), archiveBatch("b1", `{"user_id":1}`, "{\n \"user_id\": 2}")))
+	require.NoError(t, a.Send(context.Background(), archiveBatch("b2", `{"title":"<a&b>"}`)))
+
+	open := segments(t, dir, SegmentSuffix)
+	require.Len(t, open, 1)
+	assert.Equal(t, "segment-20240601T120000.000000000Z.ndjson", filepath.Base(open[0]))
+	assert.Equal(t, []string{
+		`{"batch_id":"b1","type":"log_entry","created_at":"2024-06-01T12:00:00Z","record":{"user_id":1}}`,
+		`{"batch_id":"b1","type":"log_entry","created_at":"2024-06-01T12:00:00Z","record":{"user_id":2}}`,
+		`{"batch_id":"b2","type":"log_entry","created_at":"2024-06-01T12:00:00Z","record":{"title":"<a&b>"}}`,
+	}, readLines(t, open[0]))
+
+	segment := filepath.Base(open[0])
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, []IndexEntry{
+		{BatchID: "b1", Type: "log_entry", CreatedAt: created, Segment: segment, Line: 1, Count: 2},
+		{BatchID: "b2", Type: "log_entry", CreatedAt: created, Segment: segment, Line: 3, Count: 1},
+	}, readIndex(t, dir))
+}
+
+func TestArchiveRotatesAndCompresses(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := newArchive(t, config.ArchiveConfig{SegmentBytes: 50, SegmentAge: time.Minute}, &now)
+	dir := a.cfg.Dir
+	ctx := context.Background()
+
+	// Rotated by size: the first batch fills the segment. The clock has not
+	// moved, so the next segment is named a nanosecond later.
+	require.NoError(t, a.Send(ctx, archiveBatch("b1", `{"user_id":1}`)))
+	require.NoError(t, a.Send(ctx, archiveBatch("b2", `{"user_id":2}`)))
+	// Rotated by age.
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, a.Send(ctx, archiveBatch("b3", `{}`)))
+
+	closed := segments(t, dir, CompressedSuffix)
+	require.Len(t, closed, 2)
+	assert.Equal(t, "segment-20240601T120000.000000001Z.ndjson.gz", filepath.Base(closed[1]))
+	assert.Len(t, readLines(t, closed[0]), 1)
+	assert.Contains(t, readLines(t, closed[1])[0], `"batch_id":"b2"`)
+	require.Len(t, segments(t, dir, SegmentSuffix), 1)
+
+	index := readIndex(t, dir)
+	require.Len(t, index, 3)
+	assert.Equal(t, segmentName(closed[0]), index[0].Segment)
+	assert.Equal(t, segmentName(closed[1]), index[1].Segment)
+	assert.Equal(t, 1, index[2].Line)
+
+	// A segment left open is compressed when the archive is reopened.
+	newArchive(t, config.ArchiveConfig{Dir: dir}, &now)
+	assert.Empty(t, segments(t, dir, SegmentSuffix))
+	assert.Len(t, segments(t, dir, CompressedSuffix), 3)
+}
+
+func TestArchiveRetention(t *testing.T) {
+	now := time.Now()
+	a := newArchive(t, config.ArchiveConfig{SegmentBytes: 1}, &now)
+	dir := a.cfg.Dir
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, a.Send(context.Background(), archiveBatch(fmt.Sprintf("b%d", i), `{"user_id":1}`)))
+	}
+	closed := segments(t, dir, CompressedSuffix)
+	require.Len(t, closed, 4)
+	info, err := os.Stat(closed[0])
+	require.NoError(t, err)
+
+	// Keep the newest two of the closed segments by size.
+	a.cfg.MaxBytes = 2*info.Size() + 1
+	require.NoError(t, a.retain())
+	assert.Equal(t, closed[2:], segments(t, dir, CompressedSuffix))
+	var ids []string
+	for _, e := range readIndex(t, dir) {
+		ids = append(ids, e.BatchID)
+	}
+	assert.Equal(t, []string{"b3", "b4", "b5"}, ids)
+
+	// Then everything closed an hour ago by age.
+	a.cfg.MaxBytes, a.cfg.MaxAge = 0, time.Hour
+	now = now.Add(2 * time.Hour)
+	require.NoError(t, a.retain())
+	assert.Empty(t, segments(t, dir, CompressedSuffix))
+	require.Len(t, readIndex(t, dir), 1)
+	assert.Equal(t, "b5", readIndex(t, dir)[0].BatchID)
+}
+
+func TestFromConfigArchive(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	cfg := &config.Config{Sinks: []config.SinkConfig{{
+		Name:    "archive",
+		Type:    "file",
+		Format:  config.FormatNDJSON,
+		Archive: config.ArchiveConfig{Dir: dir, SegmentBytes: 1 << 20, SegmentAge: time.Hour},
+	}}}
+	sinks, err := FromConfig(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, sinks, 1)
+	assert.Equal(t, "archive", sinks[0].Name())
+	require.NoError(t, sinks[0].Send(context.Background(), archiveBatch("b1", `{}`)))
+	assert.Len(t, readIndex(t, dir), 1)
+}
@@ -0,0 +1,199 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultSource identifies this service in payloads that name their source
+// when the sink configures none.
+const DefaultSource = "benzinga-webhook"
+
+// Encoder turns a batch into the body a sink delivers.
+type Encoder interface {
+	ContentType() string
+	Encode(b Batch) ([]byte, error)
+}
+
+// NewEncoder returns the encoder for one of the config.Format values. source
+// is used by formats that name their source, DefaultSource when empty.
+func NewEncoder(format, source string) (Encoder, error) {
+	if source == "" {
+		source = DefaultSource
+	}
+	switch format {
+	case "", config.FormatJSON:
+		return JSON{}, nil
+	case config.FormatNDJSON:
+		return NDJSON{}, nil
+	case config.FormatCloudEvents:
+		return CloudEvents{Source: source}, nil
+	case config.FormatEnvelope:
+		return &Envelope{Source: source, Now: time.Now}, nil
+	case config.FormatProtobuf:
+		return Protobuf{}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// JSON encodes a batch as a JSON array of its records.
+type JSON struct{}
+
+// ContentType returns "application/json".
+func (JSON) ContentType() string { return "application/json" }
+
+// Encode returns JSONArray(b.Records).
+func (JSON) Encode(b Batch) ([]byte, error) { return JSONArray(b.Records), nil }
+
+// NDJSON encodes a batch as newline-delimited JSON, one record per line.
+type NDJSON struct{}
+
+// ContentType returns "application/x-ndjson".
+func (NDJSON) ContentType() string { return "application/x-ndjson" }
+
+// Encode returns every record, compacted onto one line, followed by a newline.
+func (NDJSON) Encode(b Batch) ([]byte, error) {
+	var out bytes.Buffer
+	for _, r := range b.Records {
+		if err := json.Compact(&out, r); err != nil {
+			return nil, err
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// CloudEvents encodes a batch in the CloudEvents 1.0 JSON batch format, one
+// event per record with the record as its data.
+type CloudEvents struct {
+	Source string
+}
+
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// ContentType returns "application/cloudevents-batch+json".
+func (CloudEvents) ContentType() string { return "application/cloudevents-batch+json" }
+
+// Encode returns the events of b. Event IDs are the batch ID and the position
+// of the record in the batch, so that redelivered batches can be deduplicated.
+func (c CloudEvents) Encode(b Batch) ([]byte, error) {
+	events := make([]cloudEvent, len(b.Records))
+	for i, r := range b.Records {
+		events[i] = cloudEvent{
+			SpecVersion:     "1.0",
+			ID:              b.ID + "-" + strconv.Itoa(i),
+			Source:          c.Source,
+			Type:            b.Type,
+			Time:            b.CreatedAt.UTC().Format(time.RFC3339Nano),
+			DataContentType: "application/json",
+			Data:            r,
+		}
+	}
+	return marshal(events)
+}
+
+// Envelope encodes a batch as an object describing the batch, with the
+// records in "records" and their SHA-256 in "checksum".
+type Envelope struct {
+	Source string
+	// Now returns the time reported in "sent_at". It defaults to time.Now.
+	Now func() time.Time
+}
+
+type envelopeBody struct {
+	BatchID  string          `json:"batch_id"`
+	Type     string          `json:"type"`
+	Count    int             `json:"count"`
+	SentAt   string          `json:"sent_at"`
+	Source   string          `json:"source"`
+	Checksum string          `json:"checksum"`
+	Records  json.RawMessage `json:"records"`
+}
+
+// ContentType returns "application/json".
+func (*Envelope) ContentType() string { return "application/json" }
+
+// Encode returns the envelope of b. The checksum is "sha256:" followed by the
+// hex digest of the "records" array exactly as it appears in the body.
+func (e *Envelope) Encode(b Batch) ([]byte, error) {
+	now := time.Now
+	if e.Now != nil {
+		now = e.Now
+	}
+	// Records are compacted as they would be in the body anyway, so that the
+	// checksum covers the exact bytes sent.
+	var records bytes.Buffer
+	if err := json.Compact(&records, JSONArray(b.Records)); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(records.Bytes())
+	return marshal(envelopeBody{
+		BatchID:  b.ID,
+		Type:     b.Type,
+		Count:    len(b.Records),
+		SentAt:   now().UTC().Format(time.RFC3339Nano),
+		Source:   e.Source,
+		Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+		Records:  records.Bytes(),
+	})
+}
+
+// marshal is json.Marshal without HTML escaping, so that records embedded in
+// a payload keep the encoding they have in the JSON formats.
+func marshal(v any) ([]byte, error) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// logEntryType is event.LogEntry, which this package cannot import.
+const logEntryType = "log_entry"
+
+// Protobuf encodes a batch as a pb.Batch message. Records of log_entry
+// batches are converted to pb.LogEntry messages; those of other types are
+// carried as JSON.
+type Protobuf struct{}
+
+// ContentType returns "application/x-protobuf".
+func (Protobuf) ContentType() string { return pb.ContentType }
+
+// Encode returns the pb.Batch of b.
+func (Protobuf) Encode(b Batch) ([]byte, error) {
+	m := &pb.Batch{Id: b.ID, Type: b.Type, CreatedAt: b.CreatedAt.UTC().Format(time.RFC3339Nano)}
+	for i, r := range b.Records {
+		if b.Type != logEntryType {
+			m.JsonRecords = append(m.JsonRecords, r)
+			continue
+		}
+		e, err := pb.LogEntryFromJSON(r)
+		if err != nil {
+			// The error may quote the record, so only its position is reported.
+			return nil, fmt.Errorf("record %d is not a log entry", i)
+		}
+		m.LogEntries = append(m.LogEntries, e)
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+}
@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/pb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestEncoders(t *testing.T) {
+	batch := Batch{
+		ID:        "0123456789abcdef0123456789abcdef",
+		Type:      "log_entry",
+		CreatedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Records: []json.RawMessage{
+			json.RawMessage(`{"user_id":1,"total":"1.23","title":"<b>Order</b>"}`),
+			json.RawMessage(`{ "user_id": 2, "total": "4.56", "title": "Refund" }`),
+		},
+	}
+	for _, tc := range []struct {
+		format      string
+		contentType string
+	}{
+		{config.FormatJSON, "application/json"},
+		{config.FormatNDJSON, "application/x-ndjson"},
+		{config.FormatCloudEvents, "application/cloudevents-batch+json"},
+		{config.FormatEnvelope, "application/json"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			enc, err := NewEncoder(tc.format, "")
+			require.NoError(t, err)
+			if e, ok := enc.(*Envelope); ok {
+				e.Now = func() time.Time { return batch.CreatedAt.Add(1500 * time.Millisecond) }
+			}
+			assert.Equal(t, tc.contentType, enc.ContentType())
+
+			got, err := enc.Encode(batch)
+			require.NoError(t, err)
+			golden := filepath.Join("testdata", tc.format+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, got, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+}
+
+func TestEnvelopeChecksum(t *testing.T) {
+	body, err := (&Envelope{Source: "test"}).Encode(Batch{Records: []json.RawMessage{json.RawMessage(`{"a": 1}`)}})
+	require.NoError(t, err)
+
+	var got struct {
+		Checksum string          `json:"checksum"`
+		Records  json.RawMessage `json:"records"`
+	}
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, `[{"a":1}]`, string(got.Records))
+	// sha256 of [{"a":1}]
+	assert.Equal(t, "sha256:b713f6d2a989e907c58516a7c8bb487792c8785ddbf367b80dc774bf33b85a85", got.Checksum)
+}
+
+func TestProtobufEncoder(t *testing.T) {
+	enc, err := NewEncoder(config.FormatProtobuf, "")
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-protobuf", enc.ContentType())
+	createdAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	body, err := enc.Encode(Batch{ID: "b1", Type: "log_entry", CreatedAt: createdAt, Records: []json.RawMessage{
+		json.RawMessage(`{"user_id":1,"total":99.90,"title":"Order","meta":{"logins":null,"phone_numbers":{"home":"","mobile":""}},"completed":true}`),
+	}})
+	require.NoError(t, err)
+	var m pb.Batch
+	require.NoError(t, proto.Unmarshal(body, &m))
+	assert.Equal(t, "b1", m.GetId())
+	assert.Equal(t, "2024-06-01T12:00:00Z", m.GetCreatedAt())
+	if assert.Len(t, m.GetLogEntries(), 1) {
+		assert.Equal(t, "99.90", m.GetLogEntries()[0].GetTotal())
+		assert.Equal(t, "Order", m.GetLogEntries()[0].GetTitle())
+	}
+
+	body, err = enc.Encode(Batch{ID: "b2", Type: "order", Records: []json.RawMessage{json.RawMessage(`{"order_id":"A-1"}`)}})
+	require.NoError(t, err)
+	require.NoError(t, proto.Unmarshal(body, &m))
+	assert.Equal(t, [][]byte{[]byte(`{"order_id":"A-1"}`)}, m.GetJsonRecords())
+
+	_, err = enc.Encode(Batch{Type: "log_entry", Records: []json.RawMessage{json.RawMessage(`{"userId":1}`)}})
+	assert.EqualError(t, err, "record 0 is not a log entry")
+}
+
+func TestNewEncoderUnknownFormat(t *testing.T) {
+	_, err := NewEncoder("xml", "")
+	assert.EqualError(t, err, `unknown format "xml"`)
+}
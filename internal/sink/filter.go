@@ -0,0 +1,28 @@
+package sink
+
+// Filter decides which records a sink receives. Batchers consult it when an
+// entry is added, so that entries no sink wants never take up queue capacity.
+type Filter interface {
+	// Keep reports whether the sink receives doc, a record decoded with
+	// json.Decoder.UseNumber.
+	Keep(doc any) bool
+}
+
+type filtered struct {
+	Sink
+	filter Filter
+}
+
+// WithFilter returns next with f attached, for batchers to find with FilterOf.
+// Sending to the returned sink does not filter.
+func WithFilter(next Sink, f Filter) Sink {
+	return &filtered{Sink: next, filter: f}
+}
+
+// FilterOf returns the filter attached to s with WithFilter.
+func FilterOf(s Sink) (Filter, bool) {
+	if f, ok := s.(*filtered); ok {
+		return f.filter, true
+	}
+	return nil, false
+}
@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReplayHeader carries Batch.Replay on requests that re-deliver a batch, so
+// that receivers can tell replays from first deliveries.
+const ReplayHeader = "X-Webhook-Replay"
+
+// HTTP posts encoded batches to an endpoint.
+type HTTP struct {
+	name     string
+	endpoint string
+	encoder  Encoder
+	log      *zap.Logger
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// NewHTTP creates a sink that POSTs batches encoded with enc to endpoint,
+// making up to three attempts two seconds apart.
+func NewHTTP(name, endpoint string, enc Encoder, log *zap.Logger) *HTTP {
+	return &HTTP{
+		name:     name,
+		endpoint: endpoint,
+		encoder:  enc,
+		log:      log,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		attempts: 3,
+		backoff:  2 * time.Second,
+	}
+}
+
+// Name returns the configured sink name.
+func (s *HTTP) Name() string {
+	return s.name
+}
+
+// Send posts the batch, retrying on transport errors and non-2xx responses.
+func (s *HTTP) Send(ctx context.Context, b Batch) error {
+	payload, err := s.encoder.Encode(b)
+	if err != nil {
+		return fmt.Errorf("encode batch: %w", err)
+	}
+
+	var lastErr error
+	for i := 1; i <= s.attempts; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", s.encoder.ContentType())
+		if b.Replay != "" {
+			req.Header.Set(ReplayHeader, b.Replay)
+		}
+
+		resp, err := s.client.Do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		lastErr = err
+		s.log.Warn("POST failed", zap.String("sink", s.name), zap.Int("attempt", i), zap.Error(err))
+		if i < s.attempts {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.backoff):
+			}
+		}
+	}
+	return fmt.Errorf("batch failed after %d attempts: %w", s.attempts, lastErr)
+}
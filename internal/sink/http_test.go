@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHTTPSend(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `[{"a":1},{"a":2}]`, string(body))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	s := NewHTTP("test", srv.URL, JSON{}, zap.NewNop())
+	s.backoff = 0
+	err := s.Send(context.Background(), Batch{Records: []json.RawMessage{
+		json.RawMessage(`{"a":1}`), json.RawMessage(`{"a":2}`),
+	}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestHTTPSendGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	s := NewHTTP("test", srv.URL, JSON{}, zap.NewNop())
+	s.backoff = 0
+	err := s.Send(context.Background(), Batch{Records: []json.RawMessage{json.RawMessage(`{}`)}})
+
+	assert.EqualError(t, err, "batch failed after 3 attempts: unexpected status code 502")
+}
+
+func TestHTTPSendEncodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "{\"a\":1}\n{\"a\":2}\n", string(body))
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		assert.Empty(t, r.Header.Get(ReplayHeader))
+	}))
+	defer srv.Close()
+
+	s := NewHTTP("test", srv.URL, NDJSON{}, zap.NewNop())
+	err := s.Send(context.Background(), Batch{Records: []json.RawMessage{
+		json.RawMessage(`{"a":1}`), json.RawMessage(`{"a":2}`),
+	}})
+
+	assert.NoError(t, err)
+}
+
+func TestHTTPSendMarksReplays(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "r1", r.Header.Get(ReplayHeader))
+	}))
+	defer srv.Close()
+
+	s := NewHTTP("test", srv.URL, JSON{}, zap.NewNop())
+	err := s.Send(context.Background(), Batch{Records: []json.RawMessage{json.RawMessage(`{}`)}, Replay: "r1"})
+
+	assert.NoError(t, err)
+}
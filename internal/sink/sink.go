@@ -0,0 +1,162 @@
+// Package sink delivers batches of events to downstream systems.
+package sink
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/envelope"
+	"benzinga-webhook/internal/filter"
+	"benzinga-webhook/internal/redact"
+	"benzinga-webhook/internal/transform"
+
+	"go.uber.org/zap"
+)
+
+// Batch is a group of events of one type delivered together.
+type Batch struct {
+	ID        string
+	Type      string
+	CreatedAt time.Time
+	// Records holds the JSON encoding of each event in the batch.
+	Records []json.RawMessage
+	// Replay identifies the replay that re-delivers an archived batch. It is
+	// empty for batches delivered as they are accepted.
+	Replay string
+}
+
+// Sink delivers batches to a single destination.
+type Sink interface {
+	Name() string
+	// Send delivers b, retrying as appropriate for the destination. A non-nil
+	// error means the batch was not delivered.
+	Send(ctx context.Context, b Batch) error
+}
+
+// NewBatchID returns a random identifier for a batch.
+func NewBatchID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// JSONArray encodes records as a single JSON array.
+func JSONArray(records []json.RawMessage) []byte {
+	size := 2
+	for _, r := range records {
+		size += len(r) + 1
+	}
+	out := make([]byte, 0, size)
+	out = append(out, '[')
+	for i, r := range records {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, r...)
+	}
+	return append(out, ']')
+}
+
+// FromConfig builds the sinks declared in cfg, in declaration order. Records
+// that pass the sink's filter rules are transformed, then redacted, then
+// encrypted.
+func FromConfig(cfg *config.Config, transforms *transform.Set, log *zap.Logger) ([]Sink, error) {
+	var keyring *envelope.Keyring
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for _, sc := range cfg.Sinks {
+		var s Sink
+		switch sc.Type {
+		case "http":
+			enc, err := NewEncoder(sc.Format, sc.Source)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", sc.Name, err)
+			}
+			s = NewHTTP(sc.Name, sc.URL, enc, log)
+		case "file":
+			a, err := NewArchive(sc.Name, sc.Archive, log)
+			if err != nil {
+				return nil, err
+			}
+			s = a
+		default:
+			return nil, fmt.Errorf("sink %q: unknown type %q", sc.Name, sc.Type)
+		}
+
+		var stages []Stage
+		if len(sc.Transforms) > 0 {
+			p, err := transforms.Pipeline(sc.Transforms...)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", sc.Name, err)
+			}
+			stages = append(stages, p)
+		}
+		if len(sc.Redact) > 0 {
+			r, err := redact.New(sc.Redact, []byte(cfg.RedactHMACKey))
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", sc.Name, err)
+			}
+			stages = append(stages, r)
+		}
+		if len(sc.Encrypt) > 0 {
+			if keyring == nil {
+				var err error
+				if keyring, err = envelope.LoadKeyring(cfg.EncryptionKeyring); err != nil {
+					return nil, err
+				}
+			}
+			e, err := envelope.NewEncryptor(keyring, sc.Encrypt)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", sc.Name, err)
+			}
+			stages = append(stages, e)
+		}
+		s = WithStages(s, stages...)
+		if len(sc.Filter) > 0 {
+			f, err := filter.New(sc.Name, sc.Filter)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", sc.Name, err)
+			}
+			s = WithFilter(s, f)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// Route returns the sinks batches of the named event type are delivered to:
+// those listed for it in cfg, or all sinks but the alert sink when none are
+// listed.
+func Route(sinks []Sink, cfg *config.Config, eventType string) []Sink {
+	ec, ok := cfg.Event(eventType)
+	if !ok || len(ec.Sinks) == 0 {
+		if !cfg.Detection.Enabled() {
+			return sinks
+		}
+		var routed []Sink
+		for _, s := range sinks {
+			if s.Name() != cfg.Detection.AlertSink {
+				routed = append(routed, s)
+			}
+		}
+		return routed
+	}
+	return Named(sinks, ec.Sinks...)
+}
+
+// Named returns the sinks with the given names, in the order of names.
+func Named(sinks []Sink, names ...string) []Sink {
+	var named []Sink
+	for _, name := range names {
+		for _, s := range sinks {
+			if s.Name() == name {
+				named = append(named, s)
+			}
+		}
+	}
+	return named
+}
@@ -0,0 +1,27 @@
+package sink
+
+import (
+	"context"
+	"testing"
+
+	"benzinga-webhook/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namedSink string
+
+func (n namedSink) Name() string                      { return string(n) }
+func (n namedSink) Send(context.Context, Batch) error { return nil }
+
+func TestRoute(t *testing.T) {
+	sinks := []Sink{namedSink("warehouse"), namedSink("audit"), namedSink("security")}
+	cfg := &config.Config{Events: []config.EventConfig{{Name: "order", Sinks: []string{"audit", "warehouse"}}}}
+
+	assert.Equal(t, sinks, Route(sinks, cfg, "log_entry"))
+	assert.Equal(t, []Sink{namedSink("audit"), namedSink("warehouse")}, Route(sinks, cfg, "order"))
+
+	cfg.Detection.AlertSink = "security"
+	assert.Equal(t, sinks[:2], Route(sinks, cfg, "log_entry"), "the alert sink only receives alerts")
+	assert.Equal(t, []Sink{namedSink("security")}, Named(sinks, "security"))
+}
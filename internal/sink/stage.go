@@ -0,0 +1,51 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Stage rewrites records before a sink delivers them. Returning a nil record
+// drops it from the batch.
+type Stage interface {
+	Apply(record json.RawMessage) (json.RawMessage, error)
+}
+
+type staged struct {
+	Sink
+	stages []Stage
+}
+
+// WithStages returns a sink that runs every record through stages, in order,
+// before delegating to next. Batches left empty are not sent.
+func WithStages(next Sink, stages ...Stage) Sink {
+	if len(stages) == 0 {
+		return next
+	}
+	return &staged{Sink: next, stages: stages}
+}
+
+func (s *staged) Send(ctx context.Context, b Batch) error {
+	records := make([]json.RawMessage, 0, len(b.Records))
+	for i, record := range b.Records {
+		var err error
+		for _, stage := range s.stages {
+			if record, err = stage.Apply(record); err != nil {
+				// The error may quote the record, so only its position is reported.
+				return fmt.Errorf("sink %q: record %d: stage %T failed", s.Name(), i, stage)
+			}
+			if record == nil {
+				break
+			}
+		}
+		if record != nil {
+			records = append(records, record)
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	b.Records = records
+	return s.Sink.Send(ctx, b)
+}
@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type captureSink struct {
+	batches []Batch
+}
+
+func (c *captureSink) Name() string { return "capture" }
+
+func (c *captureSink) Send(_ context.Context, b Batch) error {
+	c.batches = append(c.batches, b)
+	return nil
+}
+
+type stageFunc func(json.RawMessage) (json.RawMessage, error)
+
+func (f stageFunc) Apply(record json.RawMessage) (json.RawMessage, error) { return f(record) }
+
+func TestWithStages(t *testing.T) {
+	next := &captureSink{}
+	dropOdd := stageFunc(func(r json.RawMessage) (json.RawMessage, error) {
+		if string(r) == `1` || string(r) == `3` {
+			return nil, nil
+		}
+		return r, nil
+	})
+	double := stageFunc(func(r json.RawMessage) (json.RawMessage, error) {
+		return json.RawMessage(string(r) + string(r)), nil
+	})
+	s := WithStages(next, dropOdd, double)
+	assert.Equal(t, "capture", s.Name())
+
+	batch := Batch{ID: "b1", Records: []json.RawMessage{json.RawMessage(`1`), json.RawMessage(`2`), json.RawMessage(`3`)}}
+	assert.NoError(t, s.Send(context.Background(), batch))
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`22`)}, next.batches[0].Records)
+	assert.Len(t, batch.Records, 3, "the caller's batch is not modified")
+
+	assert.NoError(t, s.Send(context.Background(), Batch{Records: []json.RawMessage{json.RawMessage(`1`)}}))
+	assert.Len(t, next.batches, 1, "empty batches are not sent")
+
+	failing := WithStages(next, stageFunc(func(json.RawMessage) (json.RawMessage, error) {
+		return nil, errors.New(`bad value "555-1212-123"`)
+	}))
+	err := failing.Send(context.Background(), batch)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "555-1212-123")
+}
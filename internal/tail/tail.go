@@ -0,0 +1,252 @@
+// Package tail streams accepted events to live subscribers, such as the
+// admins following GET /tail, without slowing ingestion down.
+package tail
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+
+	"benzinga-webhook/internal/batcher"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/redact"
+)
+
+// Dropped counts subscribers disconnected for falling more than their buffer
+// behind. It is published at /admin/debug/vars as "tail_dropped".
+var Dropped = expvar.NewInt("tail_dropped")
+
+// Event is an accepted event as streamed to subscribers, after redaction.
+type Event struct {
+	// Seq numbers the events published by a Hub from 1.
+	Seq    uint64
+	Type   string
+	Record json.RawMessage
+}
+
+// Filter selects the events a subscriber receives. Empty fields match every
+// event.
+type Filter struct {
+	// Type is the exact event type name.
+	Type string
+	// UserID matches events whose user_id is this number.
+	UserID string
+	// Title matches events whose title contains it, ignoring case.
+	Title string
+}
+
+// match reports whether doc, an event of type typ decoded with
+// json.Decoder.UseNumber, passes f.
+func (f Filter) match(typ string, doc any) bool {
+	if f.Type != "" && f.Type != typ {
+		return false
+	}
+	if f.UserID == "" && f.Title == "" {
+		return true
+	}
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return false
+	}
+	if f.UserID != "" {
+		if id, ok := obj["user_id"].(json.Number); !ok || id.String() != f.UserID {
+			return false
+		}
+	}
+	if f.Title != "" {
+		title, ok := obj["title"].(string)
+		if !ok || !strings.Contains(strings.ToLower(title), strings.ToLower(f.Title)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscriber receives the events matching its filter on C until it is
+// closed, falls behind or the Hub is closed.
+type Subscriber struct {
+	C       <-chan Event
+	c       chan Event
+	filter  Filter
+	dropped bool // guarded by Hub.mu
+}
+
+// Hub fans accepted events out to subscribers. It is safe for concurrent use.
+type Hub struct {
+	buffer   int
+	redactor *redact.Redactor
+
+	mu     sync.RWMutex
+	subs   map[*Subscriber]struct{}
+	closed bool
+
+	// sendMu orders the events sent by concurrent Publish calls. It is only
+	// taken while holding mu for reading.
+	sendMu sync.Mutex
+	seq    uint64 // guarded by sendMu
+}
+
+// New returns a Hub applying the redaction rules of cfg.
+func New(cfg config.TailConfig, key []byte) (*Hub, error) {
+	r, err := redact.New(cfg.Redact, key)
+	if err != nil {
+		return nil, fmt.Errorf("tail: %w", err)
+	}
+	buffer := cfg.Buffer
+	if buffer <= 0 {
+		buffer = 256
+	}
+	return &Hub{buffer: buffer, redactor: r, subs: make(map[*Subscriber]struct{})}, nil
+}
+
+// Subscribe returns a subscriber for the events matching f, or nil when the
+// Hub is closed.
+func (h *Hub) Subscribe(f Filter) *Subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	c := make(chan Event, h.buffer)
+	s := &Subscriber{C: c, c: c, filter: f}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Unsubscribe stops s and closes its channel, unless that already happened.
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(s)
+}
+
+// Dropped reports whether s was disconnected for falling behind.
+func (h *Hub) Dropped(s *Subscriber) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return s.dropped
+}
+
+// Subscribers returns the number of subscribers.
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Close closes every subscriber and refuses new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		h.remove(s)
+	}
+}
+
+func (h *Hub) remove(s *Subscriber) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// Publish offers an event of type typ to every subscriber whose filter it
+// matches. It never blocks: subscribers whose buffer is full are
+// disconnected and counted in Dropped. Nothing is encoded while there are no
+// subscribers, and matching and redaction run without excluding other
+// publishers.
+func (h *Hub) Publish(typ string, entry any) {
+	h.mu.RLock()
+	idle := len(h.subs) == 0
+	h.mu.RUnlock()
+	if idle {
+		return
+	}
+
+	record, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	var matched []*Subscriber
+	for s := range h.subs {
+		if s.filter.match(typ, doc) {
+			matched = append(matched, s)
+		}
+	}
+	h.mu.RUnlock()
+	if len(matched) == 0 {
+		return
+	}
+	h.redactor.Doc(doc)
+	if record, err = json.Marshal(doc); err != nil {
+		return
+	}
+
+	var full []*Subscriber
+	h.mu.RLock()
+	h.sendMu.Lock()
+	h.seq++
+	ev := Event{Seq: h.seq, Type: typ, Record: record}
+	for _, s := range matched {
+		if _, ok := h.subs[s]; !ok {
+			continue // unsubscribed since matching, its channel is closed
+		}
+		select {
+		case s.c <- ev:
+		default:
+			full = append(full, s)
+		}
+	}
+	h.sendMu.Unlock()
+	h.mu.RUnlock()
+	if len(full) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range full {
+		if _, ok := h.subs[s]; ok {
+			s.dropped = true
+			h.remove(s)
+			Dropped.Add(1)
+		}
+	}
+}
+
+// stage publishes entries on their way to a batcher.
+type stage[T any] struct {
+	hub  *Hub
+	name string
+	next batcher.Batcher[T]
+}
+
+// Wrap returns a batcher that publishes every entry of the named event type
+// to h before adding it to next.
+func Wrap[T any](h *Hub, name string, next batcher.Batcher[T]) batcher.Batcher[T] {
+	return &stage[T]{hub: h, name: name, next: next}
+}
+
+func (s *stage[T]) Add(entry T) {
+	s.hub.Publish(s.name, entry)
+	s.next.Add(entry)
+}
+
+// Load returns the load of next, which entries are added to.
+func (s *stage[T]) Load() float64 { return batcher.LoadOf(s.next) }
+
+func (s *stage[T]) Start() { s.next.Start() }
+
+func (s *stage[T]) Stop() { s.next.Stop() }
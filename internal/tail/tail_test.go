@@ -0,0 +1,144 @@
+package tail
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"benzinga-webhook/internal/batcher"
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHub(t *testing.T, buffer int) *Hub {
+	h, err := New(config.TailConfig{Buffer: buffer, Redact: config.DefaultTailRedact}, nil)
+	require.NoError(t, err)
+	return h
+}
+
+func entry(userID int, title string) model.LogEntry {
+	return model.LogEntry{
+		UserID: userID,
+		Title:  title,
+		Meta: model.Meta{
+			Logins:       []model.Login{{Time: "2020-08-08T01:52:50Z", IP: "81.2.69.142"}},
+			PhoneNumbers: model.PhoneNumbers{Home: "555-1212-123", Mobile: "555-1212-456"},
+		},
+	}
+}
+
+func TestPublishFiltersAndRedacts(t *testing.T) {
+	h := newHub(t, 8)
+	all := h.Subscribe(Filter{})
+	user := h.Subscribe(Filter{UserID: "2"})
+	title := h.Subscribe(Filter{Title: "REFUND"})
+	orders := h.Subscribe(Filter{Type: "order"})
+
+	h.Publish("log_entry", entry(1, "Order"))
+	h.Publish("log_entry", entry(2, "Partial refund"))
+	h.Publish("order", json.RawMessage(`{"id":"o-1"}`))
+
+	assert.Len(t, all.C, 3)
+	require.Len(t, user.C, 1)
+	ev := <-user.C
+	assert.Equal(t, uint64(2), ev.Seq)
+	assert.Equal(t, "log_entry", ev.Type)
+	var got model.LogEntry
+	require.NoError(t, json.Unmarshal(ev.Record, &got))
+	assert.Equal(t, "81.2.69.0", got.Meta.Logins[0].IP)
+	assert.Equal(t, "*********123", got.Meta.PhoneNumbers.Home)
+
+	require.Len(t, title.C, 1)
+	assert.Equal(t, uint64(2), (<-title.C).Seq)
+	require.Len(t, orders.C, 1)
+	assert.JSONEq(t, `{"id":"o-1"}`, string((<-orders.C).Record))
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	h := newHub(t, 1)
+	slow := h.Subscribe(Filter{})
+	fast := h.Subscribe(Filter{})
+	before := Dropped.Value()
+
+	h.Publish("log_entry", entry(1, "first"))
+	<-fast.C
+	h.Publish("log_entry", entry(1, "second"))
+
+	assert.True(t, h.Dropped(slow))
+	assert.False(t, h.Dropped(fast))
+	assert.Equal(t, before+1, Dropped.Value())
+	assert.Equal(t, 1, h.Subscribers())
+	// The buffered event is still delivered before the channel reports closed.
+	_, ok := <-slow.C
+	assert.True(t, ok)
+	_, ok = <-slow.C
+	assert.False(t, ok)
+	assert.Len(t, fast.C, 1)
+}
+
+func TestClose(t *testing.T) {
+	h := newHub(t, 1)
+	s := h.Subscribe(Filter{})
+	h.Unsubscribe(s)
+	h.Unsubscribe(s)
+	_, ok := <-s.C
+	assert.False(t, ok)
+
+	s = h.Subscribe(Filter{})
+	h.Close()
+	_, ok = <-s.C
+	assert.False(t, ok)
+	assert.False(t, h.Dropped(s))
+	assert.Nil(t, h.Subscribe(Filter{}))
+}
+
+func TestConcurrentPublish(t *testing.T) {
+	h := newHub(t, 1000)
+	s := h.Subscribe(Filter{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				h.Publish("log_entry", entry(i+1, "concurrent"))
+				h.Unsubscribe(h.Subscribe(Filter{UserID: "1"}))
+			}
+		}()
+	}
+	wg.Wait()
+	h.Close()
+
+	var seq uint64
+	for ev := range s.C {
+		assert.Greater(t, ev.Seq, seq, "events arrive in order")
+		seq = ev.Seq
+	}
+	assert.Equal(t, uint64(400), seq)
+	assert.False(t, h.Dropped(s))
+}
+
+type recorder struct {
+	entries []model.LogEntry
+}
+
+func (r *recorder) Add(e model.LogEntry) { r.entries = append(r.entries, e) }
+func (r *recorder) Load() float64        { return 0.5 }
+func (r *recorder) Start()               {}
+func (r *recorder) Stop()                {}
+
+func TestWrap(t *testing.T) {
+	h := newHub(t, 1)
+	s := h.Subscribe(Filter{})
+	next := &recorder{}
+	b := Wrap[model.LogEntry](h, "log_entry", next)
+
+	b.Add(entry(1, "Order"))
+	assert.Len(t, next.entries, 1)
+	assert.Len(t, s.C, 1)
+	assert.Equal(t, "81.2.69.142", next.entries[0].Meta.Logins[0].IP, "redaction must not reach the batcher")
+	assert.Equal(t, 0.5, batcher.LoadOf(b))
+}
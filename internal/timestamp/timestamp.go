@@ -0,0 +1,105 @@
+// Package timestamp normalizes login times to UTC and rejects times that lie
+// outside the accepted clock-skew window.
+package timestamp
+
+import (
+	"sort"
+	"time"
+
+	"benzinga-webhook/internal/config"
+	"benzinga-webhook/internal/model"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Layout is the format login times are accepted and forwarded in.
+const Layout = time.RFC3339Nano
+
+// Policy bounds timestamps relative to the server clock.
+type Policy struct {
+	// MaxFuture is how far ahead of now a timestamp may be. Zero disables the bound.
+	MaxFuture time.Duration
+	// MaxAge is how far behind now a timestamp may be. Zero disables the bound.
+	MaxAge time.Duration
+	// Now returns the server time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// New returns the policy configured via LOGIN_MAX_FUTURE and LOGIN_MAX_AGE.
+func New(cfg *config.Config) *Policy {
+	return &Policy{MaxFuture: cfg.LoginMaxFuture, MaxAge: cfg.LoginMaxAge, Now: time.Now}
+}
+
+func (p *Policy) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+	return p.Now()
+}
+
+// InFuture reports whether t is further ahead of now than MaxFuture allows.
+func (p *Policy) InFuture(t time.Time) bool {
+	return p.MaxFuture > 0 && t.Sub(p.now()) > p.MaxFuture
+}
+
+// TooOld reports whether t is further behind now than MaxAge allows.
+func (p *Policy) TooOld(t time.Time) bool {
+	return p.MaxAge > 0 && p.now().Sub(t) > p.MaxAge
+}
+
+// ValidateFuture implements the "maxfuture" validation tag. Values that are not
+// valid timestamps pass, leaving them to the datetime tag.
+func (p *Policy) ValidateFuture(fl validator.FieldLevel) bool {
+	t, err := time.Parse(time.RFC3339, fl.Field().String())
+	return err != nil || !p.InFuture(t)
+}
+
+// ValidateAge implements the "maxage" validation tag.
+func (p *Policy) ValidateAge(fl validator.FieldLevel) bool {
+	t, err := time.Parse(time.RFC3339, fl.Field().String())
+	return err != nil || !p.TooOld(t)
+}
+
+// RegisterValidations binds the maxfuture and maxage tags to p.
+func (p *Policy) RegisterValidations(v *validator.Validate) error {
+	if err := v.RegisterValidation("maxfuture", p.ValidateFuture); err != nil {
+		return err
+	}
+	return v.RegisterValidation("maxage", p.ValidateAge)
+}
+
+// UTC rewrites an RFC 3339 timestamp in UTC, e.g. "2020-08-08T03:52:50+02:00"
+// becomes "2020-08-08T01:52:50Z".
+func UTC(s string) (string, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(Layout), nil
+}
+
+// Logins normalizes every login time to UTC, keeping the time as sent in
+// OriginalTime when it differs, and returns the logins ordered oldest first
+// with repeats of the same instant and IP removed.
+func Logins(logins []model.Login) []model.Login {
+	out := make([]model.Login, 0, len(logins))
+	seen := make(map[model.Login]bool, len(logins))
+	for _, l := range logins {
+		l.OriginalTime = ""
+		if utc, err := UTC(l.Time); err == nil && utc != l.Time {
+			l.OriginalTime, l.Time = l.Time, utc
+		}
+		key := model.Login{Time: l.Time, IP: l.IP}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, l)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339, out[i].Time)
+		tj, _ := time.Parse(time.RFC3339, out[j].Time)
+		return ti.Before(tj)
+	})
+	return out
+}
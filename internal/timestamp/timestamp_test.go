@@ -0,0 +1,74 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+
+	"benzinga-webhook/internal/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTC(t *testing.T) {
+	utc, err := UTC("2020-08-08T03:52:50+02:00")
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-08-08T01:52:50Z", utc)
+
+	utc, err = UTC("2020-08-07T20:52:50.250-05:00")
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-08-08T01:52:50.25Z", utc)
+
+	_, err = UTC("08/08/2020")
+	assert.Error(t, err)
+}
+
+func TestLogins(t *testing.T) {
+	logins := Logins([]model.Login{
+		{Time: "2020-08-08T03:52:50+02:00", IP: "10.0.0.1"},
+		{Time: "2020-08-08T00:00:00Z", IP: "10.0.0.2"},
+		{Time: "2020-08-08T01:52:50Z", IP: "10.0.0.1"},
+		{Time: "2020-08-08T01:52:50Z", IP: "10.0.0.3"},
+	})
+
+	assert.Equal(t, []model.Login{
+		{Time: "2020-08-08T00:00:00Z", IP: "10.0.0.2"},
+		{Time: "2020-08-08T01:52:50Z", IP: "10.0.0.1", OriginalTime: "2020-08-08T03:52:50+02:00"},
+		{Time: "2020-08-08T01:52:50Z", IP: "10.0.0.3"},
+	}, logins)
+}
+
+func TestPolicy(t *testing.T) {
+	now := time.Date(2020, 8, 8, 12, 0, 0, 0, time.UTC)
+	p := &Policy{MaxFuture: 5 * time.Minute, MaxAge: 24 * time.Hour, Now: func() time.Time { return now }}
+
+	type login struct {
+		Time string `validate:"maxfuture,maxage"`
+	}
+	v := validator.New()
+	assert.NoError(t, p.RegisterValidations(v))
+
+	for value, tag := range map[string]string{
+		"2020-08-08T12:04:00Z":      "",
+		"2020-08-08T14:04:00+02:00": "",
+		"2020-08-08T12:06:00Z":      "maxfuture",
+		"2020-08-07T11:59:00Z":      "maxage",
+		"not a time":                "",
+	} {
+		t.Run(value, func(t *testing.T) {
+			err := v.Struct(login{Time: value})
+			if tag == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var errs validator.ValidationErrors
+			if assert.ErrorAs(t, err, &errs) {
+				assert.Equal(t, tag, errs[0].Tag())
+			}
+		})
+	}
+
+	unbounded := &Policy{}
+	assert.False(t, unbounded.InFuture(now.Add(24*time.Hour)))
+	assert.False(t, unbounded.TooOld(time.Time{}))
+}
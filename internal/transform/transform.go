@@ -0,0 +1,194 @@
+// Package transform rewrites records with the jq programs declared in
+// CONFIG_FILE, either before events are batched or before a sink delivers
+// them.
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"time"
+
+	"benzinga-webhook/internal/config"
+
+	"github.com/itchyny/gojq"
+	"go.uber.org/zap"
+)
+
+// Timeout bounds a single run of a transform, so that a program that does not
+// terminate cannot stall ingestion.
+const Timeout = 100 * time.Millisecond
+
+// Errors counts failed runs by transform name. It is published at
+// /admin/debug/vars as "transform_errors".
+var Errors = expvar.NewMap("transform_errors")
+
+// Transform is a compiled jq program.
+type Transform struct {
+	Name string
+	code *gojq.Code
+}
+
+// Compile compiles the jq program src. The program cannot read environment
+// variables.
+func Compile(name, src string) (*Transform, error) {
+	q, err := gojq.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("transform %q: %w", name, err)
+	}
+	code, err := gojq.Compile(q)
+	if err != nil {
+		return nil, fmt.Errorf("transform %q: %w", name, err)
+	}
+	return &Transform{Name: name, code: code}, nil
+}
+
+// Run returns the first output of t for v, or nil when there is none. v is a
+// document decoded with json.Decoder.UseNumber.
+func (t *Transform) Run(ctx context.Context, v any) (any, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	out, ok := t.code.RunWithContext(ctx, v).Next()
+	if !ok {
+		return nil, nil
+	}
+	if err, isErr := out.(error); isErr {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("transform %q: timed out after %s", t.Name, Timeout)
+		}
+		return nil, fmt.Errorf("transform %q: %w", t.Name, err)
+	}
+	return out, nil
+}
+
+// Set holds the transforms declared in CONFIG_FILE by name.
+type Set struct {
+	byName map[string]*Transform
+}
+
+// New compiles every transform in cfg.
+func New(cfg *config.Config) (*Set, error) {
+	s := &Set{byName: make(map[string]*Transform, len(cfg.Transforms))}
+	for _, tc := range cfg.Transforms {
+		t, err := Compile(tc.Name, tc.JQ)
+		if err != nil {
+			return nil, err
+		}
+		s.byName[tc.Name] = t
+	}
+	return s, nil
+}
+
+// Pipeline returns the named transforms in order.
+func (s *Set) Pipeline(names ...string) (Pipeline, error) {
+	p := make(Pipeline, 0, len(names))
+	for _, name := range names {
+		t, ok := s.byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q", name)
+		}
+		p = append(p, t)
+	}
+	return p, nil
+}
+
+// Pipeline runs transforms in order, each on the output of the previous one.
+type Pipeline []*Transform
+
+// Run returns the output of the last transform for v, or nil when one of
+// them had no output or output null.
+func (p Pipeline) Run(ctx context.Context, v any) (any, error) {
+	for _, t := range p {
+		var err error
+		if v, err = t.Run(ctx, v); err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, nil
+		}
+	}
+	return v, nil
+}
+
+// Apply rewrites record. Records the pipeline fails on are dropped and counted
+// in Errors under the name of the failing transform rather than failing the
+// batch. It implements sink.Stage.
+func (p Pipeline) Apply(record json.RawMessage) (json.RawMessage, error) {
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("transform: decode record: %w", err)
+	}
+	for _, t := range p {
+		var err error
+		if doc, err = t.Run(context.Background(), doc); err != nil {
+			Errors.Add(t.Name, 1)
+			return nil, nil
+		}
+		if doc == nil {
+			return nil, nil
+		}
+	}
+	out, err := json.Marshal(doc)
+	if err != nil && len(p) > 0 {
+		// e.g. infinite or nan, which have no JSON encoding.
+		Errors.Add(p[len(p)-1].Name, 1)
+		return nil, nil
+	}
+	return out, err
+}
+
+// Batcher is the batcher.Batcher[json.RawMessage] Wrap adds records to. It is
+// declared here because package sink, which package batcher depends on,
+// depends on this package.
+type Batcher interface {
+	Add(record json.RawMessage)
+	Start()
+	Stop()
+}
+
+// Wrapped is a batcher for entries of type T that transforms them on the way
+// to a Batcher.
+type Wrapped[T any] struct {
+	pipeline Pipeline
+	next     Batcher
+	name     string
+	log      *zap.Logger
+}
+
+// Wrap returns a batcher that encodes every entry of the named event type as
+// JSON, runs it through p and adds the result to next. Entries p drops or
+// fails on are not batched.
+func Wrap[T any](name string, p Pipeline, next Batcher, log *zap.Logger) *Wrapped[T] {
+	return &Wrapped[T]{pipeline: p, next: next, name: name, log: log}
+}
+
+// Add transforms entry and adds the result to the next batcher.
+func (w *Wrapped[T]) Add(entry T) {
+	record, err := json.Marshal(entry)
+	if err != nil {
+		w.log.Error("failed to marshal entry", zap.String("type", w.name), zap.Error(err))
+		return
+	}
+	if record, _ = w.pipeline.Apply(record); record != nil {
+		w.next.Add(record)
+	}
+}
+
+// Load returns the load of the next batcher, or 0 when it cannot report one.
+func (w *Wrapped[T]) Load() float64 {
+	if l, ok := w.next.(interface{ Load() float64 }); ok {
+		return l.Load()
+	}
+	return 0
+}
+
+// Start starts the next batcher.
+func (w *Wrapped[T]) Start() { w.next.Start() }
+
+// Stop stops the next batcher.
+func (w *Wrapped[T]) Stop() { w.next.Stop() }
@@ -0,0 +1,118 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"benzinga-webhook/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const record = `{"user_id":1,"total":12,"title":"Order","meta":{"logins":[]},"completed":false}`
+
+func newSet(t *testing.T, transforms ...config.TransformConfig) *Set {
+	t.Helper()
+	s, err := New(&config.Config{Transforms: transforms})
+	require.NoError(t, err)
+	return s
+}
+
+func TestPipelineApply(t *testing.T) {
+	s := newSet(t,
+		config.TransformConfig{Name: "camel", JQ: `{userId: .user_id} + del(.user_id)`},
+		config.TransformConfig{Name: "no-meta", JQ: `del(.meta)`},
+		config.TransformConfig{Name: "static", JQ: `. + {source: "benzinga"}`},
+		config.TransformConfig{Name: "derived", JQ: `.total_cents = .total * 100`},
+		config.TransformConfig{Name: "completed-only", JQ: `select(.completed)`},
+		config.TransformConfig{Name: "null", JQ: `null`},
+	)
+
+	p, err := s.Pipeline("camel", "no-meta", "static", "derived")
+	require.NoError(t, err)
+	out, err := p.Apply(json.RawMessage(record))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"userId":1,"total":12,"total_cents":1200,"title":"Order","completed":false,"source":"benzinga"}`, string(out))
+
+	for _, name := range []string{"completed-only", "null"} {
+		p, err = s.Pipeline(name)
+		require.NoError(t, err)
+		out, err = p.Apply(json.RawMessage(record))
+		assert.NoError(t, err)
+		assert.Nil(t, out, name)
+	}
+
+	_, err = s.Pipeline("camel", "missing")
+	assert.EqualError(t, err, `unknown transform "missing"`)
+}
+
+func TestPipelineCountsErrors(t *testing.T) {
+	s := newSet(t,
+		config.TransformConfig{Name: "ok", JQ: `.`},
+		config.TransformConfig{Name: "bad-type", JQ: `.title + 1`},
+		config.TransformConfig{Name: "nan", JQ: `.total = nan`},
+		config.TransformConfig{Name: "loop", JQ: `last(repeat(.))`},
+	)
+	for _, name := range []string{"bad-type", "nan", "loop"} {
+		before := counter(name)
+		p, err := s.Pipeline("ok", name)
+		require.NoError(t, err)
+		out, err := p.Apply(json.RawMessage(record))
+		assert.NoError(t, err, "failures drop the record instead of failing the batch")
+		assert.Nil(t, out)
+		assert.Equal(t, before+1, counter(name), name)
+	}
+	assert.Zero(t, counter("ok"))
+
+	p, _ := s.Pipeline("loop")
+	_, err := p.Run(context.Background(), map[string]any{})
+	assert.EqualError(t, err, `transform "loop": timed out after 100ms`)
+}
+
+func TestCompile(t *testing.T) {
+	_, err := Compile("broken", `{user_id`)
+	assert.ErrorContains(t, err, `transform "broken"`)
+	_, err = Compile("undefined", `frobnicate(.)`)
+	assert.ErrorContains(t, err, "frobnicate")
+
+	env, err := Compile("env", `$ENV | length`)
+	require.NoError(t, err)
+	t.Setenv("SECRET", "x")
+	n, err := env.Run(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n, "the environment is not visible to transforms")
+}
+
+type captureBatcher struct {
+	records []json.RawMessage
+}
+
+func (c *captureBatcher) Add(r json.RawMessage) { c.records = append(c.records, r) }
+func (c *captureBatcher) Start()                {}
+func (c *captureBatcher) Stop()                 {}
+
+func TestWrap(t *testing.T) {
+	type entry struct {
+		UserID    int  `json:"user_id"`
+		Completed bool `json:"completed"`
+	}
+	s := newSet(t, config.TransformConfig{Name: "completed", JQ: `select(.completed) | {userId: .user_id}`})
+	p, _ := s.Pipeline("completed")
+	next := &captureBatcher{}
+	b := Wrap[entry]("log_entry", p, next, zap.NewNop())
+
+	b.Add(entry{UserID: 1, Completed: true})
+	b.Add(entry{UserID: 2})
+	require.Len(t, next.records, 1)
+	assert.JSONEq(t, `{"userId":1}`, string(next.records[0]))
+}
+
+func counter(name string) int64 {
+	if v, ok := Errors.Get(name).(interface{ Value() int64 }); ok {
+		return v.Value()
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+// Package versioning upgrades payloads sent in older schema versions into the
+// current shape before they are validated and batched.
+package versioning
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"benzinga-webhook/internal/model"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Header is the request header producers may use to declare a payload version.
+const Header = "X-Schema-Version"
+
+// Field is the payload field producers may use to declare a payload version.
+// It takes precedence over Header and is removed before validation.
+const Field = "schema_version"
+
+// ErrUnsupportedVersion is returned for versions that are not registered.
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// Upgrade converts a decoded payload from one version into the next.
+type Upgrade func(doc map[string]any) (map[string]any, error)
+
+// InvalidError reports a payload that does not match the Model of the version
+// it declared. Err is the decoding error, or the validator.ValidationErrors.
+type InvalidError struct {
+	Version string
+	Err     error
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("invalid version %q payload: %v", e.Version, e.Err)
+}
+
+func (e *InvalidError) Unwrap() error { return e.Err }
+
+// Version describes one payload version.
+type Version struct {
+	Name string
+	// Model is the Go definition of the payload at this version, a struct
+	// value whose validate tags check payloads declared as this version before
+	// they are upgraded. It is required for every version but the current one,
+	// whose payloads are checked by the event type after upgrading.
+	Model any
+	// Upgrade converts a payload of this version into the next one. It is nil
+	// for the current version.
+	Upgrade Upgrade
+}
+
+// Registry holds the supported versions of a payload, oldest first.
+type Registry struct {
+	versions []Version
+	index    map[string]int
+}
+
+// LogEntry is the registry for model.LogEntry payloads.
+var LogEntry = MustRegistry(
+	Version{Name: "1", Model: model.LogEntry{}},
+)
+
+// NewRegistry builds a registry from versions ordered oldest to current.
+// Every version but the current one must have an Upgrade.
+func NewRegistry(versions ...Version) (*Registry, error) {
+	if len(versions) == 0 {
+		return nil, errors.New("at least one version is required")
+	}
+	r := &Registry{versions: versions, index: make(map[string]int, len(versions))}
+	for i, v := range versions {
+		if _, dup := r.index[v.Name]; dup || v.Name == "" {
+			return nil, fmt.Errorf("invalid or duplicate version %q", v.Name)
+		}
+		current := i == len(versions)-1
+		if current != (v.Upgrade == nil) {
+			return nil, fmt.Errorf("version %q: only the current version may omit Upgrade", v.Name)
+		}
+		if !current && (v.Model == nil || reflect.TypeOf(v.Model).Kind() != reflect.Struct) {
+			return nil, fmt.Errorf("version %q: Model must be a struct value", v.Name)
+		}
+		r.index[v.Name] = i
+	}
+	return r, nil
+}
+
+// MustRegistry is like NewRegistry but panics on error.
+func MustRegistry(versions ...Version) *Registry {
+	r, err := NewRegistry(versions...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Current returns the name of the current version.
+func (r *Registry) Current() string {
+	return r.versions[len(r.versions)-1].Name
+}
+
+// Supported returns the names of all registered versions, oldest first.
+func (r *Registry) Supported() []string {
+	names := make([]string, len(r.versions))
+	for i, v := range r.versions {
+		names[i] = v.Name
+	}
+	return names
+}
+
+// Model returns the Go definition registered for a version.
+func (r *Registry) Model(version string) (any, bool) {
+	i, ok := r.index[version]
+	if !ok {
+		return nil, false
+	}
+	return r.versions[i].Model, true
+}
+
+// Check decodes doc into the Model of an older version and validates it with
+// validate, if not nil, so that payloads are rejected in the shape they were
+// sent in rather than after upgrading. Payloads of the current version and of
+// unknown versions, which Upgrade rejects, pass. It returns an *InvalidError.
+func (r *Registry) Check(doc map[string]any, version string, validate *validator.Validate) error {
+	i, ok := r.index[version]
+	if !ok || i == len(r.versions)-1 {
+		return nil
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return &InvalidError{Version: version, Err: err}
+	}
+	payload := reflect.New(reflect.TypeOf(r.versions[i].Model)).Interface()
+	if err := json.Unmarshal(raw, payload); err != nil {
+		return &InvalidError{Version: version, Err: err}
+	}
+	if validate != nil {
+		if err := validate.Struct(payload); err != nil {
+			return &InvalidError{Version: version, Err: err}
+		}
+	}
+	return nil
+}
+
+// Detect returns the version declared by doc or header, removing the version
+// field from doc. Undeclared payloads are assumed to be the current version,
+// so producers that never declared one keep working when an older version is
+// registered.
+func (r *Registry) Detect(doc map[string]any, header string) string {
+	if v, ok := doc[Field]; ok {
+		delete(doc, Field)
+		switch v := v.(type) {
+		case string:
+			return v
+		case json.Number:
+			return v.String()
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	if header = strings.TrimSpace(header); header != "" {
+		return header
+	}
+	return r.Current()
+}
+
+// Upgrade runs the upgrade chain from version to the current version.
+func (r *Registry) Upgrade(doc map[string]any, version string) (map[string]any, error) {
+	i, ok := r.index[version]
+	if !ok {
+		return nil, fmt.Errorf("%w %q; supported versions: %s",
+			ErrUnsupportedVersion, version, strings.Join(r.Supported(), ", "))
+	}
+	for ; i < len(r.versions)-1; i++ {
+		var err error
+		if doc, err = r.versions[i].Upgrade(doc); err != nil {
+			return nil, fmt.Errorf("upgrade from version %q: %w", r.versions[i].Name, err)
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,141 @@
+package versioning
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"benzinga-webhook/internal/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// v0 is a hypothetical version 0 payload, which named the user "user".
+type v0 struct {
+	User  int    `json:"user" validate:"required"`
+	Title string `json:"title" validate:"required,min=3"`
+}
+
+// v1 is a hypothetical version 1 payload, which had no "completed" flag.
+type v1 struct {
+	UserID int `json:"user_id" validate:"required"`
+}
+
+// renameUser upgrades a hypothetical version 0 payload that used "user" instead of "user_id".
+func renameUser(doc map[string]any) (map[string]any, error) {
+	v, ok := doc["user"]
+	if !ok {
+		return nil, errors.New("missing user")
+	}
+	delete(doc, "user")
+	doc["user_id"] = v
+	return doc, nil
+}
+
+func addCompleted(doc map[string]any) (map[string]any, error) {
+	if _, ok := doc["completed"]; !ok {
+		doc["completed"] = false
+	}
+	return doc, nil
+}
+
+func TestNewRegistry(t *testing.T) {
+	_, err := NewRegistry()
+	assert.Error(t, err)
+
+	_, err = NewRegistry(Version{Name: "1", Model: v1{}, Upgrade: addCompleted}, Version{Name: "1"})
+	assert.ErrorContains(t, err, "duplicate")
+
+	_, err = NewRegistry(Version{Name: "0", Upgrade: renameUser}, Version{Name: "1"})
+	assert.EqualError(t, err, `version "0": Model must be a struct value`)
+
+	_, err = NewRegistry(Version{Name: "1"}, Version{Name: "2"})
+	assert.ErrorContains(t, err, `version "1"`)
+
+	_, err = NewRegistry(Version{Name: "1", Upgrade: addCompleted})
+	assert.ErrorContains(t, err, `version "1"`)
+
+	assert.Panics(t, func() { MustRegistry() })
+}
+
+func TestLogEntryRegistry(t *testing.T) {
+	assert.Equal(t, "1", LogEntry.Current())
+	assert.Equal(t, []string{"1"}, LogEntry.Supported())
+	m, ok := LogEntry.Model("1")
+	assert.True(t, ok)
+	assert.IsType(t, model.LogEntry{}, m)
+	_, ok = LogEntry.Model("7")
+	assert.False(t, ok)
+}
+
+func TestDetect(t *testing.T) {
+	r := MustRegistry(
+		Version{Name: "0", Model: v0{}, Upgrade: renameUser},
+		Version{Name: "1"},
+	)
+
+	doc := map[string]any{"schema_version": json.Number("1"), "user_id": 1}
+	assert.Equal(t, "1", r.Detect(doc, "0"))
+	assert.NotContains(t, doc, "schema_version")
+
+	assert.Equal(t, "1", r.Detect(map[string]any{"schema_version": "1"}, ""))
+	assert.Equal(t, "1", r.Detect(map[string]any{}, " 1 "))
+	assert.Equal(t, "0", r.Detect(map[string]any{}, "0"))
+	assert.Equal(t, "1", r.Detect(map[string]any{}, ""))
+}
+
+func TestUpgrade(t *testing.T) {
+	r := MustRegistry(
+		Version{Name: "0", Model: v0{}, Upgrade: renameUser},
+		Version{Name: "1", Model: v1{}, Upgrade: addCompleted},
+		Version{Name: "2"},
+	)
+	assert.Equal(t, "2", r.Current())
+
+	doc, err := r.Upgrade(map[string]any{"user": 7}, "0")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"user_id": 7, "completed": false}, doc)
+
+	doc, err = r.Upgrade(map[string]any{"user_id": 7, "completed": true}, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"user_id": 7, "completed": true}, doc)
+
+	doc, err = r.Upgrade(map[string]any{"user_id": 7}, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"user_id": 7}, doc)
+
+	_, err = r.Upgrade(map[string]any{}, "0")
+	assert.EqualError(t, err, `upgrade from version "0": missing user`)
+
+	_, err = r.Upgrade(map[string]any{}, "9")
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+	assert.EqualError(t, err, `unsupported schema version "9"; supported versions: 0, 1, 2`)
+}
+
+func TestCheck(t *testing.T) {
+	r := MustRegistry(
+		Version{Name: "0", Model: v0{}, Upgrade: renameUser},
+		Version{Name: "1"},
+	)
+	validate := validator.New()
+
+	assert.NoError(t, r.Check(map[string]any{"user": json.Number("7"), "title": "old"}, "0", validate))
+	// The current version and unknown ones are left to the caller.
+	assert.NoError(t, r.Check(map[string]any{}, "1", validate))
+	assert.NoError(t, r.Check(map[string]any{}, "9", validate))
+
+	err := r.Check(map[string]any{"user": json.Number("7"), "title": "x"}, "0", validate)
+	var invalid *InvalidError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "0", invalid.Version)
+	var verrs validator.ValidationErrors
+	require.ErrorAs(t, err, &verrs)
+	assert.Equal(t, "v0.Title", verrs[0].Namespace())
+
+	err = r.Check(map[string]any{"user": "seven", "title": "old"}, "0", validate)
+	require.ErrorAs(t, err, &invalid)
+	var typeErr *json.UnmarshalTypeError
+	assert.ErrorAs(t, err, &typeErr)
+}